@@ -0,0 +1,54 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// collectDecls records the function declarations of the files in nodes,
+// which accessor calls are resolved against wherever they appear.
+func (a *analyzer) collectDecls(nodes []ast.Node) {
+	for _, n := range nodes {
+		file, ok := n.(*ast.File)
+		if !ok {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Body != nil {
+				if fn, ok := a.info.Defs[fd.Name].(*types.Func); ok {
+					a.decls[fn] = fd
+				}
+			}
+		}
+	}
+}
+
+// accessed returns the map the call returns when it calls an accessor of
+// this package, a function or method whose body only returns a field or
+// variable, as in func (s *S) Set() map[string]bool { return s.set }, or
+// nil.
+func (a *analyzer) accessed(call *ast.CallExpr) types.Object {
+	fn := typeutil.StaticCallee(a.info, call)
+	if fn == nil {
+		return nil
+	}
+	fn = fn.Origin()
+	if obj, ok := a.accessors[fn]; ok {
+		return obj
+	}
+	// A recursive accessor resolves to nothing.
+	a.accessors[fn] = nil
+	fd := a.decls[fn]
+	if fd == nil || len(fd.Body.List) != 1 {
+		return nil
+	}
+	ret, ok := fd.Body.List[0].(*ast.ReturnStmt)
+	if !ok || len(ret.Results) != 1 {
+		return nil
+	}
+	obj := a.mapObject(ast.Unparen(ret.Results[0]))
+	a.accessors[fn] = obj
+	return obj
+}
@@ -0,0 +1,198 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// pointerAlias is a local variable holding the address of a tracked map, as
+// in p := &set, through which (*p)[k] writes to the map.
+type pointerAlias struct {
+	// target is the map the variable points to, nil until it is assigned
+	// one. ambiguous is set once it may point to another map, or to one
+	// the analysis cannot see; writes through it are then not attributed.
+	target    types.Object
+	ambiguous bool
+	// uses counts the variable's uses and known those accounted for by
+	// dereferences and assignments; any other use lets the address escape.
+	uses  int
+	known int
+}
+
+// trackPointer records the assignment of rhs to lhs when lhs is a local
+// pointer to a bool map.
+func (a *analyzer) trackPointer(lhs, rhs ast.Expr) {
+	id, ok := lhs.(*ast.Ident)
+	if !ok || id.Name == "_" {
+		return
+	}
+	v, ok := a.identObject(id).(*types.Var)
+	if !ok || !a.isLocalVar(v) || !isBoolMapPointer(v.Type()) {
+		return
+	}
+	pa := a.pointers[v]
+	if pa == nil {
+		pa = &pointerAlias{}
+		a.pointers[v] = pa
+	}
+	if a.info.Uses[id] == v {
+		pa.known++
+	}
+	if tv, ok := a.info.Types[rhs]; ok && tv.IsNil() {
+		// A nil pointer points to no map; dereferencing it panics.
+		return
+	}
+	var target types.Object
+	if u, ok := ast.Unparen(rhs).(*ast.UnaryExpr); ok && u.Op == token.AND {
+		if mi := a.infoFor(a.mapObject(u.X)); mi != nil {
+			target = mi.obj
+			a.aliasedAddrs[u] = true
+		}
+	}
+	if !pa.ambiguous && target != nil && (pa.target == nil || pa.target == target) {
+		pa.target = target
+		return
+	}
+	for _, obj := range []types.Object{pa.target, target} {
+		if mi := a.results[obj]; mi != nil {
+			mi.recordEscape("aliased by " + v.Name() + ", which may point to another map")
+		}
+	}
+	pa.target, pa.ambiguous = nil, true
+}
+
+// handleDeref accounts for the dereference of a pointer alias.
+func (a *analyzer) handleDeref(star *ast.StarExpr) {
+	if id, ok := star.X.(*ast.Ident); ok {
+		if pa := a.pointers[a.info.Uses[id]]; pa != nil {
+			pa.known++
+		}
+	}
+}
+
+// handleAddress records a tracked map whose address is taken other than
+// into a pointer alias: writes through that address cannot be seen.
+func (a *analyzer) handleAddress(u *ast.UnaryExpr) {
+	if u.Op != token.AND || a.aliasedAddrs[u] {
+		return
+	}
+	if mi := a.trackedMap(a.mapObject(u.X)); mi != nil {
+		mi.recordEscape("its address is taken")
+	}
+}
+
+// pointee returns the map the pointer alias x points to, or nil.
+func (a *analyzer) pointee(x ast.Expr) types.Object {
+	id, ok := ast.Unparen(x).(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	if pa := a.pointers[a.info.Uses[id]]; pa != nil && !pa.ambiguous {
+		return pa.target
+	}
+	return nil
+}
+
+// escapeAliases records the maps whose pointer aliases are used other
+// than by dereferences and assignments, such as passed to a function, once
+// countUses has counted their uses.
+func (a *analyzer) escapeAliases() {
+	for v, pa := range a.pointers {
+		if mi := a.results[pa.target]; mi != nil && pa.uses != pa.known {
+			mi.recordEscape("its address escapes through " + v.Name())
+		}
+	}
+}
+
+func isBoolMapPointer(t types.Type) bool {
+	ptr, ok := t.Underlying().(*types.Pointer)
+	if !ok {
+		return false
+	}
+	m, ok := ptr.Elem().Underlying().(*types.Map)
+	return ok && isBool(m.Elem())
+}
+
+// aliasedMap returns the tracked map of this package expr names, as in
+// m2 := m1, or the one standing for the maps expr holds, or nil.
+func (a *analyzer) aliasedMap(expr ast.Expr) *mapInfo {
+	expr = ast.Unparen(expr)
+	if call, ok := expr.(*ast.CallExpr); ok && a.builtin(call) == "append" && len(call.Args) > 0 {
+		// append returns the slice it appends to, or a copy of it.
+		return a.aliasedMap(call.Args[0])
+	}
+	switch expr.(type) {
+	case *ast.Ident, *ast.SelectorExpr, *ast.StarExpr, *ast.IndexExpr, *ast.CallExpr:
+		if mi := a.trackedMap(a.mapObject(expr)); mi != nil && !mi.imported {
+			return mi
+		}
+	}
+	return nil
+}
+
+// mergeAliases makes the maps assigned to one another share one record,
+// that of the map they are reported as: a write through any of their names
+// is a write to the same map, and each of them escapes when one does.
+func (a *analyzer) mergeAliases() {
+	if len(a.mapAliases) == 0 {
+		return
+	}
+	merged := make(map[*mapInfo]*mapInfo)
+	find := func(mi *mapInfo) *mapInfo {
+		for merged[mi] != nil {
+			mi = merged[mi]
+		}
+		return mi
+	}
+	for _, pair := range a.mapAliases {
+		x, y := find(pair[0]), find(pair[1])
+		if x == y {
+			continue
+		}
+		if a.canonical(y, x) {
+			x, y = y, x
+		}
+		x.merge(y)
+		merged[y] = x
+	}
+	for obj, mi := range a.results {
+		a.results[obj] = find(mi)
+	}
+	for i := range a.comparisons {
+		c := &a.comparisons[i]
+		c.x, c.y = find(c.x), find(c.y)
+	}
+}
+
+// canonical reports whether x names the map better than its alias y:
+// fields and package-level variables, which outlive the locals copying
+// them, come first, then the map declared first.
+func (a *analyzer) canonical(x, y *mapInfo) bool {
+	if lx, ly := a.isLocal(x.obj), a.isLocal(y.obj); lx != ly {
+		return ly
+	}
+	return x.obj.Pos() < y.obj.Pos()
+}
+
+// isLocal reports whether obj is declared in a function.
+func (a *analyzer) isLocal(obj types.Object) bool {
+	scope := obj.Parent()
+	return scope != nil && scope != a.pkg.Scope()
+}
+
+// merge adds the writes, uses and escapes recorded for o to mi.
+func (mi *mapInfo) merge(o *mapInfo) {
+	mi.typeExprs = append(mi.typeExprs, o.typeExprs...)
+	mi.values = append(mi.values, o.values...)
+	mi.writes = append(mi.writes, o.writes...)
+	mi.sites = append(mi.sites, o.sites...)
+	mi.reads = append(mi.reads, o.reads...)
+	mi.knownUses += o.knownUses
+	mi.untypedInit = mi.untypedInit || o.untypedInit
+	mi.unfixable = mi.unfixable || o.unfixable
+	mi.recordEscape(o.escape)
+	if mi.serialized == "" {
+		mi.serialized = o.serialized
+	}
+}
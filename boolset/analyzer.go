@@ -1,79 +1,509 @@
+// Package boolset reports map[T]bool values that only ever store true and
+// could be map[T]struct{} sets.
+//
+// Every entry point is safe for concurrent use, as batch drivers call them
+// from many goroutines: the analysis keeps its state per call and only reads
+// the syntax and type information it is given, so packages, and the same
+// package, may be analysed in parallel. Options, a Linter and the Analyzer
+// may be shared between concurrent runs; the functions they hold, such as
+// Options.Filter, must then be safe for concurrent use themselves, while
+// Options.Metrics is updated under a lock. RegisterCheck may be called at
+// any time, but is meant for init functions.
 package boolset
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"go/ast"
-	"go/constant"
 	"go/token"
 	"go/types"
+	"sort"
+	"strings"
 
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
 )
 
+// RuleMapBoolSet identifies the check for map[T]bool values that only ever
+// store true. Rule identifiers are stable across releases so suppressions,
+// baselines and dashboards can refer to them.
+const RuleMapBoolSet = "BS0001"
+
+// docURL is where the analyzer and its rules are documented.
+const docURL = "https://github.com/arturmelanchyk/boolset"
+
+// RuleURL returns the address of the documentation for rule.
+func RuleURL(rule string) string {
+	return docURL + "#" + strings.ToLower(rule)
+}
+
 // Diagnostic represents a linter finding.
 type Diagnostic struct {
+	// Pos and End span the reported map's name, or its first write when
+	// the map is declared in another package.
+	Pos token.Pos
+	End token.Pos
+	// Rule is the stable identifier of the check that produced the finding.
+	// The analyzer reports it as the diagnostic category.
+	Rule    string
+	Message string
+	// Severity is the rule's severity, unless Options override it.
+	Severity Severity
+	// URL documents the rule.
+	URL string
+	// Object is the map variable or field the finding is about.
+	Object types.Object
+	// Edits rewrite the map to map[T]struct{}. They are nil when the
+	// analyser cannot prove the conversion keeps the code compiling.
+	Edits []TextEdit
+	// FixMessage titles Edits for editors offering them as a quick fix.
+	FixMessage string
+	// Related lists every write that stored true in the map, in source
+	// order: the places a conversion to map[T]struct{} touches.
+	Related []RelatedInformation
+	// Reads lists where the map is read for membership, in source order:
+	// lookups, comma-ok lookups and range loops. They have to be rewritten
+	// by hand when converting to map[T]struct{}, as a lookup then no longer
+	// yields a bool.
+	Reads []RelatedInformation
+}
+
+// RelatedInformation points at a source range that contributed to a
+// diagnostic.
+type RelatedInformation struct {
 	Pos     token.Pos
+	End     token.Pos
 	Message string
 }
 
 // Analyze inspects the provided package AST and type info, returning any diagnostics.
+// It returns none when the inputs cannot be analysed; AnalyzeContext
+// reports why.
+// Diagnostics are sorted by position, with at most one per position, so
+// results are stable from run to run.
 func Analyze(pkg *types.Package, files []*ast.File, info *types.Info) []Diagnostic {
-	if pkg == nil || len(files) == 0 || info == nil {
-		return nil
+	diags, _ := AnalyzeContext(context.Background(), pkg, files, info)
+	return diags
+}
+
+// AnalyzeContext is like Analyze, checking ctx between files. It returns
+// ctx's error, and no diagnostics, once ctx is done. Unlike Analyze, it
+// tells "no findings" apart from "the analysis could not run": inputs that
+// cannot be analysed, such as a nil package or type information without
+// Defs, yield an error wrapping ErrInvalidInput.
+func AnalyzeContext(ctx context.Context, pkg *types.Package, files []*ast.File, info *types.Info) ([]Diagnostic, error) {
+	s := defaultSettings()
+	findings, _, err := analyze(ctx, pkg, files, info, nil, &s, nil)
+	return diagnostics(findings), err
+}
+
+// AnalyzeInspector is like AnalyzeContext, walking the syntax with in, an
+// inspector built from files, instead of traversing files itself. Drivers
+// that already hold an inspector for the package, such as the result of
+// golang.org/x/tools/go/analysis/passes/inspect, save a walk of the syntax
+// per package. A nil in makes it equivalent to AnalyzeContext.
+func AnalyzeInspector(ctx context.Context, pkg *types.Package, files []*ast.File, info *types.Info, in *inspector.Inspector) ([]Diagnostic, error) {
+	s := defaultSettings()
+	findings, _, err := analyze(ctx, pkg, files, info, in, &s, nil)
+	return diagnostics(findings), err
+}
+
+// AnalyzeFunc is like Analyze, passing each finding to fn as it is produced
+// instead of collecting them, so batch tools can stream results. Findings
+// come in position order: each map's is passed as soon as it is built, once
+// the whole package has been analysed, with those of registered checks,
+// which are built first, passed in between.
+func AnalyzeFunc(pkg *types.Package, files []*ast.File, info *types.Info, fn func(Finding)) {
+	s := defaultSettings()
+	analyzeFunc(context.Background(), pkg, files, info, nil, &s, nil, fn)
+}
+
+func analyze(ctx context.Context, pkg *types.Package, files []*ast.File, info *types.Info, in *inspector.Inspector, s *settings, facts factStore) ([]Finding, *Result, error) {
+	var findings []Finding
+	result, err := analyzeFunc(ctx, pkg, files, info, in, s, facts, func(f Finding) {
+		findings = append(findings, f)
+	})
+	if err != nil {
+		return nil, nil, err
 	}
+	return findings, result, nil
+}
 
-	v := &analyzer{
-		pkg:        pkg,
-		info:       info,
-		results:    make(map[types.Object]*mapInfo),
-		qualifier:  makeQualifier(pkg),
-		boolValues: make(map[types.Object]truthState),
+// analyzeFunc analyses files, walking them with in when it is not nil.
+func analyzeFunc(ctx context.Context, pkg *types.Package, files []*ast.File, info *types.Info, in *inspector.Inspector, s *settings, facts factStore, emit func(Finding)) (*Result, error) {
+	nodes := make([]ast.Node, len(files))
+	for i, file := range files {
+		nodes[i] = file
 	}
+	return analyzeNodes(ctx, pkg, nodes, in, false, info, s, facts, emit)
+}
 
-	for _, file := range files {
-		v.inspectFile(file)
+// analyzeNodes analyses the syntax trees in nodes, or in the inspector in
+// built from them when it is not nil. When partial is set, nodes cover only
+// part of the package, and maps declared or referenced outside them are
+// dropped, as their other writes cannot be seen.
+func analyzeNodes(ctx context.Context, pkg *types.Package, nodes []ast.Node, in *inspector.Inspector, partial bool, info *types.Info, s *settings, facts factStore, emit func(Finding)) (*Result, error) {
+	// Packages without syntax, such as unsafe, which drivers of fact-based
+	// analyzers also visit, come with empty type information; there is
+	// nothing to validate or analyse.
+	if len(nodes) == 0 && pkg != nil {
+		return &Result{Maps: make(map[types.Object]*MapUsage)}, nil
+	}
+	if err := checkInput(pkg, nodes, info); err != nil {
+		return nil, err
 	}
 
-	var diags []Diagnostic
-	for _, mi := range v.results {
-		if mi.trueCount == 0 || !mi.onlyTrue {
-			continue
+	v := &analyzer{
+		pkg:            pkg,
+		info:           info,
+		results:        make(map[types.Object]*mapInfo),
+		qualifier:      makeQualifier(pkg),
+		typeOwners:     make(map[*ast.MapType]int),
+		boolAssigns:    make(map[types.Object][]boolAssign),
+		addressedBools: make(map[types.Object]bool),
+		cfgs:           make(map[*ast.BlockStmt]*cfg.CFG),
+		pointers:       make(map[types.Object]*pointerAlias),
+		aliasedAddrs:   make(map[*ast.UnaryExpr]bool),
+		elems:          make(map[types.Object]types.Object),
+		holders:        make(map[types.Object]types.Object),
+		decls:          make(map[*types.Func]*ast.FuncDecl),
+		accessors:      make(map[*types.Func]types.Object),
+		closures:       make(map[types.Object]*ast.FuncLit),
+		deferred:       make(map[*ast.BlockStmt]bool),
+		facts:          facts,
+	}
+	v.checks = s.registeredChecks(&CheckPass{Pkg: pkg, Info: info})
+	v.collectDecls(nodes)
+
+	if in != nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		in.WithStack(nil, func(n ast.Node, push bool, stack []ast.Node) bool {
+			if push {
+				v.inspect(n, stack)
+			}
+			return true
+		})
+	} else {
+		for _, n := range nodes {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			v.inspectNode(n)
 		}
-		pos := mi.pos
-		if pos == token.NoPos && mi.obj != nil {
-			pos = mi.obj.Pos()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	v.mergeAliases()
+	if partial {
+		v.dropEscaping(nodes)
+	} else if s.ssa {
+		files := make([]*ast.File, len(nodes))
+		for i, n := range nodes {
+			files[i] = n.(*ast.File)
+		}
+		var err error
+		if v.ssaTruth, err = ssaTruth(pkg, files, info); err != nil {
+			s.debug("SSA unavailable", "package", pkg.Path(), "err", err)
 		}
-		if pos == token.NoPos {
+	}
+	v.resolveWrites()
+	v.resolveValueCalls()
+	v.resolveFlows()
+	v.countUses()
+	v.escapeAliases()
+	v.resolveInstances()
+	v.collectReads(nodes, in)
+	v.exportFacts()
+
+	// The checks' findings are merged in by position as each map's finding
+	// is built, so emit sees every finding in order without waiting for
+	// the rest.
+	var checked []Finding
+	for _, f := range v.checkFindings(s) {
+		s.rewriteMessage(&f)
+		if s.filter != nil && !s.filter(f) {
+			s.debug("finding not reported", "rule", f.Rule, "reason", "dropped by Filter")
 			continue
 		}
+		checked = append(checked, f)
+	}
+	reported := make(map[*mapInfo]bool)
+	for _, mi := range v.reportable(s) {
 		key := mi.keyType
-		diags = append(diags, Diagnostic{
-			Pos:     pos,
-			Message: fmt.Sprintf("map[%s]bool only stores \"true\" values; consider map[%s]struct{}", key, key),
-		})
+		diag := Diagnostic{
+			Pos:      mi.pos,
+			End:      mi.end,
+			Rule:     RuleMapBoolSet,
+			Severity: s.severity(RuleMapBoolSet),
+			URL:      RuleURL(RuleMapBoolSet),
+			Message:  fmt.Sprintf("map[%s]bool only stores \"true\" values; consider map[%s]struct{}", key, key),
+			Object:   mi.declared(),
+		}
+		var why string
+		if diag.Edits, why = v.suggestedEdits(mi); diag.Edits != nil {
+			diag.FixMessage = fmt.Sprintf("Convert %s to map[%s]struct{}", mi.obj.Name(), key)
+		} else {
+			s.debug("no fix offered", "map", mi.name(), "reason", why)
+		}
+		for _, w := range mi.writes {
+			msg := "true stored here"
+			if v.inGoroutine(w.Pos) {
+				// Concurrent writes need the same synchronisation
+				// after the conversion.
+				msg = "true stored here, in a goroutine"
+			}
+			diag.Related = append(diag.Related, RelatedInformation{Pos: w.Pos, End: w.Value.End(), Message: msg})
+		}
+		sort.Slice(diag.Related, func(i, j int) bool { return diag.Related[i].Pos < diag.Related[j].Pos })
+		diag.Reads = sortedReads(mi.reads)
+		f := v.finding(mi, diag)
+		s.rewriteMessage(&f)
+		if s.filter != nil && !s.filter(f) {
+			s.debug("map not reported", "map", mi.name(), "reason", "dropped by Filter")
+			continue
+		}
+		for len(checked) > 0 && checked[0].Pos < f.Pos {
+			emit(checked[0])
+			checked = checked[1:]
+		}
+		emit(f)
+		reported[mi] = true
 	}
-	return diags
+	for _, f := range checked {
+		emit(f)
+	}
+	s.metrics.add(len(nodes), v, len(reported))
+	return v.result(reported), nil
+}
+
+// reportable returns the maps to report, sorted by position. Distinct
+// objects reported at the same position, such as a field of a generic type
+// and its instantiations, are reported once.
+func (a *analyzer) reportable(s *settings) []*mapInfo {
+	if !s.runs(RuleMapBoolSet) {
+		return nil
+	}
+	a.resolveComparisons(s)
+	var maps []*mapInfo
+	seen := make(map[*mapInfo]bool)
+	for _, mi := range a.results {
+		if seen[mi] {
+			// An alias of a map already listed.
+			continue
+		}
+		seen[mi] = true
+		if mi.pos == token.NoPos && mi.obj != nil {
+			mi.pos = mi.obj.Pos()
+			mi.end = mi.pos + token.Pos(len(mi.declared().Name()))
+		}
+		if mi.pos != token.NoPos {
+			maps = append(maps, mi)
+		}
+	}
+	sort.Slice(maps, func(i, j int) bool {
+		if maps[i].pos != maps[j].pos {
+			return maps[i].pos < maps[j].pos
+		}
+		// Prefer the declared object to its instantiations, then order
+		// instantiations by key type so the one kept does not vary.
+		if oi, oj := isOrigin(maps[i].obj), isOrigin(maps[j].obj); oi != oj {
+			return oi
+		}
+		return maps[i].keyType < maps[j].keyType
+	})
+	unique := maps[:0]
+	for _, mi := range maps {
+		if reason := a.suppression(s, mi); reason != "" {
+			if mi.trueCount > 0 {
+				s.debug("map not reported", "map", mi.name(), "reason", reason)
+			}
+			continue
+		}
+		if len(unique) > 0 && unique[len(unique)-1].pos == mi.pos {
+			s.debug("map not reported", "map", mi.name(), "reason", "another instantiation is reported at the same position")
+			continue
+		}
+		unique = append(unique, mi)
+	}
+	return unique
+}
+
+// suppression tells why mi is not reported, or returns "" if it is.
+func (a *analyzer) suppression(s *settings, mi *mapInfo) string {
+	switch {
+	case mi.trueCount == 0:
+		return "no true writes"
+	case !mi.onlyTrue:
+		return "stores values other than true"
+	case mi.escape != "":
+		return "escapes: " + mi.escape
+	case mi.passedIn:
+		return "its writes are reported with the maps passed to it"
+	case mi.imported && mi.upstreamWrites > 0:
+		// The defining package already reports maps it writes to itself.
+		return "reported by its defining package"
+	}
+	return s.suppression(a.pkg, mi)
+}
+
+// name returns the name of the map, for logs.
+func (mi *mapInfo) name() string {
+	if mi.obj == nil {
+		return ""
+	}
+	return mi.declared().Name()
+}
+
+// declared returns the variable or field declaring the map: obj, or the
+// holder of the maps it stands for.
+func (mi *mapInfo) declared() types.Object {
+	if mi.holder != nil {
+		return mi.holder
+	}
+	return mi.obj
+}
+
+// isOrigin reports whether obj is not an instantiation of a generic
+// object.
+func isOrigin(obj types.Object) bool {
+	v, ok := obj.(*types.Var)
+	return !ok || v.Origin() == v
+}
+
+// ErrInvalidInput is returned, wrapped, when the package, syntax or type
+// information given to the analysis cannot be analysed together.
+var ErrInvalidInput = errors.New("boolset: invalid input")
+
+// checkInput reports inputs that would make the analysis silently find
+// nothing, such as type information recorded without the maps the analysis
+// relies on.
+func checkInput(pkg *types.Package, nodes []ast.Node, info *types.Info) error {
+	switch {
+	case pkg == nil:
+		return fmt.Errorf("%w: nil package", ErrInvalidInput)
+	case info == nil:
+		return fmt.Errorf("%w: nil type information", ErrInvalidInput)
+	case info.Types == nil || info.Defs == nil || info.Uses == nil:
+		return fmt.Errorf("%w: type information lacks Types, Defs or Uses", ErrInvalidInput)
+	}
+	for _, n := range nodes {
+		file, ok := n.(*ast.File)
+		if !ok {
+			continue
+		}
+		if file == nil {
+			return fmt.Errorf("%w: nil file", ErrInvalidInput)
+		}
+		if file.Name.Name != pkg.Name() {
+			return fmt.Errorf("%w: file of package %s analysed as package %s", ErrInvalidInput, file.Name.Name, pkg.Name())
+		}
+	}
+	return nil
 }
 
 type analyzer struct {
-	pkg        *types.Package
-	info       *types.Info
-	results    map[types.Object]*mapInfo
-	qualifier  types.Qualifier
-	boolValues map[types.Object]truthState
+	pkg       *types.Package
+	info      *types.Info
+	results   map[types.Object]*mapInfo
+	qualifier types.Qualifier
+	// boolAssigns holds the assignments to each local bool variable, funcs
+	// the function bodies and cfgs their control flow graphs, built on
+	// demand, to tell whether a variable is true where a map write stores
+	// it.
+	boolAssigns map[types.Object][]boolAssign
+	// addressedBools holds the local bool variables whose address is
+	// taken, which may be assigned through pointers at any point.
+	addressedBools map[types.Object]bool
+	funcs          []*ast.BlockStmt
+	cfgs           map[*ast.BlockStmt]*cfg.CFG
+	// typeOwners counts the tracked maps declared by each map type
+	// expression; a shared expression cannot be rewritten for one map alone.
+	typeOwners map[*ast.MapType]int
+	facts      factStore
+	// ssaTruth, in SSA mode, tells whether the map update at a position
+	// stores true.
+	ssaTruth map[token.Pos]bool
+	// pointers holds the local pointers to tracked maps, and aliasedAddrs
+	// the address operations assigned to them.
+	pointers     map[types.Object]*pointerAlias
+	aliasedAddrs map[*ast.UnaryExpr]bool
+	// decls holds the function declarations of the package, and accessors
+	// the map each accessor function returns, or nil once resolved to none.
+	decls     map[*types.Func]*ast.FuncDecl
+	accessors map[*types.Func]types.Object
+	// elems holds the objects standing for the maps held by maps of maps,
+	// and holders the variable or field declaring the outermost holder of
+	// each.
+	elems   map[types.Object]types.Object
+	holders map[types.Object]types.Object
+	// mapAliases are the pairs of tracked maps assigned to one another,
+	// merged into one record once the walk is done.
+	mapAliases [][2]*mapInfo
+	// comparisons are the calls comparing tracked maps with others.
+	comparisons []comparison
+	// closures maps the local variables of function type to the function
+	// literal they are only ever assigned, or nil. valueCalls are the calls
+	// of function values, resolved into flows once the walk is done.
+	closures   map[types.Object]*ast.FuncLit
+	valueCalls []*ast.CallExpr
+	flows      []paramFlow
+	// goCalls are the functions go statements call, and deferred the
+	// bodies of the function literals defer statements call.
+	goCalls  []ast.Expr
+	deferred map[*ast.BlockStmt]bool
+	// checks are the registered checks run alongside BS0001.
+	checks []activeCheck
 }
 
 type mapInfo struct {
 	obj       types.Object
 	onlyTrue  bool
 	trueCount int
-	pos       token.Pos
-	keyType   string
+	// pos and end span where the map is reported: its name, or its first
+	// write when it is declared in another package.
+	pos     token.Pos
+	end     token.Pos
+	keyType string
+
+	// Fix bookkeeping: the map type expressions that declare or initialise
+	// the map, the stored values, and how many uses of the object were
+	// accounted for by writes and initialisations.
+	typeExprs   []*ast.MapType
+	values      []ast.Expr
+	writes      []Write
+	sites       []token.Pos
+	reads       []RelatedInformation
+	knownUses   int
+	uses        int
+	untypedInit bool
+	unfixable   bool
+	// escape tells where the map leaves the analysis' sight, if it does,
+	// and serialized how it is encoded, which changes with its element
+	// type.
+	escape     string
+	serialized string
+	// passedIn is set on the parameters of function literals tracked maps
+	// are passed to, whose writes count for those maps.
+	passedIn bool
+	// holder is the variable or field holding the maps obj stands for,
+	// when obj is the synthetic object of elemObject.
+	holder types.Object
+
+	// imported maps are declared in another package; upstreamWrites counts
+	// the true writes made there, from the package's setFact.
+	imported       bool
+	upstreamWrites int
 }
 
-func (a *analyzer) inspectFile(file *ast.File) {
+func (a *analyzer) inspectNode(root ast.Node) {
 	var stack []ast.Node
-	ast.Inspect(file, func(n ast.Node) bool {
+	ast.Inspect(root, func(n ast.Node) bool {
 		if n == nil {
 			if len(stack) > 0 {
 				stack = stack[:len(stack)-1]
@@ -81,26 +511,72 @@ func (a *analyzer) inspectFile(file *ast.File) {
 			return false
 		}
 		stack = append(stack, n)
-
-		switch node := n.(type) {
-		case *ast.AssignStmt:
-			a.handleAssign(node)
-		case *ast.CompositeLit:
-			a.handleComposite(node, stack)
-		case *ast.ValueSpec:
-			a.handleValueSpec(node)
-		}
+		a.inspect(n, stack)
 		return true
 	})
 }
 
+// inspect visits n, whose ancestors are stack, ending with n.
+func (a *analyzer) inspect(n ast.Node, stack []ast.Node) {
+	for _, c := range a.checks {
+		c.Inspect(n, stack)
+	}
+
+	switch node := n.(type) {
+	case *ast.AssignStmt:
+		a.handleAssign(node)
+	case *ast.FuncDecl:
+		if node.Body != nil {
+			a.funcs = append(a.funcs, node.Body)
+		}
+	case *ast.FuncLit:
+		a.funcs = append(a.funcs, node.Body)
+	case *ast.RangeStmt:
+		a.handleRange(node)
+		a.handleRangeElems(node)
+	case *ast.GoStmt:
+		a.goCalls = append(a.goCalls, node.Call.Fun)
+	case *ast.DeferStmt:
+		if lit, ok := ast.Unparen(node.Call.Fun).(*ast.FuncLit); ok {
+			a.deferred[lit.Body] = true
+		}
+	case *ast.CallExpr:
+		a.handleCall(node)
+	case *ast.CompositeLit:
+		a.handleComposite(node, stack)
+	case *ast.ValueSpec:
+		a.handleValueSpec(node)
+	case *ast.Field:
+		a.handleField(node, stack)
+	case *ast.StarExpr:
+		a.handleDeref(node)
+	case *ast.UnaryExpr:
+		a.handleAddress(node)
+		if node.Op == token.AND {
+			a.handleBoolAddress(node.X)
+		}
+	case *ast.SelectorExpr:
+		a.handleMethodValue(node)
+	}
+	a.handleConversions(n, stack)
+}
+
 func (a *analyzer) handleAssign(assign *ast.AssignStmt) {
 	rhsLen := len(assign.Rhs)
 	for i, lhs := range assign.Lhs {
 		idx, ok := lhs.(*ast.IndexExpr)
 		rhsExpr := exprAt(assign.Rhs, rhsLen, i)
+		if rhsLen == len(assign.Lhs) {
+			a.trackVarAssignment(lhs, rhsExpr, assign.Tok, assign.End())
+		} else {
+			// The values of a multi-value call or comma-ok expression
+			// are unknown.
+			a.trackVarAssignment(lhs, nil, assign.Tok, assign.End())
+		}
 		if rhsExpr != nil {
-			a.trackVarAssignment(lhs, rhsExpr, assign.Tok)
+			a.trackMapInit(lhs, rhsExpr)
+			a.trackPointer(lhs, rhsExpr)
+			a.trackClosure(lhs, rhsExpr)
 		}
 		if !ok || assign.Tok != token.ASSIGN || rhsExpr == nil {
 			continue
@@ -110,7 +586,10 @@ func (a *analyzer) handleAssign(assign *ast.AssignStmt) {
 		if info == nil {
 			continue
 		}
-		info.recordAssignment(a, rhsExpr, idx.Pos())
+		if a.usesObject(idx.X, obj) {
+			info.knownUses++
+		}
+		info.recordAssignment(a, rhsExpr, idx, idx.Lbrack)
 	}
 }
 
@@ -119,6 +598,10 @@ func (a *analyzer) handleComposite(lit *ast.CompositeLit, stack []ast.Node) {
 	if !ok || tv.Type == nil {
 		return
 	}
+	if _, ok := typeUnderlying(tv.Type).(*types.Struct); ok {
+		a.handleStructFields(lit)
+		return
+	}
 	m, ok := tv.Type.Underlying().(*types.Map)
 	if !ok || !isBool(m.Elem()) {
 		return
@@ -135,24 +618,60 @@ func (a *analyzer) handleComposite(lit *ast.CompositeLit, stack []ast.Node) {
 		if !ok {
 			continue
 		}
-		info.recordAssignment(a, kv.Value, kv.Value.Pos())
+		info.recordAssignment(a, kv.Value, kv.Value, kv.Colon)
+	}
+}
+
+// handleStructFields records the initial values of the tracked map fields
+// a struct literal sets, such as S{set: map[string]bool{}}.
+func (a *analyzer) handleStructFields(lit *ast.CompositeLit) {
+	for _, elt := range lit.Elts {
+		mi := a.infoFor(a.fieldOf(lit, elt))
+		if mi == nil {
+			continue
+		}
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			mi.knownUses++
+			elt = kv.Value
+		}
+		mi.recordInit(a, elt)
 	}
 }
 
+// fieldOf returns the field of the struct literal lit that its element elt
+// sets, or nil.
+func (a *analyzer) fieldOf(lit *ast.CompositeLit, elt ast.Expr) *types.Var {
+	t, ok := typeUnderlying(a.info.TypeOf(lit)).(*types.Struct)
+	if !ok {
+		return nil
+	}
+	if kv, ok := elt.(*ast.KeyValueExpr); ok {
+		if key, ok := kv.Key.(*ast.Ident); ok {
+			field, _ := a.info.Uses[key].(*types.Var)
+			return field
+		}
+		return nil
+	}
+	for i, e := range lit.Elts {
+		if e == elt && i < t.NumFields() {
+			return t.Field(i)
+		}
+	}
+	return nil
+}
+
 func (a *analyzer) handleValueSpec(spec *ast.ValueSpec) {
 	namesLen := len(spec.Names)
 	if namesLen == 0 {
 		return
 	}
-	valuesLen := len(spec.Values)
-	if valuesLen == 0 {
-		return
+	if spec.Type != nil {
+		for _, name := range spec.Names {
+			a.recordTypeExprs(a.info.Defs[name], spec.Type)
+		}
 	}
+	valuesLen := len(spec.Values)
 	for i, name := range spec.Names {
-		rhs := exprAt(spec.Values, valuesLen, i)
-		if rhs == nil {
-			continue
-		}
 		obj := a.info.Defs[name]
 		if obj == nil {
 			obj = a.info.Uses[name]
@@ -160,7 +679,22 @@ func (a *analyzer) handleValueSpec(spec *ast.ValueSpec) {
 		if obj == nil {
 			continue
 		}
-		a.recordVarAssignment(obj, rhs)
+		// Without values the variable holds its zero value, and the
+		// values of a multi-value call are unknown.
+		var value ast.Expr
+		if valuesLen == namesLen {
+			value = spec.Values[i]
+		}
+		a.recordVarAssignment(obj, value, spec.End())
+		rhs := exprAt(spec.Values, valuesLen, i)
+		if rhs == nil {
+			continue
+		}
+		if mi := a.trackedMap(obj); mi != nil {
+			mi.recordInit(a, rhs)
+		}
+		a.trackPointer(name, rhs)
+		a.trackClosure(name, rhs)
 	}
 }
 
@@ -168,8 +702,8 @@ func (a *analyzer) infoFor(obj types.Object) *mapInfo {
 	if obj == nil {
 		return nil
 	}
-	if pkg := obj.Pkg(); pkg != nil && pkg != a.pkg {
-		return nil
+	if mi, ok := a.results[obj]; ok {
+		return mi
 	}
 
 	typ := obj.Type()
@@ -180,15 +714,25 @@ func (a *analyzer) infoFor(obj types.Object) *mapInfo {
 	if !ok || !isBool(m.Elem()) {
 		return nil
 	}
-
-	if mi, ok := a.results[obj]; ok {
+	// Key types from unresolved imports cannot be reported meaningfully.
+	if hasInvalid(m.Key()) {
+		return nil
+	}
+	if pkg := obj.Pkg(); pkg != nil && pkg != a.pkg {
+		return a.importedInfo(obj, m)
+	}
+	if tn := a.setType(typ); tn != nil && tn != obj {
+		mi := a.infoFor(tn)
+		a.results[obj] = mi
 		return mi
 	}
+
 	keyType := types.TypeString(m.Key(), a.qualifier)
 	mi := &mapInfo{
 		obj:      obj,
 		onlyTrue: true,
 		pos:      obj.Pos(),
+		end:      obj.Pos() + token.Pos(len(obj.Name())),
 		keyType:  keyType,
 	}
 	a.results[obj] = mi
@@ -207,6 +751,12 @@ func (a *analyzer) mapObject(expr ast.Expr) types.Object {
 			return sel.Obj()
 		}
 		return a.info.Uses[e.Sel]
+	case *ast.StarExpr:
+		return a.pointee(e.X)
+	case *ast.IndexExpr:
+		return a.elemObject(a.mapObject(e.X))
+	case *ast.CallExpr:
+		return a.accessed(e)
 	case *ast.ParenExpr:
 		return a.mapObject(e.X)
 	default:
@@ -249,6 +799,29 @@ func (a *analyzer) objectForComposite(lit *ast.CompositeLit, stack []ast.Node) t
 			return nil
 		}
 		return a.objectOfAssignable(lhs)
+	case *ast.KeyValueExpr:
+		// A field value of a struct literal, possibly itself the
+		// value of a field of an enclosing one.
+		outer, ok := stack[len(stack)-3].(*ast.CompositeLit)
+		if !ok || p.Value != lit {
+			break
+		}
+		if field := a.fieldOf(outer, p); field != nil {
+			return field
+		}
+		// A value of a map literal, held by the literal's object.
+		return a.elemObject(a.objectForComposite(outer, stack[:len(stack)-2]))
+	case *ast.CompositeLit:
+		if field := a.fieldOf(p, lit); field != nil {
+			return field
+		}
+		// An element of a slice or array literal.
+		return a.elemObject(a.objectForComposite(p, stack[:len(stack)-1]))
+	case *ast.CallExpr:
+		// A map appended to a slice.
+		if a.builtin(p) == "append" && len(p.Args) > 1 && p.Args[0] != lit {
+			return a.elemObject(a.mapObject(ast.Unparen(p.Args[0])))
+		}
 	}
 	return nil
 }
@@ -265,6 +838,10 @@ func (a *analyzer) objectOfAssignable(expr ast.Expr) types.Object {
 			return sel.Obj()
 		}
 		return a.info.Uses[e.Sel]
+	case *ast.StarExpr:
+		return a.pointee(e.X)
+	case *ast.IndexExpr:
+		return a.elemObject(a.mapObject(e.X))
 	case *ast.ParenExpr:
 		return a.objectOfAssignable(e.X)
 	default:
@@ -272,18 +849,21 @@ func (a *analyzer) objectOfAssignable(expr ast.Expr) types.Object {
 	}
 }
 
-func (mi *mapInfo) recordAssignment(a *analyzer, rhs ast.Expr, pos token.Pos) {
+// recordAssignment records that rhs is stored into the map by the write at,
+// whose SSA map update is at site.
+func (mi *mapInfo) recordAssignment(a *analyzer, rhs ast.Expr, at ast.Node, site token.Pos) {
 	if mi == nil {
 		return
 	}
+	pos := at.Pos()
 	if mi.pos == token.NoPos && pos.IsValid() {
-		mi.pos = pos
+		mi.pos, mi.end = pos, at.End()
 	}
-	if a.isDefinitelyTrue(rhs) {
-		mi.trueCount++
-		return
-	}
-	mi.onlyTrue = false
+	mi.values = append(mi.values, rhs)
+	// Whether rhs is true depends on assignments that may follow in the
+	// source; resolveWrites decides once the walk is done.
+	mi.writes = append(mi.writes, Write{Pos: pos, Value: rhs})
+	mi.sites = append(mi.sites, site)
 }
 
 func isBool(t types.Type) bool {
@@ -291,6 +871,24 @@ func isBool(t types.Type) bool {
 	return ok && basic.Kind() == types.Bool
 }
 
+func hasInvalid(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.Basic:
+		return t.Kind() == types.Invalid
+	case *types.Pointer:
+		return hasInvalid(t.Elem())
+	case *types.Array:
+		return hasInvalid(t.Elem())
+	case *types.Slice:
+		return hasInvalid(t.Elem())
+	case *types.Chan:
+		return hasInvalid(t.Elem())
+	case *types.Map:
+		return hasInvalid(t.Key()) || hasInvalid(t.Elem())
+	}
+	return false
+}
+
 func exprAt(list []ast.Expr, length, index int) ast.Expr {
 	if index < length {
 		return list[index]
@@ -316,132 +914,81 @@ func makeQualifier(pkg *types.Package) types.Qualifier {
 	}
 }
 
-type truthState int
-
-const (
-	truthUnknown truthState = iota
-	truthAlwaysTrue
-	truthNotAlwaysTrue
-)
-
-func (a *analyzer) trackVarAssignment(lhs ast.Expr, rhs ast.Expr, tok token.Token) {
-	ident, ok := lhs.(*ast.Ident)
-	if !ok {
-		return
-	}
-	if ident.Name == "_" {
-		return
-	}
-	var obj types.Object
-	if tok == token.DEFINE {
-		obj = a.info.Defs[ident]
-	} else {
-		obj = a.info.Uses[ident]
-	}
-	if obj == nil {
-		obj = a.info.Defs[ident]
-	}
-	if obj == nil {
-		return
-	}
-	a.recordVarAssignment(obj, rhs)
+// Analyzer is the boolset analyzer, for drivers such as Bazel's nogo that
+// reference analyzers by package variable. It holds no mutable state; each
+// run keeps its bookkeeping local, so one instance may analyse many packages
+// concurrently.
+var Analyzer = NewAnalyzer()
+
+// NewAnalyzer returns a new analyzer instance for the boolset linter. The
+// analyzer requires inspect.Analyzer, sharing its inspector with the other
+// analyzers a driver runs, and returns a *Result describing the
+// package's map[T]bool usage to analyzers that require it. It exports a
+// fact for package-level maps visible to other packages, so writes from
+// dependent packages are attributed to the map they populate.
+//
+// Each instance has its own settings, registered as flags on
+// Analyzer.Flags so every driver can configure it: -min-writes,
+// -include-fields, -include-globals, -exclude-key-types, -ssa and
+// -include-serialized.
+func NewAnalyzer() *analysis.Analyzer {
+	s := defaultSettings()
+	a := &analysis.Analyzer{
+		Name: "boolset",
+		Doc:  "reports map[T]bool values that only store \"true\" and should be map[T]struct{}",
+		URL:  docURL,
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			return runAnalyzer(pass, &s)
+		},
+		Requires:   []*analysis.Analyzer{inspect.Analyzer},
+		FactTypes:  []analysis.Fact{new(setFact)},
+		ResultType: resultType,
+	}
+	s.register(&a.Flags)
+	return a
 }
 
-func (a *analyzer) recordVarAssignment(obj types.Object, rhs ast.Expr) {
-	v, ok := obj.(*types.Var)
-	if !ok {
-		return
+// Analyzers returns new instances of every analyzer provided by this package,
+// for drivers that run them together such as multichecker, unitchecker and
+// golangci-lint. Callers may modify the returned analyzers.
+func Analyzers() []*analysis.Analyzer {
+	return []*analysis.Analyzer{
+		NewAnalyzer(),
 	}
-	if !isBool(v.Type()) {
-		return
-	}
-	if !a.isLocalVar(v) {
-		return
-	}
-	if a.boolValues == nil {
-		a.boolValues = make(map[types.Object]truthState)
-	}
-	if a.isDefinitelyTrue(rhs) {
-		if a.boolValues[obj] == truthUnknown {
-			a.boolValues[obj] = truthAlwaysTrue
-		}
-		return
-	}
-	a.boolValues[obj] = truthNotAlwaysTrue
 }
 
-func (a *analyzer) isDefinitelyTrue(expr ast.Expr) bool {
-	if expr == nil {
-		return false
-	}
-	if tv, ok := a.info.Types[expr]; ok && tv.Value != nil {
-		if tv.Value.Kind() == constant.Bool {
-			return constant.BoolVal(tv.Value)
+func runAnalyzer(pass *analysis.Pass, s *settings) (interface{}, error) {
+	var facts factStore
+	// Drivers without fact support leave the fact functions unset.
+	if pass.ImportObjectFact != nil && pass.ExportObjectFact != nil {
+		facts = passFacts{pass}
+	}
+	// Drivers that run requirements provide the inspector; others, and
+	// callers building a Pass by hand, may not.
+	in, _ := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	findings, result, err := analyze(context.Background(), pass.Pkg, pass.Files, pass.TypesInfo, in, s, facts)
+	if err != nil {
+		return nil, err
+	}
+	for _, diag := range diagnostics(findings) {
+		report := analysis.Diagnostic{
+			Pos:      diag.Pos,
+			End:      diag.End,
+			Category: diag.Rule,
+			Message:  diag.Message,
+			URL:      diag.URL,
 		}
-	}
-	switch e := expr.(type) {
-	case *ast.Ident:
-		if obj := a.info.Uses[e]; obj != nil {
-			return a.objectIsDefinitelyTrue(obj)
+		for _, r := range diag.Related {
+			report.Related = append(report.Related, analysis.RelatedInformation{Pos: r.Pos, End: r.End, Message: r.Message})
 		}
-		if obj := a.info.Defs[e]; obj != nil {
-			return a.objectIsDefinitelyTrue(obj)
-		}
-	case *ast.ParenExpr:
-		return a.isDefinitelyTrue(e.X)
-	}
-	return false
-}
-
-func (a *analyzer) objectIsDefinitelyTrue(obj types.Object) bool {
-	switch o := obj.(type) {
-	case *types.Const:
-		if v := o.Val(); v != nil && v.Kind() == constant.Bool {
-			return constant.BoolVal(v)
-		}
-	case *types.Var:
-		if a.boolValues != nil && a.boolValues[obj] == truthAlwaysTrue {
-			return true
+		if len(diag.Edits) > 0 {
+			fix := analysis.SuggestedFix{Message: diag.FixMessage}
+			for _, e := range diag.Edits {
+				fix.TextEdits = append(fix.TextEdits, analysis.TextEdit{Pos: e.Pos, End: e.End, NewText: []byte(e.NewText)})
+			}
+			report.SuggestedFixes = []analysis.SuggestedFix{fix}
 		}
+		pass.Report(report)
 	}
-	return false
-}
-
-func (a *analyzer) isLocalVar(v *types.Var) bool {
-	if v == nil {
-		return false
-	}
-	if v.IsField() {
-		return false
-	}
-	scope := v.Parent()
-	if scope == nil {
-		return false
-	}
-	pkg := v.Pkg()
-	if pkg != nil && scope == pkg.Scope() {
-		return false
-	}
-	// Parameters live in func scope but initial value unknown; skip them.
-	if v.Pos() == token.NoPos {
-		return false
-	}
-	return true
-}
-
-// NewAnalyzer returns a new analyzer instance for the boolset linter.
-func NewAnalyzer() *analysis.Analyzer {
-	return &analysis.Analyzer{
-		Name: "boolset",
-		Doc:  "reports map[T]bool values that only store \"true\" and should be map[T]struct{}",
-		Run:  runAnalyzer,
-	}
-}
-
-func runAnalyzer(pass *analysis.Pass) (interface{}, error) {
-	diagnostics := Analyze(pass.Pkg, pass.Files, pass.TypesInfo)
-	for _, diag := range diagnostics {
-		pass.Reportf(diag.Pos, "%s", diag.Message)
-	}
-	return nil, nil
+	return result, nil
 }
@@ -1,15 +1,33 @@
 package boolset
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"go/ast"
 	"go/importer"
 	"go/parser"
 	"go/token"
 	"go/types"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime/debug"
+	"slices"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"text/template"
 
 	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
 )
 
 const diagMsg = "map[string]bool only stores \"true\" values; consider map[string]struct{}"
@@ -157,208 +175,4360 @@ func TestAnalyzer(t *testing.T) {
 			wantMsgs: nil,
 		},
 		{
-			name: "global true variable not trusted",
+			name: "local variable reassigned false after use",
 			src: `package p
 
-				var alwaysTrue = true
-				
 				func f() {
+					flag := true
 					set := make(map[string]bool)
-					set["a"] = alwaysTrue
+					set["a"] = flag
+					flag = false
+					_ = flag
 				}
 				`,
-			wantMsgs: nil,
+			wantMsgs: []string{diagMsg},
 		},
 		{
-			name: "tuple assignment all true",
+			name: "local variable reassigned true before use",
 			src: `package p
 
 				func f() {
+					flag := false
+					flag = true
 					set := make(map[string]bool)
-					set["a"], set["b"] = true, true
+					set["a"] = flag
 				}
 				`,
 			wantMsgs: []string{diagMsg},
 		},
 		{
-			name: "tuple assignment with false",
+			name: "local variable reassigned false later in loop",
 			src: `package p
 
-				func f() {
+				func f(keys []string) {
+					flag := true
 					set := make(map[string]bool)
-					set["a"], set["b"] = true, false
+					for _, k := range keys {
+						set[k] = flag
+						flag = false
+					}
 				}
 				`,
 			wantMsgs: nil,
 		},
 		{
-			name: "constant folded true expression",
+			name: "local variable assigned by range",
 			src: `package p
 
-				func f() {
+				func f(flags []bool) {
+					flag := true
+					for _, flag = range flags {
+					}
 					set := make(map[string]bool)
-					set["a"] = 1 < 2
-					set["b"] = !false
+					set["a"] = flag
 				}
 				`,
-			wantMsgs: []string{diagMsg},
+			wantMsgs: nil,
 		},
 		{
-			name: "type alias map",
+			name: "local variable assigned by comma-ok",
 			src: `package p
 
-				type stringSet map[string]bool
-				
-				func f() {
-					set := make(stringSet)
-					set["a"] = true
+				func f(m map[string]int) {
+					flag := true
+					_, flag = m["a"]
+					set := make(map[string]bool)
+					set["a"] = flag
 				}
 				`,
-			wantMsgs: []string{diagMsg},
+			wantMsgs: nil,
 		},
 		{
-			name: "chained true locals",
+			name: "captured variable reassigned false between calls",
 			src: `package p
 
 				func f() {
 					flag := true
-					alias := flag
 					set := make(map[string]bool)
-					set["a"] = alias
+					add := func(k string) { set[k] = flag }
+					add("a")
+					flag = false
+					add("b")
 				}
 				`,
-			wantMsgs: []string{diagMsg},
+			wantMsgs: nil,
 		},
 		{
-			name: "loop assignments",
+			name: "captured variable reassigned false by another closure",
 			src: `package p
 
-				func f() {
-					keys := []string{"a", "b"}
+				func f(keys []string) {
+					flag := true
+					reset := func() { flag = false }
 					set := make(map[string]bool)
 					for _, k := range keys {
-						set[k] = true
+						set[k] = flag
+						reset()
 					}
 				}
 				`,
-			wantMsgs: []string{diagMsg},
+			wantMsgs: nil,
 		},
 		{
-			name: "nested map only true values",
+			name: "closure with its own true variable",
 			src: `package p
 
 				func f() {
-					sets := map[string]map[string]bool{}
-					sets["first"] = make(map[string]bool)
-					inner := sets["first"]
-					inner["a"] = true
+					set := make(map[string]bool)
+					func() {
+						ok := true
+						set["a"] = ok
+					}()
 				}
 				`,
 			wantMsgs: []string{diagMsg},
 		},
 		{
-			name: "nested map includes false",
+			name: "same variable name in another function",
 			src: `package p
 
 				func f() {
-					sets := map[string]map[string]bool{}
-					sets["first"] = make(map[string]bool)
-					inner := sets["first"]
-					inner["a"] = true
-					inner["b"] = false
+					flag := true
+					set := make(map[string]bool)
+					set["a"] = flag
+				}
+
+				func g() bool {
+					flag := true
+					flag = false
+					return flag
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "local variable set true on one branch only",
+			src: `package p
+
+				func f(c bool) {
+					var flag bool
+					if c {
+						flag = true
+					}
+					set := make(map[string]bool)
+					set["a"] = flag
 				}
 				`,
 			wantMsgs: nil,
 		},
 		{
-			name: "pointer dereference assignment not reported",
+			name: "local variable set false on the other branch",
 			src: `package p
 
-				func f() {
+				func f(c bool) {
+					var flag bool
+					if c {
+						flag = false
+					} else {
+						flag = true
+					}
 					set := make(map[string]bool)
-					setPtr := &set
-					(*setPtr)["a"] = true
+					set["a"] = flag
 				}
 				`,
 			wantMsgs: nil,
 		},
 		{
-			name: "assignment of function call result not reported",
+			name: "local variable set true on every branch",
 			src: `package p
 
-				func f() {
+				func f(c bool) {
+					var flag bool
+					if c {
+						flag = true
+					} else {
+						flag = !false
+					}
 					set := make(map[string]bool)
-					set["a"] = g()
+					set["a"] = flag
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "false assignment on a branch that returns",
+			src: `package p
+
+				func f(c bool) {
+					flag := true
+					if c {
+						flag = false
+						_ = flag
+						return
+					}
+					set := make(map[string]bool)
+					set["a"] = flag
 				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "delete does not disqualify",
+			src: `package p
 
-				func g() bool {
-					return true
+				type S struct {
+					set map[string]bool
+				}
+
+				func (s *S) add(k string) { s.set[k] = true }
+
+				func (s *S) remove(k string) { delete(s.set, k) }
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "clear does not disqualify",
+			src: `package p
+
+				var seen = map[string]bool{}
+
+				func mark(k string) { seen[k] = true }
+
+				func reset() { clear(seen) }
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "re-made map",
+			src: `package p
+
+				func f(batches [][]string) {
+					var seen map[string]bool
+					for _, batch := range batches {
+						seen = make(map[string]bool, len(batch))
+						for _, k := range batch {
+							seen[k] = true
+						}
+						seen = nil
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "copy from a true-only map",
+			src: `package p
+
+				func f(keys []string) {
+					src := make(map[string]bool)
+					for _, k := range keys {
+						src[k] = true
+					}
+					dst := make(map[string]bool)
+					for k := range src {
+						dst[k] = src[k]
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg, diagMsg},
+		},
+		{
+			name: "bool assigned through a pointer",
+			src: `package p
+
+				func f(k string) {
+					flag := true
+					p := &flag
+					*p = false
+					set := map[string]bool{}
+					set[k] = flag
 				}
 				`,
 			wantMsgs: nil,
 		},
-	}
+		{
+			name: "bool passed by address",
+			src: `package p
 
-	for _, tc := range tests {
-		tc := tc
-		t.Run(tc.name, func(t *testing.T) {
-			diags := runNewAnalyzer(t, tc.src)
-			if len(diags) != len(tc.wantMsgs) {
-				t.Fatalf("expected %d diagnostics, got %d", len(tc.wantMsgs), len(diags))
-			}
-			sort.Strings(diags)
-			sort.Strings(tc.wantMsgs)
-			for i, msg := range tc.wantMsgs {
-				if diags[i] != msg {
-					t.Fatalf("unexpected diagnostic %q, want %q", diags[i], msg)
+				func mutate(b *bool) { *b = false }
+
+				func f(k string) {
+					flag := true
+					mutate(&flag)
+					set := map[string]bool{}
+					set[k] = flag
 				}
-			}
-		})
-	}
-}
+				`,
+			wantMsgs: nil,
+		},
+		{
+			name: "bool with a pointer method called",
+			src: `package p
 
-func runNewAnalyzer(t *testing.T, src string) []string {
-	t.Helper()
+				type toggle bool
 
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
-	if err != nil {
-		t.Fatalf("parse error: %v", err)
-	}
+				func (t *toggle) flip() { *t = !*t }
 
-	files := []*ast.File{file}
-	info := &types.Info{
-		Types:      make(map[ast.Expr]types.TypeAndValue),
-		Defs:       make(map[*ast.Ident]types.Object),
-		Uses:       make(map[*ast.Ident]types.Object),
-		Selections: make(map[*ast.SelectorExpr]*types.Selection),
-	}
+				func f(k string) {
+					on := toggle(true)
+					on.flip()
+					set := map[string]toggle{}
+					set[k] = on
+				}
+				`,
+			wantMsgs: nil,
+		},
+		{
+			name: "copy from a true-only map for keys it may lack",
+			src: `package p
 
-	conf := types.Config{
-		Importer: importer.Default(),
-		Error:    func(err error) {},
-	}
-	pkg, err := conf.Check(file.Name.Name, fset, files, info)
-	if err != nil && pkg == nil {
-		t.Fatalf("type check error: %v", err)
-	}
+				func f(keys []string, x string) bool {
+					src := map[string]bool{"a": true}
+					dst := make(map[string]bool)
+					for _, k := range keys {
+						dst[k] = src[k]
+					}
+					if v := dst[x]; v {
+						return true
+					}
+					return false
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "copy from a true-only map guarded by a lookup",
+			src: `package p
 
-	var messages []string
-	pass := &analysis.Pass{
-		Analyzer:  NewAnalyzer(),
-		Fset:      fset,
-		Files:     files,
-		Pkg:       pkg,
-		TypesInfo: info,
-		Report: func(diag analysis.Diagnostic) {
-			messages = append(messages, diag.Message)
+				func f(keys []string) {
+					src := map[string]bool{"a": true}
+					dst := make(map[string]bool)
+					for _, k := range keys {
+						if _, ok := src[k]; ok {
+							dst[k] = src[k]
+						}
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg, diagMsg},
 		},
-	}
+		{
+			name: "copy through a variable",
+			src: `package p
 
-	_, err = NewAnalyzer().Run(pass)
-	if err != nil {
-		t.Fatalf("analyzer run error: %v", err)
-	}
+				func f(keys []string) {
+					src := map[string]bool{"a": true}
+					dst := make(map[string]bool)
+					for k := range src {
+						v := src[k]
+						dst[k] = v
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg, diagMsg},
+		},
+		{
+			name: "copies between maps only storing true",
+			src: `package p
 
-	return messages
+				func f(k string) {
+					a := map[string]bool{"a": true}
+					b := map[string]bool{"b": true}
+					if b[k] {
+						a[k] = b[k]
+					}
+					if _, ok := a[k]; ok {
+						b[k] = a[k]
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg, diagMsg},
+		},
+		{
+			name: "range copy",
+			src: `package p
+
+				func f(keys []string) {
+					src := make(map[string]bool)
+					for _, k := range keys {
+						src[k] = true
+					}
+					dst := make(map[string]bool)
+					for k, v := range src {
+						dst[k] = v
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg, diagMsg},
+		},
+		{
+			name: "range copy with an outer variable",
+			src: `package p
+
+				func f(src map[string]bool) {
+					src["a"] = true
+					dst := make(map[string]bool)
+					var v bool
+					var k string
+					for k, v = range src {
+						dst[k] = v
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg, diagMsg},
+		},
+		{
+			name: "range over keys only",
+			src: `package p
+
+				func f(src map[string]bool) {
+					dst := make(map[string]bool)
+					for k := range src {
+						dst[k] = true
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "range copy from a map storing false not reported",
+			src: `package p
+
+				func f(src map[string]bool) {
+					src["a"] = false
+					dst := make(map[string]bool)
+					for k, v := range src {
+						dst[k] = v
+					}
+				}
+				`,
+		},
+		{
+			name: "range over a bool slice not reported",
+			src: `package p
+
+				func f(flags []bool) {
+					dst := make(map[int]bool)
+					for i, v := range flags {
+						dst[i] = v
+					}
+				}
+				`,
+		},
+		{
+			name: "maps.Copy from a true-only map",
+			src: `package p
+
+				import "maps"
+
+				func f(k string) {
+					src := map[string]bool{"a": true}
+					dst := make(map[string]bool)
+					dst[k] = true
+					maps.Copy(dst, src)
+				}
+				`,
+			wantMsgs: []string{diagMsg, diagMsg},
+		},
+		{
+			name: "maps.Copy from a map storing false not reported",
+			src: `package p
+
+				import "maps"
+
+				func f(k string) {
+					src := map[string]bool{"a": false}
+					dst := make(map[string]bool)
+					dst[k] = true
+					maps.Copy(dst, src)
+				}
+				`,
+		},
+		{
+			name: "maps.Copy from an untracked map not reported",
+			src: `package p
+
+				import "maps"
+
+				func src() map[string]bool { return nil }
+
+				func f(k string) {
+					dst := make(map[string]bool)
+					dst[k] = true
+					maps.Copy(dst, src())
+				}
+				`,
+		},
+		{
+			name: "maps.Clone of a true-only map",
+			src: `package p
+
+				import "maps"
+
+				func f(k string) {
+					src := map[string]bool{"a": true}
+					dst := maps.Clone(src)
+					dst[k] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg, diagMsg},
+		},
+		{
+			name: "maps.Clone of a map storing false not reported",
+			src: `package p
+
+				import "maps"
+
+				func f(k string) {
+					src := map[string]bool{"a": false}
+					var dst = maps.Clone(src)
+					dst[k] = true
+				}
+				`,
+		},
+		{
+			name: "copy from a map storing false not reported",
+			src: `package p
+
+				func f(keys []string) {
+					src := map[string]bool{"a": true, "b": false}
+					dst := make(map[string]bool)
+					for _, k := range keys {
+						dst[k] = src[k]
+					}
+				}
+				`,
+		},
+		{
+			name: "copy from an unwritten map not reported",
+			src: `package p
+
+				func f(keys []string) {
+					src := make(map[string]bool)
+					dst := make(map[string]bool)
+					for _, k := range keys {
+						dst[k] = src[k]
+					}
+				}
+				`,
+		},
+		{
+			name: "passed to another package not reported",
+			src: `package p
+
+				import "encoding/json"
+
+				func f(k string) ([]byte, error) {
+					set := make(map[string]bool)
+					set[k] = true
+					return json.Marshal(set)
+				}
+				`,
+		},
+		{
+			name: "address passed to another package's method not reported",
+			src: `package p
+
+				import (
+					"encoding/json"
+					"io"
+				)
+
+				func f(w io.Writer, k string) error {
+					set := make(map[string]bool)
+					set[k] = true
+					return json.NewEncoder(w).Encode(&set)
+				}
+				`,
+		},
+		{
+			name: "value passed to another package",
+			src: `package p
+
+				import "strconv"
+
+				func f(k string) string {
+					set := make(map[string]bool)
+					set[k] = true
+					return strconv.FormatBool(set[k])
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "passed to a function of the package",
+			src: `package p
+
+				func size(m map[string]bool) int { return len(m) }
+
+				func f(k string) int {
+					set := make(map[string]bool)
+					set[k] = true
+					return size(set)
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "assigned to an interface variable not reported",
+			src: `package p
+
+				func f(k string) any {
+					set := make(map[string]bool)
+					set[k] = true
+					var v any
+					v = set
+					return v
+				}
+				`,
+		},
+		{
+			name: "declared as an interface not reported",
+			src: `package p
+
+				func f(k string) {
+					set := make(map[string]bool)
+					set[k] = true
+					var v interface{} = set
+					_ = v
+				}
+				`,
+		},
+		{
+			name: "stored in an interface field not reported",
+			src: `package p
+
+				type box struct{ v any }
+
+				func f(k string) box {
+					set := make(map[string]bool)
+					set[k] = true
+					return box{v: set}
+				}
+				`,
+		},
+		{
+			name: "passed as a variadic interface argument not reported",
+			src: `package p
+
+				func log(args ...interface{}) {}
+
+				func f(k string) {
+					set := make(map[string]bool)
+					set[k] = true
+					log("set", set)
+				}
+				`,
+		},
+		{
+			name: "returned as an interface not reported",
+			src: `package p
+
+				func f(k string) any {
+					set := make(map[string]bool)
+					set[k] = true
+					return set
+				}
+				`,
+		},
+		{
+			name: "converted to an interface not reported",
+			src: `package p
+
+				func f(k string) []any {
+					set := make(map[string]bool)
+					set[k] = true
+					return []any{any(set)}
+				}
+				`,
+		},
+		{
+			name: "sent as an interface not reported",
+			src: `package p
+
+				func f(ch chan<- any, k string) {
+					set := make(map[string]bool)
+					set[k] = true
+					ch <- set
+				}
+				`,
+		},
+		{
+			name: "passed to a generic function",
+			src: `package p
+
+				func keep[T any](v T) T { return v }
+
+				func f(k string) {
+					set := make(map[string]bool)
+					set[k] = true
+					keep(set)
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "value stored as an interface",
+			src: `package p
+
+				func f(k string) any {
+					set := make(map[string]bool)
+					set[k] = true
+					return set[k]
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "field with a json tag not reported",
+			src: `package p
+
+				type Config struct {
+					Features map[string]bool ` + "`json:\"features\"`" + `
+				}
+
+				func (c *Config) enable(k string) { c.Features[k] = true }
+				`,
+		},
+		{
+			name: "field of an encoded struct not reported",
+			src: `package p
+
+				import "encoding/xml"
+
+				type Config struct {
+					Features map[string]bool
+				}
+
+				func save(c Config) ([]byte, error) {
+					c.Features["a"] = true
+					return xml.Marshal(&c)
+				}
+				`,
+		},
+		{
+			name: "field of a struct encoded in a slice not reported",
+			src: `package p
+
+				import "encoding/json"
+
+				type inner struct{ Features map[string]bool }
+
+				type outer struct{ inner }
+
+				func save(cs []outer) ([]byte, error) {
+					cs[0].Features["a"] = true
+					return json.Marshal(cs)
+				}
+				`,
+		},
+		{
+			name: "field excluded from encoding",
+			src: `package p
+
+				type Config struct {
+					Name     string          ` + "`json:\"name\"`" + `
+					features map[string]bool ` + "`json:\"-\"`" + `
+				}
+
+				func (c *Config) enable(k string) { c.features[k] = true }
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "unexported field of an encoded struct",
+			src: `package p
+
+				import "encoding/json"
+
+				type Config struct {
+					Name     string
+					features map[string]bool
+				}
+
+				func save(c *Config) ([]byte, error) {
+					c.features["a"] = true
+					return json.Marshal(c)
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "decoded into not reported",
+			src: `package p
+
+				import "encoding/json"
+
+				func load(data []byte) (map[string]bool, error) {
+					set := map[string]bool{"a": true}
+					err := json.Unmarshal(data, &set)
+					return set, err
+				}
+				`,
+		},
+		{
+			name: "logged not reported",
+			src: `package p
+
+				import "log"
+
+				func f(k string) {
+					set := make(map[string]bool)
+					set[k] = true
+					log.Printf("seen %v", set)
+				}
+				`,
+		},
+		{
+			name: "field of a printed struct not reported",
+			src: `package p
+
+				import "fmt"
+
+				type state struct {
+					seen map[string]bool
+				}
+
+				func (s *state) String() string {
+					s.seen["a"] = true
+					return fmt.Sprintf("%+v", *s)
+				}
+				`,
+		},
+		{
+			name: "printed by a test not reported",
+			src: `package p
+
+				import "testing"
+
+				func TestSeen(t *testing.T) {
+					got := map[string]bool{}
+					got["a"] = true
+					t.Errorf("got %v", got)
+				}
+				`,
+		},
+		{
+			name: "printed value",
+			src: `package p
+
+				import "fmt"
+
+				func f(k string) {
+					set := make(map[string]bool)
+					set[k] = true
+					fmt.Println(set[k], len(set))
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "passed to reflect not reported",
+			src: `package p
+
+				import "reflect"
+
+				func f(k string) reflect.Type {
+					set := make(map[string]bool)
+					set[k] = true
+					return reflect.TypeOf(set)
+				}
+				`,
+		},
+		{
+			name: "field of a struct passed to reflect not reported",
+			src: `package p
+
+				import "reflect"
+
+				type registry struct {
+					names map[string]bool
+				}
+
+				func (r *registry) add(k string) int {
+					r.names[k] = true
+					return reflect.ValueOf(r).Elem().NumField()
+				}
+				`,
+		},
+		{
+			name: "compared with another true-only map",
+			src: `package p
+
+				import "reflect"
+
+				func f(keys []string) bool {
+					got := map[string]bool{}
+					for _, k := range keys {
+						got[k] = true
+					}
+					want := map[string]bool{"a": true}
+					return reflect.DeepEqual(got, want)
+				}
+				`,
+			wantMsgs: []string{diagMsg, diagMsg},
+		},
+		{
+			name: "compared with a map storing false not reported",
+			src: `package p
+
+				import "maps"
+
+				func f(keys []string) bool {
+					got := map[string]bool{}
+					for _, k := range keys {
+						got[k] = true
+					}
+					want := map[string]bool{"a": true, "b": false}
+					return maps.Equal(got, want)
+				}
+				`,
+		},
+		{
+			name: "compared with an untracked map not reported",
+			src: `package p
+
+				import "maps"
+
+				func want() map[string]bool { return nil }
+
+				func f(k string) bool {
+					got := map[string]bool{}
+					got[k] = true
+					return maps.Equal(got, want())
+				}
+				`,
+		},
+		{
+			name: "compared with an escaping map not reported",
+			src: `package p
+
+				import (
+					"encoding/json"
+					"reflect"
+				)
+
+				func f(data []byte, k string) bool {
+					got := map[string]bool{}
+					got[k] = true
+					want := map[string]bool{"a": true}
+					_ = json.Unmarshal(data, &want)
+					return reflect.DeepEqual(got, want)
+				}
+				`,
+		},
+		{
+			name: "write in an immediately called closure",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					func() { set[k] = true }()
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "write in a closure called later",
+			src: `package p
+
+				func f(keys []string) map[string]bool {
+					set := map[string]bool{}
+					add := func(k string) { set[k] = true }
+					for _, k := range keys {
+						add(k)
+					}
+					return set
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "passed to a closure storing true",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					mark := func(m map[string]bool) { m[k] = true }
+					mark(set)
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "passed through closures storing true",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					var mark func(map[string]bool)
+					mark = func(m map[string]bool) { m[k] = true }
+					func(m map[string]bool) { mark(m) }(set)
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "passed to a closure storing false not reported",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					set[k] = true
+					unset := func(m map[string]bool) { m[k] = false }
+					unset(set)
+				}
+				`,
+		},
+		{
+			name: "passed to a reassigned closure not reported",
+			src: `package p
+
+				func f(k string, c bool) {
+					set := map[string]bool{}
+					set[k] = true
+					do := func(m map[string]bool) {}
+					if c {
+						do = func(m map[string]bool) { m[k] = false }
+					}
+					do(set)
+				}
+				`,
+		},
+		{
+			name: "passed to a helper storing true",
+			src: `package p
+
+				func mark(m map[string]bool, k string) { m[k] = true }
+
+				func f(keys []string) {
+					set := map[string]bool{}
+					for _, k := range keys {
+						mark(set, k)
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "passed through helpers storing true",
+			src: `package p
+
+				func mark(m map[string]bool, k string) { m[k] = true }
+
+				func markAll(m map[string]bool, keys ...string) {
+					for _, k := range keys {
+						mark(m, k)
+					}
+				}
+
+				func f(keys []string) {
+					set := map[string]bool{}
+					markAll(set, keys...)
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "passed to a method storing true",
+			src: `package p
+
+				type S struct{}
+
+				func (S) mark(m map[string]bool, k string) { m[k] = true }
+
+				func f(s S, k string) {
+					set := map[string]bool{}
+					s.mark(set, k)
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "passed to a recursive helper storing true",
+			src: `package p
+
+				func fill(m map[string]bool, n int) {
+					if n > 0 {
+						m["x"] = true
+						fill(m, n-1)
+					}
+				}
+
+				func f() {
+					set := map[string]bool{}
+					fill(set, 3)
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "passed to a helper storing false not reported",
+			src: `package p
+
+				func unmark(m map[string]bool, k string) { m[k] = false }
+
+				func f(k string) {
+					set := map[string]bool{}
+					set[k] = true
+					unmark(set, k)
+				}
+				`,
+		},
+		{
+			name: "passed to a helper storing its argument not reported",
+			src: `package p
+
+				func put(m map[string]bool, k string, v bool) { m[k] = v }
+
+				func f(k string) {
+					set := map[string]bool{}
+					put(set, k, true)
+				}
+				`,
+		},
+		{
+			name: "passed to a variadic helper storing false not reported",
+			src: `package p
+
+				func unmarkAll(k string, ms ...map[string]bool) {
+					for _, m := range ms {
+						m[k] = false
+					}
+				}
+
+				func f(k string) {
+					a, b := map[string]bool{}, map[string]bool{}
+					a[k] = true
+					b[k] = true
+					unmarkAll(k, a, b)
+				}
+				`,
+		},
+		{
+			name: "slice of maps passed to a helper storing false not reported",
+			src: `package p
+
+				func unmarkAll(bs []map[string]bool, k string) {
+					for i := range bs {
+						bs[i][k] = false
+					}
+				}
+
+				func f(k string) {
+					buckets := make([]map[string]bool, 2)
+					buckets[0][k] = true
+					unmarkAll(buckets, k)
+				}
+				`,
+		},
+		{
+			name: "passed to a helper printing it not reported",
+			src: `package p
+
+				import "fmt"
+
+				func show(m map[string]bool) { fmt.Println(m) }
+
+				func f(k string) {
+					set := map[string]bool{}
+					set[k] = true
+					show(set)
+				}
+				`,
+		},
+		{
+			name: "passed to an interface method not reported",
+			src: `package p
+
+				type marker interface{ mark(map[string]bool) }
+
+				func f(mk marker, k string) {
+					set := map[string]bool{}
+					set[k] = true
+					mk.mark(set)
+				}
+				`,
+		},
+		{
+			name: "passed to a function parameter not reported",
+			src: `package p
+
+				func f(k string, g func(map[string]bool)) {
+					set := map[string]bool{}
+					set[k] = true
+					g(set)
+				}
+				`,
+		},
+		{
+			name: "false stored in a goroutine not reported",
+			src: `package p
+
+				func f(k string, done chan struct{}) {
+					set := map[string]bool{}
+					set[k] = true
+					go func() {
+						set[k] = false
+						close(done)
+					}()
+				}
+				`,
+		},
+		{
+			name: "write in a deferred closure",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					defer func() { set[k] = true }()
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "deferred delete",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					set[k] = true
+					defer delete(set, k)
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "deferred assignment runs after the write",
+			src: `package p
+
+				func f(k string) {
+					ok := true
+					defer func() { ok = false }()
+					set := map[string]bool{}
+					set[k] = ok
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "deferred write sees the value at exit",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					ok := false
+					defer func() { set[k] = ok }()
+					ok = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "deferred write after a change not reported",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					ok := true
+					defer func() { set[k] = ok }()
+					ok = false
+				}
+				`,
+		},
+		{
+			name: "deferred write on an early return not reported",
+			src: `package p
+
+				func f(k string, c bool) {
+					set := map[string]bool{}
+					ok := false
+					defer func() { set[k] = ok }()
+					if c {
+						return
+					}
+					ok = true
+				}
+				`,
+		},
+		{
+			name: "deep selector chain",
+			src: `package p
+
+				type cfg struct{ allowed map[string]bool }
+
+				type inner struct{ cfg cfg }
+
+				type S struct{ inner inner }
+
+				func (s *S) allow(k string) { s.inner.cfg.allowed[k] = true }
+
+				func (s *S) allowed(k string) bool { return s.inner.cfg.allowed[k] }
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "deep selector chain storing false",
+			src: `package p
+
+				type cfg struct{ allowed map[string]bool }
+
+				type inner struct{ cfg cfg }
+
+				type S struct{ inner inner }
+
+				func (s *S) allow(k string) { s.inner.cfg.allowed[k] = true }
+
+				func (s *S) deny(k string) { s.inner.cfg.allowed[k] = false }
+				`,
+		},
+		{
+			name: "promoted field",
+			src: `package p
+
+				type base struct{ set map[string]bool }
+
+				type outer struct{ *base }
+
+				func (o *outer) add(k string) { o.set[k] = true }
+
+				func (o *outer) has(k string) bool { return o.set[k] }
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "promoted field storing false through its embedded struct",
+			src: `package p
+
+				type base struct{ set map[string]bool }
+
+				type outer struct{ base }
+
+				func (o *outer) add(k string) { o.set[k] = true }
+
+				func (o *outer) remove(k string) { o.base.set[k] = false }
+				`,
+		},
+		{
+			name: "promoted field storing false in another embedder",
+			src: `package p
+
+				type base struct{ set map[string]bool }
+
+				type outer struct{ base }
+
+				type other struct{ *base }
+
+				func (o *outer) add(k string) { o.set[k] = true }
+
+				func (o other) remove(k string) { o.set[k] = false }
+				`,
+		},
+		{
+			name: "promoted field storing false through the embedded type's method",
+			src: `package p
+
+				type base struct{ set map[string]bool }
+
+				func (b *base) remove(k string) { b.set[k] = false }
+
+				type outer struct{ base }
+
+				func (o *outer) add(k string) { o.set[k] = true }
+				`,
+		},
+		{
+			name: "field literal storing false",
+			src: `package p
+
+				type S struct{ set map[string]bool }
+
+				func New() *S { return &S{set: map[string]bool{"a": false}} }
+
+				func (s *S) add(k string) { s.set[k] = true }
+				`,
+		},
+		{
+			name: "unkeyed field literal storing false",
+			src: `package p
+
+				type S struct{ set map[string]bool }
+
+				func New() *S { return &S{map[string]bool{"a": false}} }
+
+				func (s *S) add(k string) { s.set[k] = true }
+				`,
+		},
+		{
+			name: "nested field literal storing false",
+			src: `package p
+
+				type cfg struct{ allowed map[string]bool }
+
+				type S struct{ cfg cfg }
+
+				func New() S { return S{cfg: cfg{allowed: map[string]bool{"a": false}}} }
+
+				func (s *S) allow(k string) { s.cfg.allowed[k] = true }
+				`,
+		},
+		{
+			name: "alias storing false",
+			src: `package p
+
+				func f(k string) {
+					m1 := map[string]bool{}
+					m2 := m1
+					m1[k] = true
+					m2[k] = false
+				}
+				`,
+		},
+		{
+			name: "aliased map storing false",
+			src: `package p
+
+				func f(k string) {
+					m1 := map[string]bool{}
+					var m2 map[string]bool
+					m2 = m1
+					m2[k] = true
+					m1[k] = false
+				}
+				`,
+		},
+		{
+			name: "alias of an alias storing false",
+			src: `package p
+
+				func f(k string) {
+					m1 := map[string]bool{}
+					m2 := m1
+					m3 := m2
+					m1[k] = true
+					m3[k] = false
+				}
+				`,
+		},
+		{
+			name: "local copy of a field storing false",
+			src: `package p
+
+				type S struct{ set map[string]bool }
+
+				func (s *S) add(k string) {
+					local := s.set
+					local[k] = true
+				}
+
+				func (s *S) remove(k string) { s.set[k] = false }
+				`,
+		},
+		{
+			name: "aliases storing true",
+			src: `package p
+
+				func f(k string) {
+					m1 := map[string]bool{}
+					m2 := m1
+					m1[k] = true
+					m2[k] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "shadowing map storing true",
+			src: `package p
+
+				func f(k string, c bool) {
+					set := map[string]bool{}
+					set[k] = false
+					if c {
+						set := map[string]bool{}
+						set[k] = true
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "shadowed map storing true",
+			src: `package p
+
+				func f(k string, c bool) {
+					set := map[string]bool{}
+					set[k] = true
+					if c {
+						set := map[string]bool{}
+						set[k] = false
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "global shadowed by a local storing false",
+			src: `package p
+
+				var set = map[string]bool{}
+
+				func f(k string) {
+					set := map[string]bool{}
+					set[k] = false
+				}
+
+				func g(k string) { set[k] = true }
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "map shadowed in a function literal",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					func() {
+						set := map[string]bool{}
+						set[k] = false
+					}()
+					set[k] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "map shadowed in a switch statement",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					set[k] = true
+					switch set := map[string]bool{}; k {
+					case "a":
+						set[k] = false
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "shadowed alias",
+			src: `package p
+
+				func f(k string) {
+					set := map[string]bool{}
+					m := set
+					{
+						m := map[string]bool{}
+						m[k] = false
+					}
+					m[k] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "false assigned to a shadowing variable",
+			src: `package p
+
+				func f(k string, c bool) {
+					ok := true
+					set := map[string]bool{}
+					if c {
+						ok := false
+						_ = ok
+					}
+					set[k] = ok
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "true from a shadowing variable",
+			src: `package p
+
+				func f(k string, c bool) {
+					ok := false
+					set := map[string]bool{}
+					if c {
+						ok := true
+						set[k] = ok
+					}
+					_ = ok
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "written through an accessor method",
+			src: `package p
+
+				type S struct{ set map[string]bool }
+
+				func (s *S) Set() map[string]bool { return s.set }
+
+				func f(s *S, k string) { s.Set()[k] = true }
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "written through an accessor declared later",
+			src: `package p
+
+				var set = map[string]bool{}
+
+				func f(k string) { getSet()[k] = true }
+
+				func getSet() map[string]bool { return set }
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "false stored through an accessor method",
+			src: `package p
+
+				type S struct{ set map[string]bool }
+
+				func (s *S) Set() map[string]bool { return s.set }
+
+				func f(s *S, k string) {
+					s.set[k] = true
+					s.Set()[k] = false
+				}
+				`,
+		},
+		{
+			name: "false stored through the result of an accessor",
+			src: `package p
+
+				var set = map[string]bool{}
+
+				func getSet() map[string]bool { return set }
+
+				func f(k string) { getSet()[k] = true }
+
+				func g(k string) {
+					m := getSet()
+					m[k] = false
+				}
+				`,
+		},
+		{
+			name: "accessor result escaping not reported",
+			src: `package p
+
+				import "fmt"
+
+				type S struct{ set map[string]bool }
+
+				func (s *S) Set() map[string]bool { return s.set }
+
+				func f(s *S, k string) {
+					s.set[k] = true
+					fmt.Println(s.Set())
+				}
+				`,
+		},
+		{
+			name: "recursive function not an accessor",
+			src: `package p
+
+				func rec() map[string]bool { return rec() }
+
+				func f(k string) { rec()[k] = true }
+				`,
+		},
+		{
+			name: "set type with methods",
+			src: `package p
+
+				type Set map[string]bool
+
+				func (s Set) Add(v string) { s[v] = true }
+
+				func (s Set) Has(v string) bool { return s[v] }
+
+				func f() {
+					s := Set{}
+					s.Add("a")
+					s["b"] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "set type storing false outside its methods",
+			src: `package p
+
+				type Set map[string]bool
+
+				func (s Set) Add(v string) { s[v] = true }
+
+				func f() {
+					s := Set{"x": false}
+					s.Add("a")
+				}
+				`,
+		},
+		{
+			name: "generic set type storing false in an instantiation",
+			src: `package p
+
+				type Set[T comparable] map[T]bool
+
+				func (s Set[T]) Add(v T) { s[v] = true }
+
+				func f() {
+					s := Set[string]{}
+					s.Add("a")
+					s["b"] = false
+				}
+				`,
+		},
+		{
+			name: "generic set type escaping not reported",
+			src: `package p
+
+				import "fmt"
+
+				type Set[T comparable] map[T]bool
+
+				func (s Set[T]) Add(v T) { s[v] = true }
+
+				func f() {
+					s := Set[int]{}
+					s.Add(1)
+					fmt.Println(s)
+				}
+				`,
+		},
+		{
+			name: "generic field storing false in an instantiation",
+			src: `package p
+
+				type G[T comparable] struct{ set map[T]bool }
+
+				func (g *G[T]) add(k T) { g.set[k] = true }
+
+				func f(g *G[string], k string) { g.set[k] = false }
+				`,
+		},
+		{
+			name: "maps held by a map",
+			src: `package p
+
+				func f(a, b string) {
+					index := map[string]map[string]bool{}
+					index[a] = map[string]bool{}
+					index[a][b] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "maps held by a field",
+			src: `package p
+
+				type S struct{ index map[int]map[string]bool }
+
+				func (s *S) add(a int, b string) { s.index[a][b] = true }
+
+				func (s *S) has(a int, b string) bool { return s.index[a][b] }
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "maps held at depth",
+			src: `package p
+
+				func f(a, b, c string) {
+					index := map[string]map[string]map[string]bool{}
+					index[a][b][c] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "held map literal storing false",
+			src: `package p
+
+				func f(a, b string) {
+					index := map[string]map[string]bool{"x": {"y": false}}
+					index[a][b] = true
+				}
+				`,
+		},
+		{
+			name: "held map assigned storing false",
+			src: `package p
+
+				func f(a, b string) {
+					index := map[string]map[string]bool{}
+					index[a] = map[string]bool{b: false}
+					index[a][b] = true
+				}
+				`,
+		},
+		{
+			name: "held map storing false in a range loop",
+			src: `package p
+
+				func f(a, b string) {
+					index := map[string]map[string]bool{}
+					index[a][b] = true
+					for _, inner := range index {
+						inner[b] = false
+					}
+				}
+				`,
+		},
+		{
+			name: "held map looked up storing false",
+			src: `package p
+
+				func f(a, b string) {
+					index := map[string]map[string]bool{}
+					index[a][b] = true
+					if inner, ok := index[a]; ok {
+						inner[b] = false
+					}
+				}
+				`,
+		},
+		{
+			name: "held map storing false through an alias of its holder",
+			src: `package p
+
+				func f(a, b string) {
+					index := map[string]map[string]bool{}
+					other := index
+					index[a][b] = true
+					other[a][b] = false
+				}
+				`,
+		},
+		{
+			name: "maps held by a slice",
+			src: `package p
+
+				func f(n, i int, k string) {
+					buckets := make([]map[string]bool, n)
+					for j := range buckets {
+						buckets[j] = make(map[string]bool)
+					}
+					buckets[i][k] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "maps held by an array",
+			src: `package p
+
+				func f(i int, k string) {
+					var buckets [4]map[string]bool
+					buckets[i][k] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "maps held by a slice of slices",
+			src: `package p
+
+				func f(i int, k string) {
+					grid := [][]map[string]bool{}
+					grid[i][i][k] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "slice literal holding a map storing false",
+			src: `package p
+
+				func f(i int, k string) {
+					buckets := []map[string]bool{{"a": false}}
+					buckets[i][k] = true
+				}
+				`,
+		},
+		{
+			name: "map storing false appended to a slice",
+			src: `package p
+
+				func f(i int, k string) {
+					var buckets []map[string]bool
+					m := map[string]bool{}
+					buckets = append(buckets, m)
+					buckets[i][k] = true
+					m[k] = false
+				}
+				`,
+		},
+		{
+			name: "map literal storing false appended to a slice",
+			src: `package p
+
+				func f(i int, k string) {
+					var buckets []map[string]bool
+					buckets = append(buckets, map[string]bool{"a": false})
+					buckets[i][k] = true
+				}
+				`,
+		},
+		{
+			name: "slice of maps copied storing false",
+			src: `package p
+
+				func f(i int, k string) {
+					var buckets, other []map[string]bool
+					buckets[i][k] = true
+					copy(other, buckets)
+					other[i][k] = false
+				}
+				`,
+		},
+		{
+			name: "slice of maps storing false in a range loop",
+			src: `package p
+
+				func f(i int, k string) {
+					var buckets []map[string]bool
+					buckets[i][k] = true
+					for _, b := range buckets {
+						b[k] = false
+					}
+				}
+				`,
+		},
+		{
+			name: "map of maps escaping not reported",
+			src: `package p
+
+				import "fmt"
+
+				func f(a, b string) {
+					index := map[string]map[string]bool{}
+					index[a][b] = true
+					fmt.Println(index)
+				}
+				`,
+		},
+		{
+			name: "false in another switch case",
+			src: `package p
+
+				func f(k string, c int) {
+					set := map[string]bool{}
+					ok := true
+					switch c {
+					case 1:
+						ok = false
+					case 2:
+						set[k] = ok
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "false falling through not reported",
+			src: `package p
+
+				func f(k string, c int) {
+					set := map[string]bool{}
+					ok := true
+					switch c {
+					case 1:
+						ok = false
+						fallthrough
+					case 2:
+						set[k] = ok
+					}
+				}
+				`,
+		},
+		{
+			name: "false in another type switch case",
+			src: `package p
+
+				func f(k string, x any) {
+					set := map[string]bool{}
+					ok := true
+					switch x.(type) {
+					case int:
+						ok = false
+					case string:
+						set[k] = ok
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "false from a type switch case not reported",
+			src: `package p
+
+				func f(k string, x any) {
+					set := map[string]bool{}
+					ok := true
+					switch x.(type) {
+					case int:
+						ok = false
+					}
+					set[k] = ok
+				}
+				`,
+		},
+		{
+			name: "type switch variable not trusted",
+			src: `package p
+
+				func f(k string, x any) {
+					set := map[string]bool{}
+					switch b := x.(type) {
+					case bool:
+						set[k] = b
+					}
+				}
+				`,
+		},
+		{
+			name: "false in another select case",
+			src: `package p
+
+				func f(k string, ch chan int) {
+					set := map[string]bool{}
+					ok := true
+					select {
+					case <-ch:
+						ok = false
+					default:
+						set[k] = ok
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "received in a select case not reported",
+			src: `package p
+
+				func f(k string, bools chan bool) {
+					set := map[string]bool{}
+					ok := true
+					select {
+					case ok = <-bools:
+					default:
+					}
+					set[k] = ok
+				}
+				`,
+		},
+		{
+			name: "false in a select loop not reported",
+			src: `package p
+
+				func f(k string, ch chan int) {
+					set := map[string]bool{}
+					ok := true
+					for {
+						select {
+						case <-ch:
+							set[k] = ok
+						default:
+							ok = false
+						}
+					}
+				}
+				`,
+		},
+		{
+			name: "global true variable not trusted",
+			src: `package p
+
+				var alwaysTrue = true
+				
+				func f() {
+					set := make(map[string]bool)
+					set["a"] = alwaysTrue
+				}
+				`,
+			wantMsgs: nil,
+		},
+		{
+			name: "tuple assignment all true",
+			src: `package p
+
+				func f() {
+					set := make(map[string]bool)
+					set["a"], set["b"] = true, true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "tuple assignment with false",
+			src: `package p
+
+				func f() {
+					set := make(map[string]bool)
+					set["a"], set["b"] = true, false
+				}
+				`,
+			wantMsgs: nil,
+		},
+		{
+			name: "constant folded true expression",
+			src: `package p
+
+				func f() {
+					set := make(map[string]bool)
+					set["a"] = 1 < 2
+					set["b"] = !false
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "type alias map",
+			src: `package p
+
+				type stringSet map[string]bool
+				
+				func f() {
+					set := make(stringSet)
+					set["a"] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "chained true locals",
+			src: `package p
+
+				func f() {
+					flag := true
+					alias := flag
+					set := make(map[string]bool)
+					set["a"] = alias
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "loop assignments",
+			src: `package p
+
+				func f() {
+					keys := []string{"a", "b"}
+					set := make(map[string]bool)
+					for _, k := range keys {
+						set[k] = true
+					}
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "nested map only true values",
+			src: `package p
+
+				func f() {
+					sets := map[string]map[string]bool{}
+					sets["first"] = make(map[string]bool)
+					inner := sets["first"]
+					inner["a"] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "nested map includes false",
+			src: `package p
+
+				func f() {
+					sets := map[string]map[string]bool{}
+					sets["first"] = make(map[string]bool)
+					inner := sets["first"]
+					inner["a"] = true
+					inner["b"] = false
+				}
+				`,
+			wantMsgs: nil,
+		},
+		{
+			name: "pointer dereference assignment",
+			src: `package p
+
+				func f() {
+					set := make(map[string]bool)
+					setPtr := &set
+					(*setPtr)["a"] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "false through a pointer not reported",
+			src: `package p
+
+				func f(k string) {
+					set := make(map[string]bool)
+					set[k] = true
+					var p *map[string]bool
+					p = &set
+					(*p)["b"] = false
+				}
+				`,
+		},
+		{
+			name: "pointer to several maps not reported",
+			src: `package p
+
+				func f(k string, c bool) {
+					a := make(map[string]bool)
+					a[k] = true
+					b := make(map[string]bool)
+					b[k] = true
+					p := &a
+					if c {
+						p = &b
+					}
+					(*p)[k] = false
+				}
+				`,
+		},
+		{
+			name: "escaping pointer not reported",
+			src: `package p
+
+				func fill(p *map[string]bool) { (*p)["x"] = false }
+
+				func f(k string) {
+					set := make(map[string]bool)
+					set[k] = true
+					p := &set
+					fill(p)
+				}
+				`,
+		},
+		{
+			name: "address passed to a function of the package not reported",
+			src: `package p
+
+				func fill(p *map[string]bool) { (*p)["x"] = false }
+
+				func f(k string) {
+					set := make(map[string]bool)
+					set[k] = true
+					fill(&set)
+				}
+				`,
+		},
+		{
+			name: "unresolved import only skips affected maps",
+			src: `package p
+
+				import "example.com/missing/dep"
+
+				func f() {
+					set := map[string]bool{}
+					set[dep.Name] = true
+					other := map[dep.Key]bool{}
+					other[dep.K] = true
+				}
+				`,
+			wantMsgs: []string{diagMsg},
+		},
+		{
+			name: "assignment of function call result not reported",
+			src: `package p
+
+				func f() {
+					set := make(map[string]bool)
+					set["a"] = g()
+				}
+
+				func g() bool {
+					return true
+				}
+				`,
+			wantMsgs: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			diags := runNewAnalyzer(t, tc.src)
+			if len(diags) != len(tc.wantMsgs) {
+				t.Fatalf("expected %d diagnostics, got %d", len(tc.wantMsgs), len(diags))
+			}
+			sort.Strings(diags)
+			sort.Strings(tc.wantMsgs)
+			for i, msg := range tc.wantMsgs {
+				if diags[i] != msg {
+					t.Fatalf("unexpected diagnostic %q, want %q", diags[i], msg)
+				}
+			}
+		})
+	}
+}
+
+func TestAnalyzeEdits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "local composite literal",
+			src: `package p
+
+func f() {
+	set := map[string]bool{"a": true}
+	set["b"] = true
+}
+`,
+			want: `package p
+
+func f() {
+	set := map[string]struct{}{"a": struct{}{}}
+	set["b"] = struct{}{}
+}
+`,
+		},
+		{
+			name: "struct field",
+			src: `package p
+
+type S struct {
+	set map[string]bool
+}
+
+func (s *S) init() {
+	s.set = make(map[string]bool)
+	s.set["ok"] = true
+}
+`,
+			want: `package p
+
+type S struct {
+	set map[string]struct{}
+}
+
+func (s *S) init() {
+	s.set = make(map[string]struct{})
+	s.set["ok"] = struct{}{}
+}
+`,
+		},
+		{
+			name: "nested struct literal",
+			src: `package p
+
+type cfg struct{ allowed map[string]bool }
+
+type S struct{ cfg cfg }
+
+func New() *S { return &S{cfg: cfg{allowed: map[string]bool{"a": true}}} }
+
+func (s *S) allow(k string) { s.cfg.allowed[k] = true }
+`,
+			want: `package p
+
+type cfg struct{ allowed map[string]struct{} }
+
+type S struct{ cfg cfg }
+
+func New() *S { return &S{cfg: cfg{allowed: map[string]struct{}{"a": struct{}{}}}} }
+
+func (s *S) allow(k string) { s.cfg.allowed[k] = struct{}{} }
+`,
+		},
+		{
+			name: "promoted field",
+			src: `package p
+
+type base struct{ set map[string]bool }
+
+type outer struct{ base }
+
+func New() *outer { return &outer{base{set: map[string]bool{}}} }
+
+func (o *outer) add(k string) { o.set[k] = true }
+`,
+			want: `package p
+
+type base struct{ set map[string]struct{} }
+
+type outer struct{ base }
+
+func New() *outer { return &outer{base{set: map[string]struct{}{}}} }
+
+func (o *outer) add(k string) { o.set[k] = struct{}{} }
+`,
+		},
+		{
+			name: "alias",
+			src: `package p
+
+func f(k string) {
+	m1 := map[string]bool{}
+	m2 := m1
+	m1[k] = true
+	m2[k] = true
+}
+`,
+			want: `package p
+
+func f(k string) {
+	m1 := map[string]struct{}{}
+	m2 := m1
+	m1[k] = struct{}{}
+	m2[k] = struct{}{}
+}
+`,
+		},
+		{
+			name: "alias of another type is not fixable",
+			src: `package p
+
+type Set map[string]bool
+
+func f(k string) {
+	m1 := map[string]bool{}
+	var m2 Set = m1
+	m2[k] = true
+}
+`,
+		},
+		{
+			name: "shadowed map",
+			src: `package p
+
+func f(k string, c bool) {
+	set := map[string]bool{}
+	set[k] = true
+	if c {
+		set := map[string]bool{}
+		set[k] = false
+	}
+}
+`,
+			want: `package p
+
+func f(k string, c bool) {
+	set := map[string]struct{}{}
+	set[k] = struct{}{}
+	if c {
+		set := map[string]bool{}
+		set[k] = false
+	}
+}
+`,
+		},
+		{
+			name: "type parameter key",
+			src: `package p
+
+func mark[K comparable](keys []K) {
+	seen := map[K]bool{}
+	for _, k := range keys {
+		seen[k] = true
+	}
+}
+`,
+			want: `package p
+
+func mark[K comparable](keys []K) {
+	seen := map[K]struct{}{}
+	for _, k := range keys {
+		seen[k] = struct{}{}
+	}
+}
+`,
+		},
+		{
+			name: "delete keeps the fix",
+			src: `package p
+
+func f(k string) {
+	set := map[string]bool{}
+	set["a"] = true
+	delete(set, k)
+}
+`,
+			want: `package p
+
+func f(k string) {
+	set := map[string]struct{}{}
+	set["a"] = struct{}{}
+	delete(set, k)
+}
+`,
+		},
+		{
+			name: "clear keeps the fix",
+			src: `package p
+
+func f(keys []string) {
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		seen[k] = true
+	}
+	clear(seen)
+	seen["a"] = true
+}
+`,
+			want: `package p
+
+func f(keys []string) {
+	seen := make(map[string]struct{})
+	for _, k := range keys {
+		seen[k] = struct{}{}
+	}
+	clear(seen)
+	seen["a"] = struct{}{}
+}
+`,
+		},
+		{
+			name: "reset keeps the fix",
+			src: `package p
+
+type S struct {
+	seen map[int]bool
+}
+
+func (s *S) add(k int) { s.seen[k] = true }
+
+func (s *S) Reset() { s.seen = make(map[int]bool) }
+
+func (s *S) Close() { s.seen = nil }
+`,
+			want: `package p
+
+type S struct {
+	seen map[int]struct{}
+}
+
+func (s *S) add(k int) { s.seen[k] = struct{}{} }
+
+func (s *S) Reset() { s.seen = make(map[int]struct{}) }
+
+func (s *S) Close() { s.seen = nil }
+`,
+		},
+		{
+			name: "membership read is not fixable",
+			src: `package p
+
+func f(k string) bool {
+	set := map[string]bool{}
+	set["a"] = true
+	return set[k]
+}
+`,
+		},
+		{
+			name: "map written through an accessor is not fixable",
+			src: `package p
+
+type S struct{ set map[string]bool }
+
+func (s *S) Set() map[string]bool { return s.set }
+
+func (s *S) add(k string) { s.Set()[k] = true }
+`,
+		},
+		{
+			name: "map from another map is not fixable",
+			src: `package p
+
+func f() {
+	sets := map[string]map[string]bool{}
+	inner := sets["first"]
+	inner["a"] = true
+}
+`,
+		},
+		{
+			name: "true local variable is not fixable",
+			src: `package p
+
+func f() {
+	flag := true
+	set := make(map[string]bool)
+	set["a"] = flag
+}
+`,
+		},
+		{
+			name: "shared declaration is not fixable",
+			src: `package p
+
+func f() {
+	var set, other map[string]bool
+	set["a"] = true
+	other["a"] = false
+}
+`,
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			fset, files, pkg, info := typeCheck(t, tc.src)
+			diags := Analyze(pkg, files, info)
+			if len(diags) != 1 {
+				t.Fatalf("expected 1 diagnostic, got %d", len(diags))
+			}
+			if tc.want == "" {
+				if diags[0].Edits != nil {
+					t.Fatalf("expected no edits, got %v", diags[0].Edits)
+				}
+				return
+			}
+			if got := applyEdits(fset, tc.src, diags[0].Edits); got != tc.want {
+				t.Fatalf("unexpected fix:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNewAnalyzerSuggestedFixes(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func f(k string) bool {
+	set := map[string]bool{}
+	set["a"] = true
+	seen := map[string]bool{}
+	seen["b"] = true
+	return seen[k]
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	fixes := make(map[string][]string)
+	pass := &analysis.Pass{
+		Analyzer:  NewAnalyzer(),
+		Fset:      fset,
+		Files:     files,
+		Pkg:       pkg,
+		TypesInfo: info,
+		Report: func(diag analysis.Diagnostic) {
+			if diag.URL != RuleURL(RuleMapBoolSet) {
+				t.Errorf("diagnostic URL = %q, want %q", diag.URL, RuleURL(RuleMapBoolSet))
+			}
+			titles := []string{}
+			for _, fix := range diag.SuggestedFixes {
+				titles = append(titles, fix.Message)
+				for i := 1; i < len(fix.TextEdits); i++ {
+					if fix.TextEdits[i].Pos < fix.TextEdits[i-1].End {
+						t.Errorf("%s: edits out of order", fix.Message)
+					}
+				}
+			}
+			fixes[fset.Position(diag.Pos).String()] = titles
+		},
+	}
+	if _, err := NewAnalyzer().Run(pass); err != nil {
+		t.Fatalf("analyzer run error: %v", err)
+	}
+	want := map[string][]string{"test.go:4:2": {"Convert set to map[string]struct{}"}, "test.go:6:2": {}}
+	if !reflect.DeepEqual(fixes, want) {
+		t.Fatalf("unexpected suggested fixes per diagnostic %v, want %v", fixes, want)
+	}
+}
+
+func TestAnalyzerConcurrentPackages(t *testing.T) {
+	t.Parallel()
+
+	for _, analyzers := range [][]*analysis.Analyzer{Analyzers(), {Analyzer}} {
+		if err := analysis.Validate(analyzers); err != nil {
+			t.Fatalf("invalid analyzer: %v", err)
+		}
+	}
+
+	src := `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`
+	// Type-check up front; t.Fatal must not be called from the goroutines.
+	passes := make([]*analysis.Pass, 8)
+	counts := make([]int, len(passes))
+	for i := range passes {
+		fset, files, pkg, info := typeCheck(t, src)
+		passes[i] = &analysis.Pass{
+			Analyzer:  Analyzer,
+			Fset:      fset,
+			Files:     files,
+			Pkg:       pkg,
+			TypesInfo: info,
+			Report:    func(analysis.Diagnostic) { counts[i]++ },
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, pass := range passes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Analyzer.Run(pass); err != nil {
+				t.Errorf("analyzer run error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	for i, count := range counts {
+		if count != 1 {
+			t.Errorf("package %d: expected 1 diagnostic, got %d", i, count)
+		}
+	}
+}
+
+// TestConcurrentAnalyze runs every entry point from many goroutines, sharing
+// options and a Linter, so the race detector can check the guarantee in the
+// package documentation.
+func TestConcurrentAnalyze(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+	flags := map[string]bool{}
+	flags["a"] = false
+}
+`
+	type input struct {
+		files []*ast.File
+		pkg   *types.Package
+		info  *types.Info
+	}
+	// Half the goroutines share a package, half have their own.
+	shared := make([]input, 2)
+	for i := range shared {
+		_, files, pkg, info := typeCheck(t, src)
+		shared[i] = input{files, pkg, info}
+	}
+	var m Metrics
+	opts := DefaultOptions()
+	opts.Metrics = &m
+	opts.Locale = "de"
+	opts.Filter = func(f Finding) bool { return f.Name != "" }
+	linter := New(WithLocale("fr"))
+
+	const goroutines = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for i := range goroutines {
+		in := shared[i%2]
+		if i%2 == 0 {
+			_, files, pkg, info := typeCheck(t, src)
+			in = input{files, pkg, info}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if n := len(Analyze(in.pkg, in.files, in.info)); n != 1 {
+				errs <- fmt.Errorf("Analyze: %d diagnostics", n)
+			}
+			if n := len(AnalyzeFindings(in.pkg, in.files, in.info, opts)); n != 1 {
+				errs <- fmt.Errorf("AnalyzeFindings: %d findings", n)
+			}
+			if n := len(Inventory(in.pkg, in.files, in.info)); n != 2 {
+				errs <- fmt.Errorf("Inventory: %d maps", n)
+			}
+			if f, err := linter.Analyze(context.Background(), in.pkg, in.files, in.info); err != nil || len(f) != 1 {
+				errs <- fmt.Errorf("Linter.Analyze: %d findings, %v", len(f), err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if m.Files != goroutines || m.Flagged != goroutines {
+		t.Errorf("Metrics counted %d files and %d flagged maps, want %d of each", m.Files, m.Flagged, goroutines)
+	}
+}
+
+func TestAnalyzerFlags(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+var global = map[string]bool{}
+
+type S struct {
+	field  map[int]bool
+	Tagged map[string]bool ` + "`json:\"tagged\"`" + `
+}
+
+func f(s *S) {
+	global["a"] = true
+	s.field[1] = true
+	s.Tagged["a"] = true
+	local := map[string]bool{}
+	local["a"] = true
+	local["b"] = true
+}
+`
+	tests := []struct {
+		flags map[string]string
+		want  []string
+	}{
+		{nil, []string{"field", "global", "local"}},
+		{map[string]string{"min-writes": "2"}, []string{"local"}},
+		{map[string]string{"include-fields": "false"}, []string{"global", "local"}},
+		{map[string]string{"include-globals": "false"}, []string{"field", "local"}},
+		{map[string]string{"exclude-key-types": "^int$"}, []string{"global", "local"}},
+		{map[string]string{"ssa": "true"}, []string{"field", "global", "local"}},
+		{map[string]string{"include-serialized": "true"}, []string{"Tagged", "field", "global", "local"}},
+	}
+	for _, tc := range tests {
+		a := NewAnalyzer()
+		for name, value := range tc.flags {
+			if err := a.Flags.Set(name, value); err != nil {
+				t.Fatalf("set -%s: %v", name, err)
+			}
+		}
+		fset, files, pkg, info := typeCheck(t, src)
+		var got []string
+		pass := &analysis.Pass{
+			Analyzer:  a,
+			Fset:      fset,
+			Files:     files,
+			Pkg:       pkg,
+			TypesInfo: info,
+			Report: func(diag analysis.Diagnostic) {
+				got = append(got, nameAt(files[0], diag.Pos))
+			},
+		}
+		if _, err := a.Run(pass); err != nil {
+			t.Fatalf("analyzer run error: %v", err)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("flags %v: reported %v, want %v", tc.flags, got, tc.want)
+		}
+	}
+
+	if err := NewAnalyzer().Flags.Set("exclude-key-types", "("); err == nil {
+		t.Fatalf("expected an error for an invalid regexp")
+	}
+}
+
+func TestDiagnosticRange(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func f(ids []int) {
+	seen := map[int]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+	known := map[string]bool{"a": true}
+	_ = known
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	var got []string
+	for _, diag := range Analyze(pkg, files, info) {
+		if diag.URL != RuleURL(diag.Rule) {
+			t.Errorf("URL = %q, want %q", diag.URL, RuleURL(diag.Rule))
+		}
+		start, end := fset.Position(diag.Pos).Offset, fset.Position(diag.End).Offset
+		got = append(got, src[start:end])
+	}
+	sort.Strings(got)
+	if want := []string{"known", "seen"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("diagnostic ranges cover %q, want %q", got, want)
+	}
+}
+
+func TestDiagnosticRelated(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func f(ids []int) {
+	seen := map[int]bool{0: true}
+	for _, id := range ids {
+		seen[id] = true
+	}
+	seen[-1] = 1 < 2
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	text := func(pos, end token.Pos) string {
+		return src[fset.Position(pos).Offset:fset.Position(end).Offset]
+	}
+	want := []string{"true", "seen[id] = true", "seen[-1] = 1 < 2"}
+
+	diags := Analyze(pkg, files, info)
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics, want 1", len(diags))
+	}
+	var got []string
+	for _, r := range diags[0].Related {
+		got = append(got, text(r.Pos, r.End))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("related = %q, want %q", got, want)
+	}
+
+	got = nil
+	pass := &analysis.Pass{
+		Analyzer:  NewAnalyzer(),
+		Fset:      fset,
+		Files:     files,
+		Pkg:       pkg,
+		TypesInfo: info,
+		Report: func(diag analysis.Diagnostic) {
+			for _, r := range diag.Related {
+				got = append(got, text(r.Pos, r.End))
+			}
+		},
+	}
+	if _, err := pass.Analyzer.Run(pass); err != nil {
+		t.Fatalf("analyzer run error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("analysis related = %q, want %q", got, want)
+	}
+}
+
+func TestGoroutineWrites(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+import "sync"
+
+func f(ids []int) {
+	var mu sync.Mutex
+	seen := map[int]bool{}
+	seen[0] = true
+	add := func(id int) {
+		mu.Lock()
+		seen[id] = true
+		mu.Unlock()
+	}
+	for _, id := range ids {
+		go add(id)
+		go func() {
+			mu.Lock()
+			seen[-id] = true
+			mu.Unlock()
+		}()
+	}
+}
+`
+	_, files, pkg, info := typeCheck(t, src)
+	diags := Analyze(pkg, files, info)
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics, want 1", len(diags))
+	}
+	var got []string
+	for _, r := range diags[0].Related {
+		got = append(got, r.Message)
+	}
+	want := []string{"true stored here", "true stored here, in a goroutine", "true stored here, in a goroutine"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("related = %q, want %q", got, want)
+	}
+}
+
+func TestDiagnosticReads(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func f(ids []int) int {
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+	}
+	_, ok := seen[0]
+	var _, ok2 = seen[1]
+	n := 0
+	for range seen {
+		n++
+	}
+	if ok && ok2 {
+		return n
+	}
+	return len(seen)
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	diags := Analyze(pkg, files, info)
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics, want 1", len(diags))
+	}
+	var got []string
+	for _, r := range diags[0].Reads {
+		got = append(got, fmt.Sprintf("%d: %s %s", fset.Position(r.Pos).Line, r.Message, src[fset.Position(r.Pos).Offset:fset.Position(r.End).Offset]))
+	}
+	want := []string{
+		"6: lookup seen[id]",
+		"11: comma-ok lookup seen[0]",
+		"12: comma-ok lookup seen[1]",
+		"14: range for range seen",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("reads = %q, want %q", got, want)
+	}
+}
+
+func TestAnalyzeFindings(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+type ID int
+
+func f(ids []ID) bool {
+	set := map[string]bool{}
+	set["a"] = true
+	seen := map[ID]bool{}
+	for _, id := range ids {
+		seen[id] = true
+	}
+	return seen[0]
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	findings := AnalyzeFindings(pkg, files, info, DefaultOptions())
+	sort.Slice(findings, func(i, j int) bool { return findings[i].Pos < findings[j].Pos })
+	if len(findings) != 2 {
+		t.Fatalf("got %d findings, want 2", len(findings))
+	}
+	type summary struct {
+		Name, KeyType, ElemType string
+		Line, Writes, Reads     int
+		Fixable                 bool
+	}
+	var got []summary
+	for _, f := range findings {
+		got = append(got, summary{f.Name, f.KeyType, f.ElemType, fset.Position(f.Defined).Line, f.Writes, f.Reads, f.Fixable})
+	}
+	want := []summary{
+		{"set", "string", "bool", 6, 1, 0, true},
+		{"seen", "ID", "bool", 8, 1, 1, false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("findings = %+v, want %+v", got, want)
+	}
+}
+
+func TestElementMapNames(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+type registry struct {
+	byKind map[string][]map[string]bool
+}
+
+func f(r *registry, a, b string) {
+	index := map[string]map[string]map[string]bool{}
+	index[a][b]["c"] = true
+	r.byKind[a][0][b] = true
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	var got []string
+	for _, f := range AnalyzeFindings(pkg, files, info, DefaultOptions()) {
+		span := fset.Position(f.End).Offset - fset.Position(f.Pos).Offset
+		got = append(got, fmt.Sprintf("%s %s %d", f.Name, f.Object.Name(), span))
+	}
+	sort.Strings(got)
+	// The held maps are reported under their holder's name, spanning it.
+	if want := []string{"byKind byKind 6", "index index 5"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("findings = %q, want %q", got, want)
+	}
+}
+
+func TestAnalyzeFunc(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func f() {
+	a := map[string]bool{}
+	a["x"] = true
+	b := map[int]bool{1: true}
+	_ = b
+}
+`
+	_, files, pkg, info := typeCheck(t, src)
+	var streamed []string
+	AnalyzeFunc(pkg, files, info, func(f Finding) {
+		streamed = append(streamed, f.Name)
+	})
+	var collected []string
+	for _, diag := range Analyze(pkg, files, info) {
+		collected = append(collected, diag.Object.Name())
+	}
+	sort.Strings(streamed)
+	sort.Strings(collected)
+	if want := []string{"a", "b"}; !reflect.DeepEqual(streamed, want) || !reflect.DeepEqual(collected, want) {
+		t.Fatalf("streamed %v and collected %v, want %v", streamed, collected, want)
+	}
+
+	// Findings of registered checks are streamed in position order with
+	// the maps'.
+	registerChanBoolCheck()
+	src = `package chancheck
+
+func f() {
+	done := make(chan bool)
+	a := map[string]bool{}
+	a["x"] = true
+	quit := make(chan bool)
+	b := map[int]bool{1: true}
+	_, _, _ = done, quit, b
+}
+`
+	_, files, pkg, info = typeCheck(t, src)
+	streamed = nil
+	AnalyzeFunc(pkg, files, info, func(f Finding) {
+		streamed = append(streamed, f.Name)
+	})
+	if want := []string{"done", "a", "quit", "b"}; !reflect.DeepEqual(streamed, want) {
+		t.Fatalf("streamed %v, want %v", streamed, want)
+	}
+}
+
+func TestAnalyzeOrder(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+type S[T comparable] struct {
+	m map[T]bool
+}
+
+func use() {
+	var s S[string]
+	s.m = map[string]bool{}
+	s.m["x"] = true
+	var u S[int]
+	u.m = map[int]bool{}
+	u.m[1] = true
+	a := map[int]bool{}
+	a[1] = true
+	b := map[int]bool{}
+	b[2] = true
+	c := map[int]bool{}
+	c[3] = true
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	// The field's two instantiations are distinct objects declared at the
+	// same position; only the one keyed by int is reported.
+	want := []string{"4:2 int", "14:2 int", "16:2 int", "18:2 int"}
+	for range 10 {
+		var got []string
+		for _, f := range AnalyzeFindings(pkg, files, info, DefaultOptions()) {
+			p := fset.Position(f.Pos)
+			got = append(got, fmt.Sprintf("%d:%d %s", p.Line, p.Column, f.KeyType))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Analyze reported at %v, want %v", got, want)
+		}
+	}
+}
+
+func TestAnalyzeContext(t *testing.T) {
+	t.Parallel()
+
+	src := "package p\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[\"a\"] = true\n}\n"
+	_, files, pkg, info := typeCheck(t, src)
+	diags, err := AnalyzeContext(context.Background(), pkg, files, info)
+	if err != nil || len(diags) != 1 {
+		t.Fatalf("AnalyzeContext = %d diagnostics, %v; want 1, nil", len(diags), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	diags, err = AnalyzeContext(ctx, pkg, files, info)
+	if !errors.Is(err, context.Canceled) || diags != nil {
+		t.Fatalf("AnalyzeContext after cancel = %v, %v; want nil, context.Canceled", diags, err)
+	}
+}
+
+func TestAnalyzeInspector(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+type S struct{ m map[int]bool }
+
+func f(s *S, ids []int) bool {
+	seen := map[int]bool{}
+	for _, id := range ids {
+		seen[id] = true
+		s.m[id] = true
+	}
+	_, ok := seen[0]
+	return ok && s.m[1]
+}
+`
+	_, files, pkg, info := typeCheck(t, src)
+	want, err := AnalyzeContext(context.Background(), pkg, files, info)
+	if err != nil || len(want) != 2 {
+		t.Fatalf("AnalyzeContext = %d diagnostics, %v; want 2", len(want), err)
+	}
+	got, err := AnalyzeInspector(context.Background(), pkg, files, info, inspector.New(files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeInspector = %+v\nwant %+v", got, want)
+	}
+
+	if !slices.Contains(NewAnalyzer().Requires, inspect.Analyzer) {
+		t.Error("the analyzer does not require inspect.Analyzer")
+	}
+}
+
+func TestAnalyzeInvalidInput(t *testing.T) {
+	t.Parallel()
+
+	src := "package p\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[\"a\"] = true\n}\n"
+	_, files, pkg, info := typeCheck(t, src)
+	tests := []struct {
+		name  string
+		pkg   *types.Package
+		files []*ast.File
+		info  *types.Info
+	}{
+		{"nil package", nil, files, info},
+		{"nil info", pkg, files, nil},
+		{"info without Defs", pkg, files, &types.Info{Types: info.Types, Uses: info.Uses}},
+		{"nil file", pkg, []*ast.File{nil}, info},
+		{"other package", types.NewPackage("q", "q"), files, info},
+	}
+	for _, tc := range tests {
+		diags, err := AnalyzeContext(context.Background(), tc.pkg, tc.files, tc.info)
+		if !errors.Is(err, ErrInvalidInput) || diags != nil {
+			t.Errorf("%s: AnalyzeContext = %v, %v; want nil, ErrInvalidInput", tc.name, diags, err)
+		}
+	}
+	if diags, err := AnalyzeContext(context.Background(), pkg, nil, info); err != nil || diags != nil {
+		t.Errorf("AnalyzeContext without files = %v, %v; want nil, nil", diags, err)
+	}
+}
+
+func TestAnalyzePartial(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+var global = map[string]bool{}
+
+func f() {
+	global["a"] = true
+	local := map[int]bool{}
+	local[1] = true
+}
+
+func g() {
+	other := map[int]bool{}
+	other[1] = true
+	global["b"] = true
+}
+`
+	_, files, pkg, info := typeCheck(t, src)
+	names := func(diags []Diagnostic) []string {
+		var got []string
+		for _, diag := range diags {
+			got = append(got, diag.Object.Name())
+		}
+		sort.Strings(got)
+		return got
+	}
+	decls := make(map[string]*ast.FuncDecl)
+	for _, decl := range files[0].Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			decls[fn.Name.Name] = fn
+		}
+	}
+
+	if got, want := names(AnalyzeFile(pkg, files[0], info)), []string{"global", "local", "other"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeFile reported %v, want %v", got, want)
+	}
+	// global is declared outside f and written by g as well.
+	if got, want := names(AnalyzeFuncDecl(pkg, decls["f"], info)), []string{"local"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeFuncDecl(f) reported %v, want %v", got, want)
+	}
+	if got, want := names(AnalyzeFuncDecl(pkg, decls["g"], info)), []string{"other"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeFuncDecl(g) reported %v, want %v", got, want)
+	}
+}
+
+func TestAnalyzeWithOptions(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+var global = map[string]bool{}
+
+type S struct {
+	field map[int]bool
+}
+
+func f(s *S) {
+	global["a"] = true
+	s.field[1] = true
+	local := map[string]bool{}
+	local["a"] = true
+	local["b"] = true
+}
+`
+	_, files, pkg, info := typeCheck(t, src)
+	intKeys := func(key types.Type) bool { return !types.Identical(key, types.Typ[types.Int]) }
+	tests := []struct {
+		name string
+		opts func(*Options)
+		want []string
+	}{
+		{"defaults", func(*Options) {}, []string{"field", "global", "local"}},
+		{"min writes", func(o *Options) { o.MinWrites = 2 }, []string{"local"}},
+		{"no fields", func(o *Options) { o.ExcludeFields = true }, []string{"global", "local"}},
+		{"no globals", func(o *Options) { o.ExcludeGlobals = true }, []string{"field", "local"}},
+		{"key filter", func(o *Options) { o.KeyTypeFilter = intKeys }, []string{"global", "local"}},
+		{"checks", func(o *Options) { o.Checks = []string{RuleMapBoolSet} }, []string{"field", "global", "local"}},
+		{"no checks", func(o *Options) { o.Checks = []string{} }, nil},
+		{"filter", func(o *Options) { o.Filter = func(f Finding) bool { return f.Name != "global" } }, []string{"field", "local"}},
+	}
+	for _, tc := range tests {
+		opts := DefaultOptions()
+		tc.opts(&opts)
+		var got []string
+		for _, diag := range AnalyzeWithOptions(pkg, files, info, opts) {
+			got = append(got, nameAt(files[0], diag.Pos))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: reported %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// The zero value reports what Analyze does.
+	if got, want := AnalyzeWithOptions(pkg, files, info, Options{}), Analyze(pkg, files, info); !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeWithOptions(Options{}) = %+v, want %+v", got, want)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	t.Parallel()
+
+	src := "package p\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[\"a\"] = true\n}\n"
+	_, files, pkg, info := typeCheck(t, src)
+	if diags := Analyze(pkg, files, info); len(diags) != 1 || diags[0].Severity != SeverityWarning {
+		t.Fatalf("Analyze = %+v, want one warning", diags)
+	}
+	opts := DefaultOptions()
+	opts.Severities = map[string]Severity{RuleMapBoolSet: SeverityError}
+	if diags := AnalyzeWithOptions(pkg, files, info, opts); len(diags) != 1 || diags[0].Severity != SeverityError {
+		t.Fatalf("AnalyzeWithOptions = %+v, want one error", diags)
+	}
+
+	for _, sev := range []Severity{SeverityInfo, SeverityWarning, SeverityError} {
+		text, err := sev.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Severity
+		if err := got.UnmarshalText(text); err != nil || got != sev {
+			t.Errorf("round trip of %v = %v, %v", sev, got, err)
+		}
+	}
+	if err := new(Severity).UnmarshalText([]byte("fatal")); err == nil {
+		t.Error("UnmarshalText accepted an unknown severity")
+	}
+}
+
+func TestLinter(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+var global = map[string]bool{}
+
+func f() {
+	global["a"] = true
+	global["b"] = true
+	once := map[string]bool{}
+	once["a"] = true
+	twice := map[string]bool{}
+	twice["a"] = true
+	twice["b"] = true
+}
+`
+	_, files, pkg, info := typeCheck(t, src)
+	l := New(WithMinWrites(2), WithGlobals(false), WithSeverity(RuleMapBoolSet, SeverityError))
+	findings, err := l.Analyze(context.Background(), pkg, files, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 1 || findings[0].Name != "twice" || findings[0].Severity != SeverityError {
+		t.Fatalf("Analyze = %+v, want twice as an error", findings)
+	}
+	if opts := l.Options(); opts.MinWrites != 2 || !opts.ExcludeGlobals || opts.ExcludeFields {
+		t.Errorf("Options = %+v", opts)
+	}
+	if opts := New(WithSSA(true)).Options(); !opts.SSA {
+		t.Error("WithSSA(true) left SSA off")
+	}
+	if opts := New(WithSSA(true), WithSSA(false)).Options(); opts.SSA {
+		t.Error("WithSSA(false) left SSA on")
+	}
+
+	if _, err := New().Analyze(context.Background(), nil, files, info); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("Analyze(nil package) error = %v, want ErrInvalidInput", err)
+	}
+	findings, err = New(WithChecks()).Analyze(context.Background(), pkg, files, info)
+	if err != nil || len(findings) != 0 {
+		t.Errorf("Analyze with no checks = %v, %v; want none", findings, err)
+	}
+}
+
+func TestMessageTemplates(t *testing.T) {
+	t.Parallel()
+
+	src := "package p\n\nfunc f() {\n\tseen := map[int]bool{}\n\tseen[1] = true\n}\n"
+	_, files, pkg, info := typeCheck(t, src)
+	tmpl := template.Must(template.New("").Parse("use sets.Set[{{.KeyType}}] for {{.Name}} ({{.Writes}} writes)"))
+	findings, err := New(WithMessage(RuleMapBoolSet, tmpl)).Analyze(context.Background(), pkg, files, info)
+	if err != nil || len(findings) != 1 {
+		t.Fatalf("Analyze = %v, %v; want one finding", findings, err)
+	}
+	if want := "use sets.Set[int] for seen (1 writes)"; findings[0].Message != want {
+		t.Errorf("Message = %q, want %q", findings[0].Message, want)
+	}
+
+	failing := template.Must(template.New("").Parse("{{.Missing}}"))
+	findings, _ = New(WithMessage(RuleMapBoolSet, failing)).Analyze(context.Background(), pkg, files, info)
+	if want := "map[int]bool only stores \"true\" values; consider map[int]struct{}"; len(findings) != 1 || findings[0].Message != want {
+		t.Errorf("with a failing template, findings = %+v, want the default message", findings)
+	}
+}
+
+func TestLocale(t *testing.T) {
+	t.Parallel()
+
+	src := "package p\n\nfunc f() {\n\tseen := map[int]bool{}\n\tseen[1] = true\n}\n"
+	_, files, pkg, info := typeCheck(t, src)
+	tests := []struct {
+		locale, message, fix string
+	}{
+		{"", `map[int]bool only stores "true" values; consider map[int]struct{}`, "Convert seen to map[int]struct{}"},
+		{"C", `map[int]bool only stores "true" values; consider map[int]struct{}`, "Convert seen to map[int]struct{}"},
+		{"ja_JP.UTF-8", `map[int]bool only stores "true" values; consider map[int]struct{}`, "Convert seen to map[int]struct{}"},
+		{"de_DE.UTF-8", "map[int]bool speichert nur „true“; besser map[int]struct{}", "seen in map[int]struct{} umwandeln"},
+		{"fr", "map[int]bool ne stocke que « true » ; préférez map[int]struct{}", "Convertir seen en map[int]struct{}"},
+	}
+	for _, tc := range tests {
+		findings, err := New(WithLocale(tc.locale)).Analyze(context.Background(), pkg, files, info)
+		if err != nil || len(findings) != 1 {
+			t.Fatalf("%q: Analyze = %v, %v; want one finding", tc.locale, findings, err)
+		}
+		if f := findings[0]; f.Message != tc.message || f.FixMessage != tc.fix {
+			t.Errorf("%q: message %q, fix %q; want %q, %q", tc.locale, f.Message, f.FixMessage, tc.message, tc.fix)
+		}
+	}
+
+	// Every catalog must parse and translate every built-in rule.
+	for _, lang := range Locales() {
+		c := catalog(lang)
+		if c == nil {
+			t.Errorf("catalog %s does not parse", lang)
+			continue
+		}
+		for _, name := range []string{RuleMapBoolSet, RuleMapBoolSet + ".fix"} {
+			if c.Lookup(name) == nil {
+				t.Errorf("catalog %s lacks %s", lang, name)
+			}
+		}
+	}
+}
+
+// nameAt returns the identifier starting at pos.
+func nameAt(file *ast.File, pos token.Pos) string {
+	var name string
+	ast.Inspect(file, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Pos() == pos {
+			name = id.Name
+		}
+		return name == ""
+	})
+	return name
+}
+
+func TestAnalyzerResult(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func f(v bool) bool {
+	seen := map[string]bool{"a": true}
+	seen["b"] = true
+	flags := map[string]bool{}
+	flags["x"] = v
+	return seen["a"] || flags["x"]
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	a := NewAnalyzer()
+	pass := &analysis.Pass{
+		Analyzer:  a,
+		Fset:      fset,
+		Files:     files,
+		Pkg:       pkg,
+		TypesInfo: info,
+		Report:    func(analysis.Diagnostic) {},
+	}
+	res, err := a.Run(pass)
+	if err != nil {
+		t.Fatalf("analyzer run error: %v", err)
+	}
+	result, ok := res.(*Result)
+	if !ok {
+		t.Fatalf("result type = %T, want *Result", res)
+	}
+	if reflect.TypeOf(result) != a.ResultType {
+		t.Fatalf("ResultType = %v, want %v", a.ResultType, reflect.TypeOf(result))
+	}
+
+	usage := make(map[string]*MapUsage)
+	for obj, u := range result.Maps {
+		if u.Object != obj {
+			t.Errorf("%s: Object = %v, want key %v", obj.Name(), u.Object, obj)
+		}
+		usage[obj.Name()] = u
+	}
+	seen, flags := usage["seen"], usage["flags"]
+	if seen == nil || flags == nil {
+		t.Fatalf("Maps = %v, want seen and flags", usage)
+	}
+	if !seen.OnlyTrue || !seen.Reported || len(seen.Writes) != 2 || seen.OtherUses != 1 || seen.KeyType != "string" {
+		t.Errorf("seen = %+v, want 2 true writes, 1 other use, reported", *seen)
+	}
+	if flags.OnlyTrue || flags.Reported || len(flags.Writes) != 1 || flags.Writes[0].True {
+		t.Errorf("flags = %+v, want 1 non-true write, not reported", *flags)
+	}
+}
+
+func TestInventory(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func f(in map[string]bool) bool {
+	set := map[string]bool{}
+	set["a"] = true
+	flags := map[string]bool{}
+	flags["a"] = false
+	in["b"] = true
+	return flags["a"] || set["b"]
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	type summary struct {
+		Name               string
+		Line               int
+		Writes, Reads      int
+		OnlyTrue, Reported bool
+	}
+	var got []summary
+	for _, u := range Inventory(pkg, files, info) {
+		got = append(got, summary{u.Object.Name(), fset.Position(u.Object.Pos()).Line, len(u.Writes), len(u.Reads), u.OnlyTrue, u.Reported})
+	}
+	want := []summary{
+		{"in", 3, 1, 0, true, true},
+		{"set", 4, 1, 1, true, true},
+		{"flags", 6, 1, 1, false, false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Inventory = %+v, want %+v", got, want)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+	set["b"] = true
+	flags := map[string]bool{}
+	flags["a"] = false
+	alias := set
+	alias["c"] = true
+}
+`
+	_, files, pkg, info := typeCheck(t, src)
+	var m Metrics
+	opts := DefaultOptions()
+	opts.Metrics = &m
+	AnalyzeFindings(pkg, files, info, opts)
+	AnalyzeFindings(pkg, files, info, opts)
+	// alias is the map set is, counted once with its write.
+	if m.Files != 2 || m.Maps != 4 || m.Flagged != 2 || m.Writes != 8 {
+		t.Fatalf("Metrics = files %d, maps %d, flagged %d, writes %d; want 2, 4, 2, 8", m.Files, m.Maps, m.Flagged, m.Writes)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+import "fmt"
+
+var global = map[string]bool{}
+
+func f() {
+	global["a"] = true
+	mixed := map[string]bool{}
+	mixed["a"] = true
+	mixed["b"] = false
+	passed := map[string]bool{}
+	passed["a"] = true
+	g(passed)
+	printed := map[string]bool{}
+	printed["a"] = true
+	fmt.Print(printed)
+}
+
+func g(map[string]bool) {}
+`
+	_, files, pkg, info := typeCheck(t, src)
+	var buf bytes.Buffer
+	opts := DefaultOptions()
+	opts.ExcludeGlobals = true
+	opts.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	AnalyzeFindings(pkg, files, info, opts)
+	want := `level=DEBUG msg="map not reported" map=global reason="package-level maps are excluded"
+level=DEBUG msg="map not reported" map=mixed reason="stores values other than true"
+level=DEBUG msg="map not reported" map=printed reason="escapes: printed by fmt.Print"
+level=DEBUG msg="no fix offered" map=passed reason="escapes: used other than by its writes and initialisation"
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("logged:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// chanBoolCheck reports channels of bool declared in packages named
+// chancheck, so that registering it leaves the other tests unaffected.
+type chanBoolCheck struct {
+	pass  *CheckPass
+	diags []Diagnostic
+}
+
+func (c *chanBoolCheck) Inspect(n ast.Node, _ []ast.Node) {
+	id, ok := n.(*ast.Ident)
+	if !ok || c.pass.Pkg.Name() != "chancheck" {
+		return
+	}
+	obj := c.pass.Info.Defs[id]
+	if obj == nil {
+		return
+	}
+	if ch, ok := obj.Type().(*types.Chan); ok && isBool(ch.Elem()) {
+		c.diags = append(c.diags, Diagnostic{Pos: id.Pos(), End: id.End(), Message: "chan bool only signals", Object: obj})
+	}
+}
+
+func (c *chanBoolCheck) Finalize() []Diagnostic { return c.diags }
+
+var registerChanBool sync.Once
+
+// registerChanBoolCheck registers chanBoolCheck as XT0001 for the tests
+// that need it.
+func registerChanBoolCheck() {
+	registerChanBool.Do(func() {
+		rule := Rule{
+			ID:       "XT0001",
+			Name:     "chan-bool",
+			Summary:  "chan bool used as a signal",
+			URL:      "https://example.com/xt0001",
+			Severity: SeverityError,
+		}
+		RegisterCheck(rule, func(pass *CheckPass) Check {
+			return &chanBoolCheck{pass: pass}
+		})
+	})
+}
+
+func TestRegisterCheck(t *testing.T) {
+	t.Parallel()
+
+	registerChanBoolCheck()
+
+	src := `package chancheck
+
+func f() {
+	done := make(chan bool)
+	set := map[string]bool{}
+	set["a"] = true
+	_ = done
+	quit := make(chan bool)
+	_ = quit
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	var got []string
+	for _, diag := range Analyze(pkg, files, info) {
+		got = append(got, fmt.Sprintf("%d %s %s %s", fset.Position(diag.Pos).Line, diag.Rule, diag.URL, diag.Severity))
+	}
+	want := []string{
+		"4 XT0001 https://example.com/xt0001 error",
+		"5 BS0001 " + RuleURL(RuleMapBoolSet) + " warning",
+		"8 XT0001 https://example.com/xt0001 error",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Analyze = %q, want %q", got, want)
+	}
+
+	opts := DefaultOptions()
+	opts.Checks = []string{"XT0001"}
+	opts.Severities = map[string]Severity{"XT0001": SeverityInfo}
+	got = nil
+	for _, f := range AnalyzeFindings(pkg, files, info, opts) {
+		got = append(got, fmt.Sprintf("%s %s %s", f.Rule, f.Name, f.Severity))
+	}
+	if want := []string{"XT0001 done info", "XT0001 quit info"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("AnalyzeFindings = %q, want %q", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering XT0001 twice did not panic")
+		}
+	}()
+	RegisterCheck(Rule{ID: "XT0001"}, func(*CheckPass) Check { return nil })
+}
+
+func TestAnalyzerFacts(t *testing.T) {
+	t.Parallel()
+
+	analysistest.Run(t, analysistest.TestData(), NewAnalyzer(), "registry", "user", "printer")
+}
+
+func applyEdits(fset *token.FileSet, src string, edits []TextEdit) string {
+	sorted := append([]TextEdit(nil), edits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Pos > sorted[j].Pos })
+	for _, e := range sorted {
+		start := fset.Position(e.Pos).Offset
+		end := fset.Position(e.End).Offset
+		src = src[:start] + e.NewText + src[end:]
+	}
+	return src
+}
+
+func TestRun(t *testing.T) {
+	t.Chdir(writeModule(t, map[string]string{
+		"a/a.go":           "package a\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[\"x\"] = true\n}\n",
+		"b/b.go":           "package b\n\nfunc g() {\n\tseen := map[int]bool{}\n\tseen[1] = true\n}\n",
+		"broken/broken.go": "package broken\n\nvar x int = \"s\"\n",
+	}))
+
+	findings, err := Run("./...")
+	if err == nil || !strings.Contains(err.Error(), "example.com/m/broken") {
+		t.Errorf("Run error = %v, want one naming the broken package", err)
+	}
+	type summary struct {
+		Package, Name, File string
+		Line, Column        int
+	}
+	var got []summary
+	for _, f := range findings {
+		got = append(got, summary{f.Package, f.Name, filepath.Base(f.Position.Filename), f.Position.Line, f.Position.Column})
+	}
+	want := []summary{
+		{"example.com/m/a", "set", "a.go", 4, 2},
+		{"example.com/m/b", "seen", "b.go", 4, 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Run findings = %+v, want %+v", got, want)
+	}
+	if end := findings[0].End; end.Line != 4 || end.Column != 5 {
+		t.Errorf("End = %v, want line 4, column 5", end)
+	}
+
+	data, err := json.Marshal(findings[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	wantJSON := map[string]any{
+		"file": findings[0].Position.Filename, "line": 4.0, "column": 2.0, "endLine": 4.0, "endColumn": 5.0,
+		"rule": RuleMapBoolSet, "severity": "warning", "message": diagMsg, "url": RuleURL(RuleMapBoolSet),
+		"package": "example.com/m/a", "name": "set", "keyType": "string", "elemType": "bool",
+		"writes": 1.0, "reads": 0.0, "fixable": true,
+		"edits": []any{
+			map[string]any{"offset": 41.0, "end": 45.0, "newText": "struct{}"},
+			map[string]any{"offset": 60.0, "end": 64.0, "newText": "struct{}{}"},
+		},
+	}
+	if !reflect.DeepEqual(decoded, wantJSON) {
+		t.Errorf("JSON = %s, want %v", data, wantJSON)
+	}
+
+	src, err := os.ReadFile(findings[0].Position.Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixed, err := ApplyEdits(src, findings[0].Edits)
+	if err != nil {
+		t.Fatalf("ApplyEdits: %v", err)
+	}
+	if want := "package a\n\nfunc f() {\n\tset := map[string]struct{}{}\n\tset[\"x\"] = struct{}{}\n}\n"; string(fixed) != want {
+		t.Errorf("ApplyEdits = %q, want %q", fixed, want)
+	}
+	overlapping := append(findings[0].Edits, findings[0].Edits[0])
+	if _, err := ApplyEdits(src, overlapping); err == nil {
+		t.Error("ApplyEdits accepted overlapping edits")
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	t.Chdir(writeModule(t, map[string]string{
+		"registry/registry.go": "package registry\n\nvar Handlers = map[string]bool{}\n",
+		"user/user.go":         "package user\n\nimport \"example.com/m/registry\"\n\nfunc init() {\n\tregistry.Handlers[\"user\"] = true\n}\n",
+		"broken/broken.go":     "package broken\n\nvar x int = \"s\"\n",
+	}))
+
+	results, err := RunBatch(context.Background(), []string{"./..."}, DefaultOptions())
+	if err != nil {
+		t.Fatalf("RunBatch: %v", err)
+	}
+	var got []string
+	for _, r := range results {
+		line := r.Package
+		for _, f := range r.Findings {
+			line += fmt.Sprintf(" %s@%d", f.Name, f.Position.Line)
+		}
+		if r.Err != nil {
+			line += " error"
+		}
+		got = append(got, line)
+	}
+	want := []string{"example.com/m/broken error", "example.com/m/registry", "example.com/m/user Handlers@6"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("RunBatch results = %q, want %q", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunBatch(ctx, []string{"./..."}, DefaultOptions()); err == nil {
+		t.Fatal("RunBatch with a cancelled context succeeded")
+	}
+}
+
+// writeModule creates the module example.com/m holding files, keyed by
+// slash-separated path, and returns its directory.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files["go.mod"] = "module example.com/m\n\ngo 1.24\n"
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func typeCheck(t *testing.T, src string) (*token.FileSet, []*ast.File, *types.Package, *types.Info) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	files := []*ast.File{file}
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+	}
+
+	conf := types.Config{
+		Importer: importer.Default(),
+		Error:    func(err error) {},
+	}
+	pkg, err := conf.Check(file.Name.Name, fset, files, info)
+	if err != nil && pkg == nil {
+		t.Fatalf("type check error: %v", err)
+	}
+	return fset, files, pkg, info
+}
+
+func runNewAnalyzer(t *testing.T, src string) []string {
+	t.Helper()
+
+	fset, files, pkg, info := typeCheck(t, src)
+
+	var messages []string
+	pass := &analysis.Pass{
+		Analyzer:  NewAnalyzer(),
+		Fset:      fset,
+		Files:     files,
+		Pkg:       pkg,
+		TypesInfo: info,
+		Report: func(diag analysis.Diagnostic) {
+			if diag.Category != RuleMapBoolSet {
+				t.Errorf("unexpected diagnostic category %q, want %q", diag.Category, RuleMapBoolSet)
+			}
+			messages = append(messages, diag.Message)
+		},
+	}
+
+	_, err := NewAnalyzer().Run(pass)
+	if err != nil {
+		t.Fatalf("analyzer run error: %v", err)
+	}
+
+	return messages
+}
+
+func TestReporters(t *testing.T) {
+	f := PositionedFinding{
+		Finding:  Finding{Diagnostic: Diagnostic{Rule: RuleMapBoolSet, Message: "map[string]bool used as a set"}, Name: "seen"},
+		Position: token.Position{Filename: "p.go", Line: 3, Column: 2},
+	}
+	for _, tc := range []struct{ format, want string }{
+		{"text", "p.go:3:2: map[string]bool used as a set (BS0001)\n"},
+		{"json", `"file":"p.go","line":3,"column":2,`},
+	} {
+		var buf bytes.Buffer
+		r, ok := NewReporter(tc.format, &buf)
+		if !ok {
+			t.Fatalf("NewReporter(%q) not found", tc.format)
+		}
+		if err := r.Begin(); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Report(f); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.End(); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), tc.want) {
+			t.Errorf("%s reporter wrote %q, want %q", tc.format, buf.String(), tc.want)
+		}
+	}
+	if _, ok := NewReporter("xml", io.Discard); ok {
+		t.Error("NewReporter found an unregistered format")
+	}
+
+	RegisterReporter("reporters-test", func(w io.Writer) Reporter { r, _ := NewReporter("text", w); return r })
+	if names := Reporters(); !slices.Contains(names, "reporters-test") || !slices.IsSorted(names) {
+		t.Errorf("Reporters() = %q", names)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a reporter name twice did not panic")
+		}
+	}()
+	RegisterReporter("json", func(io.Writer) Reporter { return nil })
+}
+
+func TestRules(t *testing.T) {
+	registerChanBoolCheck()
+	rules := Rules()
+	if len(rules) == 0 || rules[0].ID != RuleMapBoolSet || rules[0].Severity != SeverityWarning || rules[0].URL != RuleURL(RuleMapBoolSet) {
+		t.Fatalf("Rules()[0] = %+v", rules)
+	}
+	if !slices.IsSortedFunc(rules, func(a, b Rule) int { return strings.Compare(a.ID, b.ID) }) {
+		t.Errorf("Rules() not sorted: %+v", rules)
+	}
+	registered := func(r Rule) bool {
+		return r.ID == "XT0001" && r.Name == "chan-bool" && r.Summary != "" && r.Severity == SeverityError
+	}
+	if !slices.ContainsFunc(rules, registered) {
+		t.Errorf("Rules() misses the registered check XT0001: %+v", rules)
+	}
+
+	for _, tc := range []struct {
+		info *debug.BuildInfo
+		want string
+	}{
+		{&debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "v1.2.0"}}, "v1.2.0"},
+		{&debug.BuildInfo{Main: debug.Module{Path: modulePath}}, "(devel)"},
+		{&debug.BuildInfo{Main: debug.Module{Path: "example.com/app"}, Deps: []*debug.Module{{Path: modulePath, Version: "v1.3.1"}}}, "v1.3.1"},
+		{&debug.BuildInfo{Main: debug.Module{Path: "example.com/app"}, Deps: []*debug.Module{{Path: modulePath, Version: "v1.3.1", Replace: &debug.Module{Path: "../boolset"}}}}, "(devel)"},
+		{&debug.BuildInfo{Main: debug.Module{Path: "example.com/app"}}, "(devel)"},
+	} {
+		if got := moduleVersion(tc.info); got != tc.want {
+			t.Errorf("moduleVersion(%+v) = %q, want %q", tc.info, got, tc.want)
+		}
+	}
+	if Version() == "" {
+		t.Error("Version() is empty")
+	}
+}
+
+func TestAnalyzeSource(t *testing.T) {
+	t.Parallel()
+
+	findings, err := AnalyzeSource(map[string]string{
+		"b.go": "package p\n\nvar seen = map[string]bool{}\n",
+		"a.go": "package p\n\nimport \"strings\"\n\nfunc add(s string) {\n\tseen[strings.ToLower(s)] = true\n}\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range findings {
+		got = append(got, fmt.Sprintf("%s:%d %s %s %s", f.Position.Filename, f.Position.Line, f.Package, f.Rule, f.Name))
+	}
+	if want := []string{"b.go:3 p BS0001 seen"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeSource = %q, want %q", got, want)
+	}
+
+	if _, err := AnalyzeSource(map[string]string{"p.go": "package p\n\nvar x int = \"s\"\n"}); err == nil || !strings.Contains(err.Error(), "p.go:3") {
+		t.Errorf("type error: err = %v", err)
+	}
+	if _, err := AnalyzeSource(map[string]string{"p.go": "package p\n\nfunc {\n"}); err == nil {
+		t.Error("parse error: no error")
+	}
+	if findings, err := AnalyzeSource(nil); findings != nil || err != nil {
+		t.Errorf("AnalyzeSource(nil) = %v, %v", findings, err)
+	}
+}
+
+func TestSSA(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func yes() bool { return true }
+
+func viaCall(k string) {
+	called := map[string]bool{}
+	called[k] = yes()
+}
+
+func viaShortCircuit(k string, c bool) {
+	flag := c || true
+	shorted := map[string]bool{}
+	shorted[k] = flag
+}
+
+func viaPointer(k string) {
+	flag := true
+	p := &flag
+	*p = false
+	aliased := map[string]bool{}
+	aliased[k] = flag
+}
+
+func viaClosure(keys []string) {
+	ok := true
+	mark := func() { ok = true }
+	marked := map[string]bool{}
+	for _, k := range keys {
+		mark()
+		marked[k] = ok
+	}
+}
+
+func viaCopy(k string) {
+	seen := map[string]bool{"a": true}
+	copied := map[string]bool{}
+	if _, ok := seen[k]; ok {
+		copied[k] = seen[k]
+	}
+	ranged := map[string]bool{}
+	for k, v := range seen {
+		ranged[k] = v
+	}
+}
+`
+	_, files, pkg, info := typeCheck(t, src)
+	names := func(findings []Finding) []string {
+		var names []string
+		for _, f := range findings {
+			names = append(names, f.Name)
+		}
+		return names
+	}
+	opts := DefaultOptions()
+	if got, want := names(AnalyzeFindings(pkg, files, info, opts)), []string{"marked", "seen", "copied", "ranged"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("without SSA: reported %q, want %q", got, want)
+	}
+	opts.SSA = true
+	if got, want := names(AnalyzeFindings(pkg, files, info, opts)), []string{"called", "shorted", "marked", "seen", "copied", "ranged"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with SSA: reported %q, want %q", got, want)
+	}
+
+	// Type information SSA cannot be built from falls back to the syntax.
+	_, files, pkg, info = typeCheck(t, "package p\n\ntype S struct{ m map[string]bool }\n\nfunc (s *S) add(k string) { s.m[k] = true }\n")
+	info.Selections = nil
+	var buf bytes.Buffer
+	opts.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if got, want := names(AnalyzeFindings(pkg, files, info, opts)), []string{"m"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("without Selections: reported %q, want %q", got, want)
+	}
+	if !strings.Contains(buf.String(), `msg="SSA unavailable"`) {
+		t.Errorf("no fallback logged:\n%s", buf.String())
+	}
+}
+
+func TestAliasesReportedOnce(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func (s *S) add(k string) {
+	local := s.set
+	local[k] = true
+	other := local
+	other[k] = true
+}
+
+type S struct {
+	set map[string]bool
+}
+`
+	_, files, pkg, info := typeCheck(t, src)
+	diags := Analyze(pkg, files, info)
+	if len(diags) != 1 {
+		t.Fatalf("got %d diagnostics, want 1", len(diags))
+	}
+	if name := diags[0].Object.Name(); name != "set" {
+		t.Fatalf("reported %s, want the field set", name)
+	}
+	if len(diags[0].Related) != 2 {
+		t.Fatalf("got %d related writes, want 2", len(diags[0].Related))
+	}
+}
+
+func TestGenericSetType(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+type Set[T comparable] map[T]bool
+
+func (s Set[T]) Add(v T) { s[v] = true }
+
+func (s Set[T]) Union(o Set[T]) {
+	for k := range o {
+		s[k] = true
+	}
+}
+
+func f() {
+	s := Set[int]{}
+	s.Add(1)
+	s.Union(Set[int]{2: true})
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	findings := AnalyzeFindings(pkg, files, info, DefaultOptions())
+	if len(findings) != 1 {
+		t.Fatalf("got %d findings, want 1", len(findings))
+	}
+	f := findings[0]
+	if p := fset.Position(f.Pos); p.Line != 3 || f.Name != "Set" {
+		t.Fatalf("reported %s at %v, want Set at line 3", f.Name, p)
+	}
+	if want := "map[T]bool only stores \"true\" values; consider map[T]struct{}"; f.Message != want {
+		t.Fatalf("message = %q, want %q", f.Message, want)
+	}
+}
+
+func TestGenericKeys(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		src  string
+		ssa  bool
+		want []string
+	}{
+		{
+			name: "type parameter key",
+			src: `package p
+
+func addAll[K comparable](keys []K) map[K]bool {
+	seen := map[K]bool{}
+	for _, k := range keys {
+		seen[k] = true
+	}
+	return seen
+}
+`,
+			want: []string{"map[K]bool only stores \"true\" values; consider map[K]struct{}"},
+		},
+		{
+			name: "type parameter key read back",
+			src: `package p
+
+func dedupe[K comparable](keys []K) []K {
+	seen := make(map[K]bool)
+	var out []K
+	for _, k := range keys {
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	return out
+}
+`,
+			want: []string{"map[K]bool only stores \"true\" values; consider map[K]struct{}"},
+		},
+		{
+			name: "type parameter key storing false",
+			src: `package p
+
+func f[K comparable, V any](m map[K]V) {
+	seen := map[K]bool{}
+	for k := range m {
+		seen[k] = false
+	}
+}
+`,
+		},
+		{
+			name: "constrained element key",
+			src: `package p
+
+func f[S ~[]E, E comparable](s S) {
+	seen := map[E]bool{}
+	for _, e := range s {
+		seen[e] = true
+	}
+}
+`,
+			want: []string{"map[E]bool only stores \"true\" values; consider map[E]struct{}"},
+		},
+		{
+			name: "generic struct key",
+			src: `package p
+
+type pair[A, B comparable] struct {
+	a A
+	b B
+}
+
+func f[A, B comparable](ps []pair[A, B]) {
+	seen := map[pair[A, B]]bool{}
+	for _, p := range ps {
+		seen[p] = true
+	}
+}
+`,
+			want: []string{"map[pair[A, B]]bool only stores \"true\" values; consider map[pair[A, B]]struct{}"},
+		},
+		{
+			name: "generic method with SSA",
+			src: `package p
+
+func yes() bool { return true }
+
+type G[T comparable] struct{ m map[T]bool }
+
+func (g *G[T]) add(v T) { g.m[v] = yes() }
+`,
+			ssa:  true,
+			want: []string{"map[T]bool only stores \"true\" values; consider map[T]struct{}"},
+		},
+	}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, files, pkg, info := typeCheck(t, tc.src)
+			opts := DefaultOptions()
+			opts.SSA = tc.ssa
+			var got []string
+			for _, f := range AnalyzeFindings(pkg, files, info, opts) {
+				got = append(got, f.Message)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("reported %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHelperWrites(t *testing.T) {
+	t.Parallel()
+
+	src := `package p
+
+func mark(m map[string]bool, k string) { m[k] = true }
+
+func f(keys []string) {
+	set := map[string]bool{}
+	set[""] = true
+	for _, k := range keys {
+		mark(set, k)
+	}
+}
+`
+	fset, files, pkg, info := typeCheck(t, src)
+	findings := AnalyzeFindings(pkg, files, info, DefaultOptions())
+	if len(findings) != 1 || findings[0].Name != "set" {
+		t.Fatalf("got %d findings, want set alone", len(findings))
+	}
+	var lines []int
+	for _, r := range findings[0].Related {
+		lines = append(lines, fset.Position(r.Pos).Line)
+	}
+	if want := []int{3, 7}; !reflect.DeepEqual(lines, want) {
+		t.Fatalf("writes related at lines %v, want %v", lines, want)
+	}
 }
@@ -0,0 +1,59 @@
+// Package boolsettest runs the boolset analyzer over golden test packages, so
+// code embedding or extending the analyzer can be tested the way boolset
+// tests itself.
+//
+// Test data follows the analysistest layout. Each package lives in
+// dir/src/<import path>, and every expected diagnostic is declared by a
+// comment of the form
+//
+//	seen[k] = true // want `map\[string\]bool only stores "true" values`
+//
+// on the line it is reported at. For RunWithSuggestedFixes, every file whose
+// diagnostics carry fixes has a sibling <file>.golden holding the source with
+// the fixes applied.
+package boolsettest
+
+import (
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/arturmelanchyk/boolset/boolset"
+)
+
+// TestData returns the absolute path of the testdata directory of the
+// calling test's package.
+func TestData() string {
+	return analysistest.TestData()
+}
+
+// Run analyses the packages matching patterns under dir with a new boolset
+// analyzer, configured by flags keyed by flag name without the leading
+// dash, and checks the diagnostics against the want comments.
+func Run(t analysistest.Testing, dir string, flags map[string]string, patterns ...string) []*analysistest.Result {
+	a, ok := newAnalyzer(t, flags)
+	if !ok {
+		return nil
+	}
+	return analysistest.Run(t, dir, a, patterns...)
+}
+
+// RunWithSuggestedFixes behaves like Run and also checks that applying the
+// suggested fixes produces each file's .golden counterpart.
+func RunWithSuggestedFixes(t analysistest.Testing, dir string, flags map[string]string, patterns ...string) []*analysistest.Result {
+	a, ok := newAnalyzer(t, flags)
+	if !ok {
+		return nil
+	}
+	return analysistest.RunWithSuggestedFixes(t, dir, a, patterns...)
+}
+
+func newAnalyzer(t analysistest.Testing, flags map[string]string) (*analysis.Analyzer, bool) {
+	a := boolset.NewAnalyzer()
+	for name, value := range flags {
+		if err := a.Flags.Set(name, value); err != nil {
+			t.Errorf("boolsettest: set -%s: %v", name, err)
+			return nil, false
+		}
+	}
+	return a, true
+}
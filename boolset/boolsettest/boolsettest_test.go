@@ -0,0 +1,37 @@
+package boolsettest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRunWithSuggestedFixes(t *testing.T) {
+	RunWithSuggestedFixes(t, TestData(), nil, "sets")
+}
+
+func TestRunFlags(t *testing.T) {
+	// With a higher threshold nothing in sets is reported, so the want
+	// comment goes unmatched.
+	rec := &recorder{}
+	Run(rec, TestData(), map[string]string{"min-writes": "2"}, "sets")
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "no diagnostic was reported") {
+		t.Fatalf("errors = %q, want one unmatched expectation", rec.errors)
+	}
+
+	rec = &recorder{}
+	if results := Run(rec, TestData(), map[string]string{"no-such-flag": "1"}, "sets"); results != nil {
+		t.Fatalf("results = %v, want nil for an unknown flag", results)
+	}
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "no-such-flag") {
+		t.Fatalf("errors = %q, want the unknown flag reported", rec.errors)
+	}
+}
+
+type recorder struct {
+	errors []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,22 @@
+package sets
+
+func unique(names []string) []string {
+	seen := map[string]bool{} // want `map\[string\]bool only stores "true" values; consider map\[string\]struct\{\}`
+	var out []string
+	for _, name := range names {
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = true
+		out = append(out, name)
+	}
+	return out
+}
+
+func flags(names []string, on bool) map[string]bool {
+	m := map[string]bool{}
+	for _, name := range names {
+		m[name] = on
+	}
+	return m
+}
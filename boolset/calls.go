@@ -0,0 +1,87 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/types"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// handleCall records calls that use a tracked map the way a set is used,
+// which neither store a value nor stand in the way of a conversion.
+func (a *analyzer) handleCall(call *ast.CallExpr) {
+	switch a.builtin(call) {
+	case "delete":
+		// delete(m, k) removes a member from map[T]struct{} just as well.
+		if len(call.Args) == 2 {
+			a.recordSetUse(call.Args[0])
+		}
+	case "append", "copy":
+		a.handleAppend(call)
+	case "clear":
+		// clear(m) empties the map whatever its element type; on a slice
+		// it is no map use at all.
+		if len(call.Args) == 1 {
+			a.recordSetUse(call.Args[0])
+		}
+	case "":
+		// maps.Copy(dst, src) stores src's values in dst.
+		if a.mapsFunc(call) == "Copy" && len(call.Args) == 2 {
+			if mi := a.infoFor(a.mapObject(call.Args[0])); mi != nil {
+				mi.recordAssignment(a, call.Args[1], call, call.Lparen)
+			} else if mi := a.trackedMap(a.mapObject(call.Args[0])); mi != nil {
+				// The maps src holds are then held by dst too.
+				if src := a.trackedMap(a.mapObject(call.Args[1])); src != nil {
+					a.mapAliases = append(a.mapAliases, [2]*mapInfo{mi, src})
+				}
+			}
+		}
+		a.handleEscapes(call)
+		a.handleHelperCall(call)
+		a.handleFuncValueCall(call)
+	}
+}
+
+// clonedMap returns the map expr clones with maps.Clone, or nil.
+func (a *analyzer) clonedMap(expr ast.Expr) ast.Expr {
+	call, ok := ast.Unparen(expr).(*ast.CallExpr)
+	if !ok || a.mapsFunc(call) != "Clone" || len(call.Args) != 1 {
+		return nil
+	}
+	return call.Args[0]
+}
+
+// mapsFunc returns the name of the function of the standard maps package
+// call calls, or "".
+func (a *analyzer) mapsFunc(call *ast.CallExpr) string {
+	fn := typeutil.StaticCallee(a.info, call)
+	if fn == nil || fn.Pkg() == nil || fn.Pkg().Path() != "maps" {
+		return ""
+	}
+	return fn.Name()
+}
+
+// recordSetUse accounts for a use of the tracked map expr that a
+// conversion to map[T]struct{} keeps compiling unchanged.
+func (a *analyzer) recordSetUse(expr ast.Expr) {
+	obj := a.mapObject(expr)
+	mi := a.infoFor(obj)
+	if mi == nil {
+		return
+	}
+	if a.usesObject(expr, obj) {
+		mi.knownUses++
+	}
+}
+
+// builtin returns the name of the builtin function call calls, or "".
+func (a *analyzer) builtin(call *ast.CallExpr) string {
+	id, ok := ast.Unparen(call.Fun).(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	if b, ok := a.info.Uses[id].(*types.Builtin); ok {
+		return b.Name()
+	}
+	return ""
+}
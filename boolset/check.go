@@ -0,0 +1,106 @@
+package boolset
+
+import (
+	"fmt"
+	"go/ast"
+	"go/types"
+	"sort"
+	"sync"
+)
+
+// Check is a detection run alongside the built-in BS0001 rule, sharing its
+// traversal of the syntax. A Check is created for each package analysed and
+// may keep state between calls.
+type Check interface {
+	// Inspect is called for every node of the package's syntax in
+	// depth-first order. stack holds the node's ancestors, ending with n.
+	Inspect(n ast.Node, stack []ast.Node)
+	// Finalize is called once every node has been inspected and returns
+	// the check's diagnostics. Rule and URL are filled in from the
+	// registration when left empty, and Severity is the registered one
+	// unless Options.Severities overrides it.
+	Finalize() []Diagnostic
+}
+
+// CheckPass is what a Check is given about the package it analyses.
+type CheckPass struct {
+	Pkg  *types.Package
+	Info *types.Info
+}
+
+type registeredCheck struct {
+	rule     Rule
+	newCheck func(*CheckPass) Check
+}
+
+var registry struct {
+	mu     sync.Mutex
+	checks []registeredCheck
+}
+
+// RegisterCheck adds a check reporting under rule.ID to every analysis run:
+// the analyzer, Analyze and the functions taking Options, where
+// Options.Checks selects it by ID. Rules lists rule as given; its URL
+// documents the check's diagnostics and its Severity is the one they are
+// reported with unless Options override it. newCheck is called once per
+// package. RegisterCheck is meant to be called from init functions; it
+// panics if rule.ID is empty or already registered.
+func RegisterCheck(rule Rule, newCheck func(*CheckPass) Check) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if rule.ID == "" || rule.ID == RuleMapBoolSet {
+		panic(fmt.Sprintf("boolset: RegisterCheck with reserved rule %q", rule.ID))
+	}
+	for _, c := range registry.checks {
+		if c.rule.ID == rule.ID {
+			panic(fmt.Sprintf("boolset: RegisterCheck called twice for rule %q", rule.ID))
+		}
+	}
+	registry.checks = append(registry.checks, registeredCheck{rule: rule, newCheck: newCheck})
+}
+
+// registeredChecks returns the checks s runs, created for a package.
+func (s *settings) registeredChecks(pass *CheckPass) []activeCheck {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	var checks []activeCheck
+	for _, c := range registry.checks {
+		if s.runs(c.rule.ID) {
+			checks = append(checks, activeCheck{registeredCheck: c, Check: c.newCheck(pass)})
+		}
+	}
+	return checks
+}
+
+type activeCheck struct {
+	registeredCheck
+	Check
+}
+
+// checkFindings finalises checks and returns their findings, sorted by
+// position.
+func (a *analyzer) checkFindings(s *settings) []Finding {
+	var findings []Finding
+	for _, c := range a.checks {
+		for _, diag := range c.Finalize() {
+			if diag.Rule == "" {
+				diag.Rule = c.rule.ID
+			}
+			if diag.URL == "" {
+				diag.URL = c.rule.URL
+			}
+			diag.Severity = c.rule.Severity
+			if sev, ok := s.severities[diag.Rule]; ok {
+				diag.Severity = sev
+			}
+			f := Finding{Diagnostic: diag, Fixable: diag.Edits != nil}
+			if diag.Object != nil {
+				f.Name = diag.Object.Name()
+				f.Defined = diag.Object.Pos()
+			}
+			findings = append(findings, f)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Pos < findings[j].Pos })
+	return findings
+}
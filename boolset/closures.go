@@ -0,0 +1,215 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// paramFlow is a tracked map passed to a parameter of a function literal
+// the package calls, or of a function of the package: the function's
+// writes to the parameter are writes to the map.
+type paramFlow struct {
+	arg   *mapInfo
+	param types.Object
+}
+
+// trackClosure records the assignment of rhs to lhs when lhs is a local
+// variable of function type: a variable only ever assigned one function
+// literal calls that literal.
+func (a *analyzer) trackClosure(lhs, rhs ast.Expr) {
+	id, ok := lhs.(*ast.Ident)
+	if !ok || id.Name == "_" {
+		return
+	}
+	v, ok := a.identObject(id).(*types.Var)
+	if !ok || !a.isLocalVar(v) {
+		return
+	}
+	if _, ok := v.Type().Underlying().(*types.Signature); !ok {
+		return
+	}
+	lit, _ := ast.Unparen(rhs).(*ast.FuncLit)
+	if prev, seen := a.closures[v]; seen && prev != lit {
+		lit = nil
+	}
+	a.closures[v] = lit
+}
+
+// handleFuncValueCall defers the resolution of a call to a function value,
+// whose callee is only known once every assignment to it has been seen.
+func (a *analyzer) handleFuncValueCall(call *ast.CallExpr) {
+	if _, ok := typeutil.Callee(a.info, call).(*types.Func); ok {
+		return
+	}
+	if tv, ok := a.info.Types[call.Fun]; !ok || tv.IsType() || tv.IsBuiltin() {
+		return
+	}
+	a.valueCalls = append(a.valueCalls, call)
+}
+
+// resolveValueCalls links the tracked maps passed to function values to
+// the parameters of the function literals called, and records those passed
+// to function values the analysis cannot resolve, such as parameters and
+// fields, as escaping.
+func (a *analyzer) resolveValueCalls() {
+	for _, call := range a.valueCalls {
+		lit := a.calledLiteral(call.Fun)
+		var params *types.Tuple
+		if lit != nil {
+			if sig, ok := a.info.TypeOf(lit).(*types.Signature); ok && !sig.Variadic() {
+				params = sig.Params()
+			}
+		}
+		for i, arg := range call.Args {
+			mi := a.escapingMap(arg)
+			if mi == nil {
+				continue
+			}
+			if _, isAddr := ast.Unparen(arg).(*ast.UnaryExpr); isAddr || params == nil || i >= params.Len() {
+				mi.recordEscape("passed to a function value")
+				continue
+			}
+			a.flows = append(a.flows, paramFlow{arg: mi, param: params.At(i)})
+		}
+	}
+}
+
+// handleHelperCall links the tracked maps passed to a function or method of
+// this package to its parameters. Those passed to one whose body is not
+// analysed, such as an interface method, escape.
+func (a *analyzer) handleHelperCall(call *ast.CallExpr) {
+	fn, ok := typeutil.Callee(a.info, call).(*types.Func)
+	if !ok || fn.Pkg() != a.pkg {
+		return
+	}
+	fn = fn.Origin()
+	for i, arg := range call.Args {
+		mi := a.escapingMap(arg)
+		if mi == nil {
+			continue
+		}
+		if _, isAddr := ast.Unparen(arg).(*ast.UnaryExpr); isAddr {
+			// handleAddress decides on the address.
+			continue
+		}
+		if a.decls[fn] == nil {
+			mi.recordEscape("passed to " + fn.FullName() + ", whose body is not analysed")
+			continue
+		}
+		if param := a.heldMap(a.paramAt(fn.Signature(), i, call.Ellipsis.IsValid())); param != nil {
+			a.flows = append(a.flows, paramFlow{arg: mi, param: param})
+		}
+	}
+}
+
+// paramAt returns the parameter of sig the i'th argument of a call is
+// passed to, or the object standing for the elements of a variadic one
+// unless spread is set, or nil.
+func (a *analyzer) paramAt(sig *types.Signature, i int, spread bool) types.Object {
+	params := sig.Params()
+	if sig.Variadic() && i >= params.Len()-1 {
+		last := params.At(params.Len() - 1)
+		if spread {
+			return last
+		}
+		return a.elemObject(last)
+	}
+	if i < params.Len() {
+		return params.At(i)
+	}
+	return nil
+}
+
+// heldMap returns obj if it is a bool map, or the object standing for the
+// bool maps it holds, or nil.
+func (a *analyzer) heldMap(obj types.Object) types.Object {
+	for obj != nil {
+		if m, ok := typeUnderlying(obj.Type()).(*types.Map); ok && isBool(m.Elem()) {
+			return obj
+		}
+		obj = a.elemObject(obj)
+	}
+	return nil
+}
+
+// calledLiteral returns the function literal fun is, or the one the local
+// variable fun is only ever assigned, or nil.
+func (a *analyzer) calledLiteral(fun ast.Expr) *ast.FuncLit {
+	switch fun := ast.Unparen(fun).(type) {
+	case *ast.FuncLit:
+		return fun
+	case *ast.Ident:
+		return a.closures[a.info.Uses[fun]]
+	}
+	return nil
+}
+
+// resolveFlows applies the writes functions make to their parameters to
+// the maps passed in: a parameter storing other values than
+// true, or escaping, lets the map escape, and its true writes count for
+// the map. It runs once resolveWrites has decided the parameters' writes.
+func (a *analyzer) resolveFlows() {
+	for changed := true; changed; {
+		changed = false
+		for _, f := range a.flows {
+			pm := a.results[f.param]
+			if pm == nil || pm == f.arg || f.arg.escape != "" {
+				continue
+			}
+			switch {
+			case pm.escape != "":
+				f.arg.recordEscape("passed as " + f.param.Name() + ", which escapes: " + pm.escape)
+				changed = true
+			case !pm.onlyTrue:
+				f.arg.recordEscape("passed as " + f.param.Name() + ", which stores values other than true")
+				changed = true
+			}
+		}
+	}
+	// Collect the writes of each map first, so a parameter passed on to
+	// another literal contributes that literal's writes too.
+	collected := make(map[*mapInfo][]Write)
+	var collect func(mi *mapInfo, visiting map[*mapInfo]bool) []Write
+	collect = func(mi *mapInfo, visiting map[*mapInfo]bool) []Write {
+		if ws, ok := collected[mi]; ok {
+			return ws
+		}
+		ws := mi.writes
+		if visiting[mi] {
+			return ws
+		}
+		visiting[mi] = true
+		for _, f := range a.flows {
+			if pm := a.results[f.param]; f.arg == mi && pm != nil && pm != mi {
+				ws = append(ws[:len(ws):len(ws)], collect(pm, visiting)...)
+			}
+		}
+		delete(visiting, mi)
+		collected[mi] = ws
+		return ws
+	}
+	for _, f := range a.flows {
+		collect(f.arg, make(map[*mapInfo]bool))
+		if pm := a.results[f.param]; pm != nil && pm != f.arg {
+			pm.passedIn = true
+		}
+	}
+	for mi, ws := range collected {
+		mi.trueCount += len(ws) - len(mi.writes)
+		mi.writes = ws
+	}
+}
+
+// inGoroutine reports whether pos is in a function literal a go statement
+// runs, directly or through a local variable only ever assigned it.
+func (a *analyzer) inGoroutine(pos token.Pos) bool {
+	for _, fun := range a.goCalls {
+		if lit := a.calledLiteral(fun); lit != nil && lit.Body.Pos() <= pos && pos < lit.Body.End() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// comparison is a call comparing two maps, such as maps.Equal(x, y). x and
+// y are nil for operands that are not tracked maps.
+type comparison struct {
+	fn   *types.Func
+	x, y *mapInfo
+}
+
+// isComparison reports whether fn compares its two arguments: maps.Equal
+// and reflect.DeepEqual.
+func isComparison(fn *types.Func) bool {
+	switch fn.Pkg().Path() {
+	case "maps":
+		return fn.Name() == "Equal"
+	case "reflect":
+		return fn.Name() == "DeepEqual"
+	}
+	return false
+}
+
+// handleComparison records the call to fn comparing two maps, which only
+// holds after a conversion if both are converted.
+func (a *analyzer) handleComparison(call *ast.CallExpr, fn *types.Func) {
+	if len(call.Args) != 2 {
+		return
+	}
+	c := comparison{fn: fn, x: a.escapingMap(call.Args[0]), y: a.escapingMap(call.Args[1])}
+	if c.x == nil && c.y == nil {
+		// Neither operand is a tracked map; it may be a struct holding
+		// them, which the comparison reaches as reflection does.
+		a.handleReflect(call, fn)
+		return
+	}
+	a.comparisons = append(a.comparisons, c)
+}
+
+// resolveComparisons records the compared maps whose counterpart is not
+// reported, until each comparison has both operands reported or neither.
+func (a *analyzer) resolveComparisons(s *settings) {
+	for changed := true; changed; {
+		changed = false
+		for _, c := range a.comparisons {
+			for _, pair := range [][2]*mapInfo{{c.x, c.y}, {c.y, c.x}} {
+				mi, other := pair[0], pair[1]
+				if mi == nil || mi.escape != "" || a.suppression(s, mi) != "" {
+					continue
+				}
+				if other == nil || a.suppression(s, other) != "" {
+					mi.recordEscape("compared by " + c.fn.FullName() + " with a map that is not reported")
+					changed = true
+				}
+			}
+		}
+	}
+}
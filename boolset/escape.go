@@ -0,0 +1,208 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// handleEscapes records the tracked maps call passes to a function of
+// another package, which may depend on the element type or store values
+// the analysis cannot see. maps.Copy and maps.Clone are followed instead.
+func (a *analyzer) handleEscapes(call *ast.CallExpr) {
+	fn, ok := typeutil.Callee(a.info, call).(*types.Func)
+	if !ok || fn.Pkg() == nil || fn.Pkg() == a.pkg {
+		return
+	}
+	if name := a.mapsFunc(call); name == "Copy" || name == "Clone" {
+		return
+	}
+	if isComparison(fn) {
+		a.handleComparison(call, fn)
+		return
+	}
+	if isSerializer(fn.Pkg().Path()) && !isDecoder(fn) {
+		a.handleSerialization(call, fn)
+		return
+	}
+	if isPrinter(fn) {
+		a.handlePrint(call, fn)
+		return
+	}
+	if fn.Pkg().Path() == "reflect" {
+		a.handleReflect(call, fn)
+		return
+	}
+	for _, arg := range call.Args {
+		if mi := a.escapingMap(arg); mi != nil {
+			mi.recordEscape("passed to " + fn.FullName())
+		}
+	}
+}
+
+// escapingMap returns the tracked map expr is, or whose address it takes,
+// or the one standing for the maps it holds.
+func (a *analyzer) escapingMap(expr ast.Expr) *mapInfo {
+	expr = ast.Unparen(expr)
+	if u, ok := expr.(*ast.UnaryExpr); ok && u.Op == token.AND {
+		expr = u.X
+	}
+	return a.trackedMap(a.mapObject(expr))
+}
+
+// recordEscape notes why mi is out of the analysis' sight, keeping the
+// first reason.
+func (mi *mapInfo) recordEscape(reason string) {
+	if mi.escape == "" {
+		mi.escape = reason
+	}
+}
+
+// handleConversions records the tracked maps n stores in interface values,
+// whose users may depend on the dynamic type: assignments and declarations
+// of interface-typed variables and fields, composite literal elements,
+// arguments, conversions, returned results and values sent on channels.
+func (a *analyzer) handleConversions(n ast.Node, stack []ast.Node) {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		if n.Tok == token.ASSIGN && len(n.Lhs) == len(n.Rhs) {
+			for i, lhs := range n.Lhs {
+				a.recordConversion(n.Rhs[i], a.info.TypeOf(lhs))
+			}
+		}
+	case *ast.ValueSpec:
+		if n.Type != nil {
+			for _, v := range n.Values {
+				a.recordConversion(v, a.info.TypeOf(n.Type))
+			}
+		}
+	case *ast.CompositeLit:
+		a.compositeConversions(n)
+	case *ast.CallExpr:
+		a.callConversions(n)
+	case *ast.ReturnStmt:
+		sig := a.enclosingSignature(stack)
+		if sig == nil || sig.Results().Len() != len(n.Results) {
+			return
+		}
+		for i, r := range n.Results {
+			a.recordConversion(r, sig.Results().At(i).Type())
+		}
+	case *ast.SendStmt:
+		if ch, ok := typeUnderlying(a.info.TypeOf(n.Chan)).(*types.Chan); ok {
+			a.recordConversion(n.Value, ch.Elem())
+		}
+	}
+}
+
+func (a *analyzer) compositeConversions(lit *ast.CompositeLit) {
+	switch t := typeUnderlying(a.info.TypeOf(lit)).(type) {
+	case *types.Struct:
+		for _, elt := range lit.Elts {
+			if field := a.fieldOf(lit, elt); field != nil {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					elt = kv.Value
+				}
+				a.recordConversion(elt, field.Type())
+			}
+		}
+	case *types.Slice:
+		a.elementConversions(lit, nil, t.Elem())
+	case *types.Array:
+		a.elementConversions(lit, nil, t.Elem())
+	case *types.Map:
+		a.elementConversions(lit, t.Key(), t.Elem())
+	}
+}
+
+// elementConversions records the conversions of the keys and values of a
+// slice, array or map literal to key and elem; key is nil for indices.
+func (a *analyzer) elementConversions(lit *ast.CompositeLit, key, elem types.Type) {
+	for _, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if key != nil {
+				a.recordConversion(kv.Key, key)
+			}
+			elt = kv.Value
+		}
+		a.recordConversion(elt, elem)
+	}
+}
+
+func (a *analyzer) callConversions(call *ast.CallExpr) {
+	if fn, ok := typeutil.Callee(a.info, call).(*types.Func); ok && fn.Pkg() != nil && isComparison(fn) {
+		// reflect.DeepEqual takes its operands as any; handleComparison
+		// decides on them.
+		return
+	}
+	tv, ok := a.info.Types[call.Fun]
+	if !ok {
+		return
+	}
+	if tv.IsType() {
+		if len(call.Args) == 1 {
+			a.recordConversion(call.Args[0], tv.Type)
+		}
+		return
+	}
+	sig, ok := typeUnderlying(tv.Type).(*types.Signature)
+	if !ok {
+		return
+	}
+	params := sig.Params()
+	for i, arg := range call.Args {
+		switch {
+		case sig.Variadic() && i >= params.Len()-1:
+			last := params.At(params.Len() - 1).Type()
+			if call.Ellipsis.IsValid() {
+				a.recordConversion(arg, last)
+			} else if s, ok := last.Underlying().(*types.Slice); ok {
+				a.recordConversion(arg, s.Elem())
+			}
+		case i < params.Len():
+			a.recordConversion(arg, params.At(i).Type())
+		}
+	}
+}
+
+// enclosingSignature returns the signature of the innermost function in
+// stack, or nil.
+func (a *analyzer) enclosingSignature(stack []ast.Node) *types.Signature {
+	for i := len(stack) - 1; i >= 0; i-- {
+		switch fn := stack[i].(type) {
+		case *ast.FuncLit:
+			sig, _ := a.info.TypeOf(fn).(*types.Signature)
+			return sig
+		case *ast.FuncDecl:
+			if obj := a.info.Defs[fn.Name]; obj != nil {
+				sig, _ := obj.Type().(*types.Signature)
+				return sig
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// recordConversion records expr escaping when it is a tracked map stored
+// as the interface type t.
+func (a *analyzer) recordConversion(expr ast.Expr, t types.Type) {
+	if t == nil || !types.IsInterface(t) {
+		return
+	}
+	if _, ok := t.(*types.TypeParam); ok {
+		return
+	}
+	if mi := a.escapingMap(expr); mi != nil {
+		mi.recordEscape("stored as " + types.TypeString(t, a.qualifier))
+	}
+}
+
+func typeUnderlying(t types.Type) types.Type {
+	if t == nil {
+		return nil
+	}
+	return t.Underlying()
+}
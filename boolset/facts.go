@@ -0,0 +1,96 @@
+package boolset
+
+import (
+	"fmt"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// setFact is exported for package-level map[T]bool variables visible to other
+// packages whose defining package only ever stores true in them. Writes counts
+// those stores; zero means the map is populated, if at all, elsewhere.
+type setFact struct {
+	Writes int
+}
+
+func (*setFact) AFact() {}
+
+func (f *setFact) String() string {
+	return fmt.Sprintf("set(%d writes)", f.Writes)
+}
+
+// factStore connects the analysis to the go/analysis fact mechanism. It is nil
+// when a package is analysed in isolation, in which case maps declared in
+// other packages are ignored.
+type factStore interface {
+	importSet(obj types.Object) (writes int, ok bool)
+	exportSet(obj types.Object, writes int)
+}
+
+type passFacts struct {
+	pass *analysis.Pass
+}
+
+func (f passFacts) importSet(obj types.Object) (int, bool) {
+	var fact setFact
+	if !f.pass.ImportObjectFact(obj, &fact) {
+		return 0, false
+	}
+	return fact.Writes, true
+}
+
+func (f passFacts) exportSet(obj types.Object, writes int) {
+	f.pass.ExportObjectFact(obj, &setFact{Writes: writes})
+}
+
+// exportFacts records a setFact for every exported package-level bool map
+// that this package only writes true to.
+func (a *analyzer) exportFacts() {
+	if a.facts == nil {
+		return
+	}
+	scope := a.pkg.Scope()
+	for _, name := range scope.Names() {
+		v, ok := scope.Lookup(name).(*types.Var)
+		if !ok || !v.Exported() {
+			continue
+		}
+		m, ok := v.Type().Underlying().(*types.Map)
+		if !ok || !isBool(m.Elem()) {
+			continue
+		}
+		writes := 0
+		if mi, ok := a.results[v]; ok {
+			if !mi.onlyTrue {
+				continue
+			}
+			writes = mi.trueCount
+		}
+		a.facts.exportSet(v, writes)
+	}
+}
+
+// importedInfo tracks writes to a map declared in another package. Only maps
+// whose defining package never stores anything but true are followed.
+func (a *analyzer) importedInfo(obj types.Object, m *types.Map) *mapInfo {
+	if a.facts == nil {
+		return nil
+	}
+	writes, ok := a.facts.importSet(obj)
+	if !ok {
+		return nil
+	}
+	mi := &mapInfo{
+		obj:      obj,
+		onlyTrue: true,
+		keyType:  types.TypeString(m.Key(), a.qualifier),
+		// The declaration lives in another package; report at the first
+		// write seen here.
+		unfixable:      true,
+		upstreamWrites: writes,
+		imported:       true,
+	}
+	a.results[obj] = mi
+	return mi
+}
@@ -0,0 +1,60 @@
+package boolset
+
+import (
+	"go/token"
+	"go/types"
+)
+
+// Finding describes a reported map in more detail than its diagnostic
+// message, for tools that build on the analysis.
+type Finding struct {
+	Diagnostic
+	// Name is the name of the map variable or field. The maps held by a
+	// map, slice or array, such as the inner maps of
+	// index map[A]map[B]bool, are reported as one, under the name of the
+	// variable or field holding them.
+	Name string
+	// KeyType and ElemType are the map's key and element types, qualified
+	// relative to the analysed package.
+	KeyType  string
+	ElemType string
+	// Defined is where the map is declared; it may lie in another package.
+	Defined token.Pos
+	// Writes counts the stores into the map, Reads its membership reads.
+	Writes int
+	Reads  int
+	// Fixable reports whether Edits safely convert the map to
+	// map[T]struct{}.
+	Fixable bool
+}
+
+func (a *analyzer) finding(mi *mapInfo, diag Diagnostic) Finding {
+	f := Finding{
+		Diagnostic: diag,
+		KeyType:    mi.keyType,
+		ElemType:   "bool",
+		Writes:     len(mi.writes),
+		Reads:      len(mi.reads),
+		Fixable:    diag.Edits != nil,
+	}
+	if mi.obj != nil {
+		f.Name = mi.declared().Name()
+		f.Defined = mi.declared().Pos()
+		if m, ok := mi.obj.Type().Underlying().(*types.Map); ok {
+			f.ElemType = types.TypeString(m.Elem(), a.qualifier)
+		}
+	}
+	return f
+}
+
+// diagnostics returns the diagnostics of findings.
+func diagnostics(findings []Finding) []Diagnostic {
+	if findings == nil {
+		return nil
+	}
+	diags := make([]Diagnostic, len(findings))
+	for i, f := range findings {
+		diags[i] = f.Diagnostic
+	}
+	return diags
+}
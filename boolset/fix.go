@@ -0,0 +1,171 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// TextEdit replaces the source between Pos and End with NewText.
+type TextEdit struct {
+	Pos     token.Pos
+	End     token.Pos
+	NewText string
+}
+
+// handleField records the declared type of struct fields and marks
+// parameters and results as unfixable: their maps come from callers.
+func (a *analyzer) handleField(field *ast.Field, stack []ast.Node) {
+	if len(stack) < 3 {
+		return
+	}
+	_, isStruct := stack[len(stack)-3].(*ast.StructType)
+	for _, name := range field.Names {
+		obj := a.info.Defs[name]
+		if !isStruct {
+			if mi := a.infoFor(obj); mi != nil {
+				mi.unfixable = true
+			}
+			continue
+		}
+		a.recordTypeExprs(obj, field.Type)
+	}
+	if isStruct {
+		a.handleTags(field)
+	}
+}
+
+// trackMapInit records an assignment of a whole map value to a tracked map,
+// such as s.set = make(map[string]bool).
+func (a *analyzer) trackMapInit(lhs, rhs ast.Expr) {
+	obj := a.objectOfAssignable(lhs)
+	mi := a.trackedMap(obj)
+	if mi == nil {
+		return
+	}
+	if mi.obj == obj && a.usesObject(lhs, obj) {
+		mi.knownUses++
+	}
+	mi.recordInit(a, rhs)
+}
+
+// recordInit notes the map type expressions an initial value is built from;
+// values without one (calls, untracked maps) cannot be rewritten in place. A
+// map reset to nil or to a new, empty map stays the same set: its earlier
+// writes still count and the reset compiles for either element type. A
+// tracked map assigned to mi becomes its alias, which is rewritten with it
+// when both have the same type.
+func (mi *mapInfo) recordInit(a *analyzer, rhs ast.Expr) {
+	if tv, ok := a.info.Types[rhs]; ok && tv.IsNil() {
+		return
+	}
+	if src := a.aliasedMap(rhs); src != nil && !mi.imported {
+		a.mapAliases = append(a.mapAliases, [2]*mapInfo{mi, src})
+		if a.usesObject(ast.Unparen(rhs), src.obj) {
+			src.knownUses++
+		}
+		if types.Identical(mi.obj.Type(), src.obj.Type()) {
+			return
+		}
+	}
+	if src := a.clonedMap(rhs); src != nil {
+		// A clone holds the values of the map it copies.
+		mi.recordAssignment(a, src, rhs, ast.Unparen(rhs).(*ast.CallExpr).Lparen)
+	}
+	if !a.recordTypeExprs(mi.obj, rhs) {
+		mi.untypedInit = true
+	}
+}
+
+// recordTypeExprs attributes the map type expressions in expr that spell
+// obj's type to obj, reporting whether any were found.
+func (a *analyzer) recordTypeExprs(obj types.Object, expr ast.Expr) bool {
+	mi := a.infoFor(obj)
+	if mi == nil {
+		return false
+	}
+	found := false
+	ast.Inspect(expr, func(n ast.Node) bool {
+		mt, ok := n.(*ast.MapType)
+		if !ok {
+			return true
+		}
+		if t := a.info.TypeOf(mt); t != nil && types.Identical(t, obj.Type()) {
+			mi.typeExprs = append(mi.typeExprs, mt)
+			a.typeOwners[mt]++
+			found = true
+		}
+		return false
+	})
+	return found
+}
+
+func (a *analyzer) usesObject(expr ast.Expr, obj types.Object) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return a.info.Uses[e] == obj
+	case *ast.SelectorExpr:
+		return a.info.Uses[e.Sel] == obj
+	case *ast.ParenExpr:
+		return a.usesObject(e.X, obj)
+	}
+	return false
+}
+
+// countUses tallies every reference to a tracked map so that suggestedEdits
+// can tell whether reads or escapes exist beyond the recorded writes.
+func (a *analyzer) countUses() {
+	for _, obj := range a.info.Uses {
+		if mi, ok := a.results[obj]; ok {
+			mi.uses++
+		}
+		if pa, ok := a.pointers[obj]; ok {
+			pa.uses++
+		}
+	}
+}
+
+// suggestedEdits returns the edits converting mi to map[T]struct{}, or nil
+// and the reason if the map is used in ways the rewrite would break.
+func (a *analyzer) suggestedEdits(mi *mapInfo) ([]TextEdit, string) {
+	switch {
+	case mi.unfixable:
+		return nil, "declared where it cannot be rewritten"
+	case mi.untypedInit || len(mi.typeExprs) == 0:
+		return nil, "no map type expression to rewrite"
+	case mi.uses != mi.knownUses:
+		return nil, "escapes: used other than by its writes and initialisation"
+	}
+	obj := mi.obj
+	// Named and aliased types may be shared with maps we did not prove.
+	if _, ok := obj.Type().(*types.Map); !ok {
+		return nil, "declared with a named or aliased type"
+	}
+	if v, ok := obj.(*types.Var); ok && obj.Exported() && (v.IsField() || obj.Parent() == a.pkg.Scope()) {
+		return nil, "exported"
+	}
+
+	var edits []TextEdit
+	seen := make(map[*ast.MapType]struct{})
+	for _, mt := range mi.typeExprs {
+		if a.typeOwners[mt] > 1 {
+			return nil, "its type expression declares other maps"
+		}
+		if _, ok := seen[mt]; ok {
+			continue
+		}
+		seen[mt] = struct{}{}
+		edits = append(edits, TextEdit{Pos: mt.Value.Pos(), End: mt.Value.End(), NewText: "struct{}"})
+	}
+	for _, v := range mi.values {
+		// Replacing a variable would leave it unused; only constants are safe.
+		if tv, ok := a.info.Types[v]; !ok || tv.Value == nil {
+			return nil, "stores a true value that is not a constant"
+		}
+		edits = append(edits, TextEdit{Pos: v.Pos(), End: v.End(), NewText: "struct{}{}"})
+	}
+	// Clients such as gopls expect a fix's edits in file order.
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Pos < edits[j].Pos })
+	return edits, ""
+}
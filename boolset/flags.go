@@ -0,0 +1,161 @@
+package boolset
+
+import (
+	"flag"
+	"go/types"
+	"log/slog"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// settings tune which maps are reported. Drivers set them through the
+// analyzer's flags, AnalyzeWithOptions through Options; Analyze uses
+// defaultSettings.
+type settings struct {
+	// minWrites is the number of true writes a map needs before it is
+	// reported.
+	minWrites int
+	// includeFields and includeGlobals report struct fields and
+	// package-level variables respectively.
+	includeFields  bool
+	includeGlobals bool
+	// excludeKeyTypes skips maps whose key type string matches.
+	excludeKeyTypes regexpFlag
+	// keyFilter, if set, reports whether maps with a key type are reported.
+	keyFilter func(types.Type) bool
+	// checks holds the IDs of the rules to run; nil runs all.
+	checks map[string]struct{}
+	// severities overrides the severity of the rules it lists.
+	severities map[string]Severity
+	// messages holds templates replacing the message of the rules they
+	// are keyed by.
+	messages map[string]*template.Template
+	// catalog translates messages into the selected locale; nil keeps
+	// them in English.
+	catalog *template.Template
+	// filter, if set, reports whether a finding is emitted.
+	filter func(Finding) bool
+	// logger, if set, receives debug logs explaining what is not reported.
+	logger *slog.Logger
+	// metrics, if set, accumulates the counts of each run.
+	metrics *Metrics
+	// ssa decides whether stored values are true on the package's SSA
+	// form.
+	ssa bool
+	// includeSerialized reports maps encoded by encoding packages, whose
+	// encoded form the conversion changes.
+	includeSerialized bool
+}
+
+func defaultSettings() settings {
+	return settings{minWrites: 1, includeFields: true, includeGlobals: true}
+}
+
+func (s *settings) register(fs *flag.FlagSet) {
+	fs.IntVar(&s.minWrites, "min-writes", s.minWrites, "report maps only after this many `true` writes")
+	fs.BoolVar(&s.includeFields, "include-fields", s.includeFields, "report maps stored in struct fields")
+	fs.BoolVar(&s.includeGlobals, "include-globals", s.includeGlobals, "report package-level maps")
+	fs.Var(&s.excludeKeyTypes, "exclude-key-types", "skip maps whose key type matches this `regexp` (e.g. ^int$)")
+	fs.BoolVar(&s.ssa, "ssa", s.ssa, "decide which stored values are true on the SSA form: more precise, but slower")
+	fs.BoolVar(&s.includeSerialized, "include-serialized", s.includeSerialized, "report maps encoded with encoding/json, yaml, xml, gob or toml, whose encoded form the fix changes")
+}
+
+// suppression tells why the settings exclude a map selected by the
+// analysis, or returns "" if they do not.
+func (s *settings) suppression(pkg *types.Package, mi *mapInfo) string {
+	if mi.trueCount < s.minWrites {
+		return "fewer true writes than the minimum"
+	}
+	if v, ok := mi.obj.(*types.Var); ok {
+		if v.IsField() && !s.includeFields {
+			return "struct fields are excluded"
+		}
+		if pkg != nil && v.Parent() == pkg.Scope() && !s.includeGlobals {
+			return "package-level maps are excluded"
+		}
+	}
+	if mi.serialized != "" && !s.includeSerialized {
+		return "serialized: " + mi.serialized
+	}
+	if s.excludeKeyTypes.re != nil && s.excludeKeyTypes.re.MatchString(mi.keyType) {
+		return "key type matches -exclude-key-types"
+	}
+	if s.keyFilter != nil && mi.obj != nil {
+		if m, ok := mi.obj.Type().Underlying().(*types.Map); ok && !s.keyFilter(m.Key()) {
+			return "key type rejected by KeyTypeFilter"
+		}
+	}
+	return ""
+}
+
+// rewriteMessage replaces f's message using the template for its rule, if
+// any, or else translates its message and fix title to the locale. The
+// default text is kept when a template fails.
+func (s *settings) rewriteMessage(f *Finding) {
+	if tmpl := s.messages[f.Rule]; tmpl != nil {
+		s.execute(tmpl, f, &f.Message)
+		return
+	}
+	if s.catalog == nil {
+		return
+	}
+	if tmpl := s.catalog.Lookup(f.Rule); tmpl != nil {
+		s.execute(tmpl, f, &f.Message)
+	}
+	if tmpl := s.catalog.Lookup(f.Rule + ".fix"); tmpl != nil && f.FixMessage != "" {
+		s.execute(tmpl, f, &f.FixMessage)
+	}
+}
+
+// execute stores the output of tmpl for f in text, leaving text alone when
+// execution fails.
+func (s *settings) execute(tmpl *template.Template, f *Finding, text *string) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, f); err != nil {
+		s.debug("message template failed", "rule", f.Rule, "template", tmpl.Name(), "err", err)
+		return
+	}
+	*text = b.String()
+}
+
+// debug logs msg at debug level when a logger is set.
+func (s *settings) debug(msg string, args ...any) {
+	if s.logger != nil {
+		s.logger.Debug(msg, args...)
+	}
+}
+
+// runs tells whether the rule with the given ID is enabled.
+func (s *settings) runs(rule string) bool {
+	if s.checks == nil {
+		return true
+	}
+	_, ok := s.checks[rule]
+	return ok
+}
+
+// regexpFlag is a flag.Value holding an optional regular expression.
+type regexpFlag struct {
+	re *regexp.Regexp
+}
+
+func (f *regexpFlag) String() string {
+	if f == nil || f.re == nil {
+		return ""
+	}
+	return f.re.String()
+}
+
+func (f *regexpFlag) Set(value string) error {
+	if value == "" {
+		f.re = nil
+		return nil
+	}
+	re, err := regexp.Compile(value)
+	if err != nil {
+		return err
+	}
+	f.re = re
+	return nil
+}
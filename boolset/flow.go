@@ -0,0 +1,213 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/cfg"
+)
+
+// boolDef is an assignment to a local bool variable reaching a read: the
+// assigned value, nil when it is unknown or the zero value, or, for entry,
+// the value the variable held when the function body started. A range loop
+// over a map assigns its values; over is then the map.
+type boolDef struct {
+	value ast.Expr
+	entry bool
+	over  ast.Expr
+}
+
+// cfgFor returns the control flow graph of the function body fn, built on
+// first use.
+func (a *analyzer) cfgFor(fn *ast.BlockStmt) *cfg.CFG {
+	if g, ok := a.cfgs[fn]; ok {
+		return g
+	}
+	g := cfg.New(fn, a.mayReturn)
+	a.cfgs[fn] = g
+	return g
+}
+
+// mayReturn reports whether call may return, for the control flow graph:
+// calls to the panic builtin do not.
+func (a *analyzer) mayReturn(call *ast.CallExpr) bool {
+	if id, ok := ast.Unparen(call.Fun).(*ast.Ident); ok {
+		if b, ok := a.info.Uses[id].(*types.Builtin); ok && b.Name() == "panic" {
+			return false
+		}
+	}
+	return true
+}
+
+// reachingDefs returns the assignments to obj that reach its read at pos in
+// the function body fn. It returns false if pos is not in fn's graph.
+func (a *analyzer) reachingDefs(fn *ast.BlockStmt, obj types.Object, pos token.Pos) ([]boolDef, bool) {
+	g := a.cfgFor(fn)
+	var (
+		at  *cfg.Block
+		idx int
+	)
+	for _, b := range g.Blocks {
+		for i, n := range b.Nodes {
+			if n.Pos() <= pos && pos < n.End() {
+				at, idx = b, i
+			}
+		}
+	}
+	if at == nil {
+		return nil, false
+	}
+	// An assignment earlier in the same block is the only one reaching pos.
+	for i := idx - 1; i >= 0; i-- {
+		if value, ok := a.nodeDef(at.Nodes[i], obj); ok {
+			return []boolDef{{value: value}}, true
+		}
+	}
+	if d, ok := a.rangeDef(at, obj); ok {
+		return []boolDef{d}, true
+	}
+	defs, _, in := a.solveDefs(g, obj)
+	var reaching []boolDef
+	for d := range in[at.Index] {
+		reaching = append(reaching, defs[d])
+	}
+	return reaching, true
+}
+
+// exitDefs returns the assignments to obj that reach the exits of the
+// function body fn, where its deferred calls run: its returns, the end of
+// the body and calls that panic.
+func (a *analyzer) exitDefs(fn *ast.BlockStmt, obj types.Object) []boolDef {
+	g := a.cfgFor(fn)
+	defs, gen, in := a.solveDefs(g, obj)
+	reaching := make(map[int]bool)
+	for _, b := range g.Blocks {
+		if !b.Live || len(b.Succs) > 0 {
+			continue
+		}
+		if gen[b.Index] >= 0 {
+			reaching[gen[b.Index]] = true
+			continue
+		}
+		for d := range in[b.Index] {
+			reaching[d] = true
+		}
+	}
+	var exits []boolDef
+	for d := range reaching {
+		exits = append(exits, defs[d])
+	}
+	return exits
+}
+
+// solveDefs solves reaching definitions for obj over g. defs[0] is the
+// value at entry; gen holds the index in defs of the assignment that leaves
+// each block, or -1 if the block assigns nothing, and in the indices of
+// those reaching the start of each block.
+func (a *analyzer) solveDefs(g *cfg.CFG, obj types.Object) (defs []boolDef, gen []int, in []map[int]bool) {
+	defs = []boolDef{{entry: true}}
+	gen = make([]int, len(g.Blocks))
+	preds := make([][]*cfg.Block, len(g.Blocks))
+	for _, b := range g.Blocks {
+		gen[b.Index] = -1
+		for i := len(b.Nodes) - 1; i >= 0 && gen[b.Index] < 0; i-- {
+			if value, ok := a.nodeDef(b.Nodes[i], obj); ok {
+				gen[b.Index] = len(defs)
+				defs = append(defs, boolDef{value: value})
+			}
+		}
+		if gen[b.Index] < 0 {
+			if d, ok := a.rangeDef(b, obj); ok {
+				gen[b.Index] = len(defs)
+				defs = append(defs, d)
+			}
+		}
+		for _, succ := range b.Succs {
+			preds[succ.Index] = append(preds[succ.Index], b)
+		}
+	}
+	in = make([]map[int]bool, len(g.Blocks))
+	for i := range in {
+		in[i] = make(map[int]bool)
+	}
+	in[0][0] = true
+	for changed := true; changed; {
+		changed = false
+		for _, b := range g.Blocks {
+			for _, p := range preds[b.Index] {
+				if gen[p.Index] >= 0 {
+					if !in[b.Index][gen[p.Index]] {
+						in[b.Index][gen[p.Index]] = true
+						changed = true
+					}
+					continue
+				}
+				for d := range in[p.Index] {
+					if !in[b.Index][d] {
+						in[b.Index][d] = true
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	return defs, gen, in
+}
+
+// nodeDef returns the value n assigns to obj, if it assigns obj.
+func (a *analyzer) nodeDef(n ast.Node, obj types.Object) (ast.Expr, bool) {
+	var (
+		value   ast.Expr
+		defines bool
+	)
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		for i, lhs := range n.Lhs {
+			if id, ok := lhs.(*ast.Ident); ok && a.identObject(id) == obj {
+				defines, value = true, nil
+				if (n.Tok == token.ASSIGN || n.Tok == token.DEFINE) && len(n.Rhs) == len(n.Lhs) {
+					value = n.Rhs[i]
+				}
+			}
+		}
+	case *ast.ValueSpec:
+		for i, name := range n.Names {
+			if a.identObject(name) == obj {
+				defines, value = true, nil
+				if len(n.Values) == len(n.Names) {
+					value = n.Values[i]
+				}
+			}
+		}
+	}
+	return value, defines
+}
+
+// rangeDef returns the assignment to obj on each iteration of the range
+// loop b is the body of, if the loop assigns obj.
+func (a *analyzer) rangeDef(b *cfg.Block, obj types.Object) (boolDef, bool) {
+	rng, ok := b.Stmt.(*ast.RangeStmt)
+	if !ok || b.Kind != cfg.KindRangeBody {
+		return boolDef{}, false
+	}
+	if id, ok := rng.Key.(*ast.Ident); ok && a.identObject(id) == obj {
+		return boolDef{}, true
+	}
+	if id, ok := rng.Value.(*ast.Ident); ok && a.identObject(id) == obj {
+		if t := a.info.TypeOf(rng.X); t != nil {
+			if _, ok := t.Underlying().(*types.Map); ok {
+				return boolDef{over: rng.X}, true
+			}
+		}
+		return boolDef{}, true
+	}
+	return boolDef{}, false
+}
+
+func (a *analyzer) identObject(id *ast.Ident) types.Object {
+	if obj := a.info.Defs[id]; obj != nil {
+		return obj
+	}
+	return a.info.Uses[id]
+}
@@ -0,0 +1,50 @@
+package boolset
+
+import "go/types"
+
+// setType returns the type name of t when t is a bool map type defined in
+// this package with methods, such as type Set[T comparable] map[T]bool,
+// or nil. All the values of such a type are tracked as one map, reported
+// at the type: its methods write to whichever value they are called on.
+func (a *analyzer) setType(t types.Type) *types.TypeName {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return nil
+	}
+	named = named.Origin()
+	tn := named.Obj()
+	if tn.Pkg() != a.pkg || named.NumMethods() == 0 {
+		return nil
+	}
+	return tn
+}
+
+// resolveInstances shares the conclusions reached on the instantiations of
+// a field of a generic type with the field and its other instantiations:
+// a method of S[T] writing s.m writes the m of every S[string] too, so
+// when any of them stores other values than true or escapes, all do.
+func (a *analyzer) resolveInstances() {
+	instances := make(map[types.Object][]*mapInfo)
+	for obj, mi := range a.results {
+		if v, ok := obj.(*types.Var); ok && v.IsField() {
+			instances[v.Origin()] = append(instances[v.Origin()], mi)
+		}
+	}
+	for _, group := range instances {
+		onlyTrue, escape, serialized := true, "", ""
+		for _, mi := range group {
+			onlyTrue = onlyTrue && mi.onlyTrue
+			if escape == "" {
+				escape = mi.escape
+			}
+			if serialized == "" {
+				serialized = mi.serialized
+			}
+		}
+		for _, mi := range group {
+			mi.onlyTrue = mi.onlyTrue && onlyTrue
+			mi.recordEscape(escape)
+			mi.recordSerialized(serialized)
+		}
+	}
+}
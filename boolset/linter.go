@@ -0,0 +1,130 @@
+package boolset
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"log/slog"
+	"text/template"
+)
+
+// Linter runs the analysis with a fixed configuration. Create one with New;
+// it is safe for concurrent use.
+type Linter struct {
+	opts Options
+}
+
+// Option configures a Linter.
+type Option func(*Options)
+
+// New returns a Linter configured by opts, applied in order on top of
+// DefaultOptions. New options can be added without changing the signature
+// of New or of the Linter's methods.
+func New(opts ...Option) *Linter {
+	o := DefaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return &Linter{opts: o}
+}
+
+// WithMinWrites reports maps only after n true writes.
+func WithMinWrites(n int) Option {
+	return func(o *Options) { o.MinWrites = n }
+}
+
+// WithFields sets whether maps stored in struct fields are reported.
+func WithFields(include bool) Option {
+	return func(o *Options) { o.ExcludeFields = !include }
+}
+
+// WithGlobals sets whether package-level maps are reported.
+func WithGlobals(include bool) Option {
+	return func(o *Options) { o.ExcludeGlobals = !include }
+}
+
+// WithSerialized sets whether maps encoded by encoding packages are
+// reported, as Options.IncludeSerialized describes.
+func WithSerialized(include bool) Option {
+	return func(o *Options) { o.IncludeSerialized = include }
+}
+
+// WithKeyTypeFilter reports only maps whose key type filter accepts.
+func WithKeyTypeFilter(filter func(key types.Type) bool) Option {
+	return func(o *Options) { o.KeyTypeFilter = filter }
+}
+
+// WithSSA sets whether stored values are decided on the SSA form, as
+// Options.SSA describes.
+func WithSSA(on bool) Option {
+	return func(o *Options) { o.SSA = on }
+}
+
+// WithChecks runs only the rules with the given IDs.
+func WithChecks(rules ...string) Option {
+	return func(o *Options) { o.Checks = append([]string{}, rules...) }
+}
+
+// WithSeverity reports the diagnostics of rule with severity.
+func WithSeverity(rule string, severity Severity) Option {
+	return func(o *Options) {
+		severities := make(map[string]Severity, len(o.Severities)+1)
+		for r, s := range o.Severities {
+			severities[r] = s
+		}
+		severities[rule] = severity
+		o.Severities = severities
+	}
+}
+
+// WithMessage replaces the message of rule's diagnostics with tmpl, as
+// Options.Messages describes.
+func WithMessage(rule string, tmpl *template.Template) Option {
+	return func(o *Options) {
+		messages := make(map[string]*template.Template, len(o.Messages)+1)
+		for r, t := range o.Messages {
+			messages[r] = t
+		}
+		messages[rule] = tmpl
+		o.Messages = messages
+	}
+}
+
+// WithLocale translates messages to locale, as Options.Locale describes.
+func WithLocale(locale string) Option {
+	return func(o *Options) { o.Locale = locale }
+}
+
+// WithFilter drops the findings filter returns false for.
+func WithFilter(filter func(Finding) bool) Option {
+	return func(o *Options) { o.Filter = filter }
+}
+
+// WithLogger logs what is not reported, and why, to logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *Options) { o.Logger = logger }
+}
+
+// WithMetrics adds the counts of every run to m.
+func WithMetrics(m *Metrics) Option {
+	return func(o *Options) { o.Metrics = m }
+}
+
+// Options returns the Linter's configuration.
+func (l *Linter) Options() Options {
+	return l.opts
+}
+
+// Analyze analyses a type-checked package, as AnalyzeContext does with the
+// Linter's options.
+func (l *Linter) Analyze(ctx context.Context, pkg *types.Package, files []*ast.File, info *types.Info) ([]Finding, error) {
+	s := l.opts.settings()
+	findings, _, err := analyze(ctx, pkg, files, info, nil, &s, nil)
+	return findings, err
+}
+
+// Run loads and analyses the packages matching patterns, as RunBatch does
+// with the Linter's options.
+func (l *Linter) Run(ctx context.Context, patterns ...string) ([]PackageResult, error) {
+	return RunBatch(ctx, patterns, l.opts)
+}
@@ -0,0 +1,272 @@
+package boolset
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"runtime"
+	"sort"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// PositionedFinding is a Finding with its positions resolved, so it can be
+// used once the FileSet it was reported against is gone.
+type PositionedFinding struct {
+	Finding
+	// Package is the import path of the package the map was reported in.
+	Package string
+	// Position and End delimit the reported range.
+	Position token.Position
+	End      token.Position
+	// Edits are the finding's Edits with their positions resolved, for
+	// ApplyEdits.
+	Edits []PositionedEdit
+}
+
+// PositionedEdit is a TextEdit with its positions resolved.
+type PositionedEdit struct {
+	Position token.Position
+	End      token.Position
+	NewText  string
+}
+
+// ApplyEdits returns src with edits applied. The edits must be for the file
+// src was read from and must not overlap; as Edits are computed from the
+// source analysed, an edit out of range means the file has changed since.
+func ApplyEdits(src []byte, edits []PositionedEdit) ([]byte, error) {
+	edits = append([]PositionedEdit(nil), edits...)
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Position.Offset > edits[j].Position.Offset })
+	out := append([]byte(nil), src...)
+	limit := len(src)
+	for _, e := range edits {
+		if e.Position.Offset < 0 || e.Position.Offset > e.End.Offset || e.End.Offset > len(src) {
+			return nil, fmt.Errorf("boolset: edit at %s out of range", e.Position)
+		}
+		if e.End.Offset > limit {
+			return nil, fmt.Errorf("boolset: overlapping edits at %s", e.Position)
+		}
+		out = append(out[:e.Position.Offset], append([]byte(e.NewText), out[e.End.Offset:]...)...)
+		limit = e.Position.Offset
+	}
+	return out, nil
+}
+
+// MarshalJSON encodes f with the keys boolsetlint uses for findings (file,
+// line, column, rule and message), followed by the finding's details.
+func (f PositionedFinding) MarshalJSON() ([]byte, error) {
+	type jsonFinding struct {
+		File      string     `json:"file"`
+		Line      int        `json:"line"`
+		Column    int        `json:"column"`
+		EndLine   int        `json:"endLine,omitempty"`
+		EndColumn int        `json:"endColumn,omitempty"`
+		Rule      string     `json:"rule"`
+		Severity  Severity   `json:"severity"`
+		Message   string     `json:"message"`
+		URL       string     `json:"url,omitempty"`
+		Package   string     `json:"package"`
+		Name      string     `json:"name"`
+		KeyType   string     `json:"keyType"`
+		ElemType  string     `json:"elemType"`
+		Writes    int        `json:"writes"`
+		Reads     int        `json:"reads"`
+		Fixable   bool       `json:"fixable"`
+		Edits     []jsonEdit `json:"edits,omitempty"`
+	}
+	var edits []jsonEdit
+	for _, e := range f.Edits {
+		edits = append(edits, jsonEdit{Offset: e.Position.Offset, End: e.End.Offset, NewText: e.NewText})
+	}
+	return json.Marshal(jsonFinding{
+		File:      f.Position.Filename,
+		Line:      f.Position.Line,
+		Column:    f.Position.Column,
+		EndLine:   f.End.Line,
+		EndColumn: f.End.Column,
+		Rule:      f.Rule,
+		Severity:  f.Severity,
+		Message:   f.Message,
+		URL:       f.URL,
+		Package:   f.Package,
+		Name:      f.Name,
+		KeyType:   f.KeyType,
+		ElemType:  f.ElemType,
+		Writes:    f.Writes,
+		Reads:     f.Reads,
+		Fixable:   f.Fixable,
+		Edits:     edits,
+	})
+}
+
+// jsonEdit encodes a PositionedEdit by byte offsets into its file.
+type jsonEdit struct {
+	Offset  int    `json:"offset"`
+	End     int    `json:"end"`
+	NewText string `json:"newText"`
+}
+
+// PackageResult is the outcome of analysing one package in RunBatch.
+type PackageResult struct {
+	// Package is the package's import path.
+	Package string
+	// Findings are sorted by position.
+	Findings []PositionedFinding
+	// Err joins the errors that kept the package from being analysed, such
+	// as parse and type errors. Findings is empty when it is set.
+	Err error
+}
+
+// loadMode is what analysis needs from go/packages.
+const loadMode = packages.NeedName | packages.NeedFiles | packages.NeedSyntax |
+	packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports
+
+// Run loads the packages matching patterns, as the go command would from
+// the current directory, and analyses them with the default options.
+// Findings are sorted by position. Packages that fail to load or type-check
+// are skipped; their errors are returned, joined, with the findings of the
+// others.
+func Run(patterns ...string) ([]PositionedFinding, error) {
+	results, err := RunBatch(context.Background(), patterns, DefaultOptions())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		findings []PositionedFinding
+		errs     []error
+	)
+	for _, r := range results {
+		findings = append(findings, r.Findings...)
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	sortPositioned(findings)
+	return findings, errors.Join(errs...)
+}
+
+// RunBatch loads the packages matching patterns in one go/packages call, so
+// their dependencies are loaded and type-checked once, and analyses them
+// concurrently. A package is analysed after the packages it imports from the
+// batch, so maps exported by one are followed into the others, as the
+// analyzer does with facts. Results are sorted by package path.
+//
+// The returned error is set when the patterns cannot be loaded at all or ctx
+// is done; errors confined to a package are reported in its result.
+// opts.KeyTypeFilter may be called from several goroutines at once.
+func RunBatch(ctx context.Context, patterns []string, opts Options) ([]PackageResult, error) {
+	cfg := &packages.Config{Context: ctx, Mode: loadMode}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	s := opts.settings()
+	facts := &batchFacts{sets: make(map[string]int)}
+	done := make(map[*packages.Package]chan struct{}, len(pkgs))
+	for _, pkg := range pkgs {
+		done[pkg] = make(chan struct{})
+	}
+
+	results := make([]PackageResult, len(pkgs))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, pkg := range pkgs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[pkg])
+			for _, dep := range pkg.Imports {
+				if ch, ok := done[dep]; ok {
+					<-ch
+				}
+			}
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = analyzePackage(ctx, pkg, &s, facts)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Package < results[j].Package })
+	return results, nil
+}
+
+func analyzePackage(ctx context.Context, pkg *packages.Package, s *settings, facts factStore) PackageResult {
+	r := PackageResult{Package: pkg.PkgPath}
+	if len(pkg.Errors) > 0 {
+		errs := make([]error, len(pkg.Errors))
+		for i, e := range pkg.Errors {
+			errs[i] = fmt.Errorf("%s: %w", pkg.PkgPath, e)
+		}
+		r.Err = errors.Join(errs...)
+		s.debug("package skipped", "package", pkg.PkgPath, "err", r.Err)
+		return r
+	}
+	_, err := analyzeFunc(ctx, pkg.Types, pkg.Syntax, pkg.TypesInfo, nil, s, facts, func(f Finding) {
+		r.Findings = append(r.Findings, positioned(pkg.Fset, pkg.PkgPath, f))
+	})
+	if err != nil {
+		r.Err = err
+		r.Findings = nil
+	}
+	sortPositioned(r.Findings)
+	return r
+}
+
+// positioned resolves the positions of f, reported in the package at path.
+func positioned(fset *token.FileSet, path string, f Finding) PositionedFinding {
+	p := PositionedFinding{
+		Finding:  f,
+		Package:  path,
+		Position: fset.Position(f.Pos),
+		End:      fset.Position(f.End),
+	}
+	for _, e := range f.Edits {
+		p.Edits = append(p.Edits, PositionedEdit{Position: fset.Position(e.Pos), End: fset.Position(e.End), NewText: e.NewText})
+	}
+	return p
+}
+
+func sortPositioned(findings []PositionedFinding) {
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i].Position, findings[j].Position
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+}
+
+// batchFacts shares set facts between the packages of a RunBatch. Each
+// package sees its imports through its own types.Objects, so facts are keyed
+// by package path and name; only package-level variables carry them.
+type batchFacts struct {
+	mu   sync.Mutex
+	sets map[string]int
+}
+
+func factKey(obj types.Object) string {
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+func (f *batchFacts) importSet(obj types.Object) (int, bool) {
+	if obj.Pkg() == nil {
+		return 0, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	writes, ok := f.sets[factKey(obj)]
+	return writes, ok
+}
+
+func (f *batchFacts) exportSet(obj types.Object, writes int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sets[factKey(obj)] = writes
+}
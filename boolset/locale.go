@@ -0,0 +1,83 @@
+package boolset
+
+import (
+	"embed"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// The message catalogs hold a template per rule, named by its ID, and one
+// for its fix title, named ID.fix, executed with the Finding. A file is
+// named by the language code it translates to; English is built in.
+//
+//go:embed messages/*.tmpl
+var messageFiles embed.FS
+
+var catalogs sync.Map // language -> *template.Template, nil when missing
+
+// catalog returns the message templates for locale, or nil when locale is
+// English or has no catalog.
+func catalog(locale string) *template.Template {
+	lang := language(locale)
+	if lang == "" || lang == "en" {
+		return nil
+	}
+	if t, ok := catalogs.Load(lang); ok {
+		return t.(*template.Template)
+	}
+	// A missing file means the language is not translated; the embedded
+	// files are parsed by the tests.
+	t, err := template.ParseFS(messageFiles, "messages/"+lang+".tmpl")
+	if err != nil {
+		t = nil
+	}
+	actual, _ := catalogs.LoadOrStore(lang, t)
+	return actual.(*template.Template)
+}
+
+// Localize returns f with its message and fix title translated to locale,
+// for tools that keep the English message to identify findings, as in
+// baselines, and show the translation.
+func Localize(f Finding, locale string) Finding {
+	s := settings{catalog: catalog(locale)}
+	s.rewriteMessage(&f)
+	return f
+}
+
+// language returns the language code of a locale such as "de", "de_DE" or
+// "de_DE.UTF-8", or "" for the C and POSIX locales.
+func language(locale string) string {
+	lang, _, _ := strings.Cut(locale, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	lang, _, _ = strings.Cut(lang, "_")
+	lang, _, _ = strings.Cut(lang, "-")
+	lang = strings.ToLower(lang)
+	if lang == "c" || lang == "posix" {
+		return ""
+	}
+	return lang
+}
+
+// Locales returns the languages diagnostics can be translated to, besides
+// English.
+func Locales() []string {
+	entries, _ := messageFiles.ReadDir("messages")
+	var langs []string
+	for _, e := range entries {
+		langs = append(langs, strings.TrimSuffix(e.Name(), ".tmpl"))
+	}
+	return langs
+}
+
+// LocaleFromEnv returns the locale messages should use according to the
+// environment: LC_ALL, LC_MESSAGES or LANG, whichever is set first.
+func LocaleFromEnv() string {
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package boolset
+
+import "sync"
+
+// Metrics counts what analysis runs did, for services embedding the
+// analyzer that report telemetry. Set Options.Metrics to have a run add its
+// counts; one Metrics may be shared by concurrent runs, such as the packages
+// of a RunBatch, and read once they have returned.
+type Metrics struct {
+	mu sync.Mutex
+	// Files counts the syntax trees inspected: whole files, or the
+	// declaration given to AnalyzeFuncDecl.
+	Files int
+	// Maps counts the map[T]bool objects tracked, counting the variables
+	// aliasing one map, as in alias := set, once.
+	Maps int
+	// Flagged counts the maps reported.
+	Flagged int
+	// Writes counts the stores into tracked maps.
+	Writes int
+}
+
+// add adds the counts of one run. It does nothing on nil Metrics.
+func (m *Metrics) add(files int, a *analyzer, flagged int) {
+	if m == nil {
+		return
+	}
+	// Aliases share one record, and so count as one map.
+	seen := make(map[*mapInfo]bool)
+	writes := 0
+	for _, mi := range a.results {
+		if mi == nil || seen[mi] {
+			continue
+		}
+		seen[mi] = true
+		writes += len(mi.writes)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Files += files
+	m.Maps += len(seen)
+	m.Flagged += flagged
+	m.Writes += writes
+}
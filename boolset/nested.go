@@ -0,0 +1,118 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// elemObject returns the object standing for the elements of the map, slice
+// or array obj of this package, such as index[a] for index map[A]map[B]bool
+// or buckets[i] for buckets []map[K]bool, or nil when they hold no bool
+// maps. All the maps held are tracked as one: a write to any of them counts
+// for each, as an index expression may yield any of them.
+func (a *analyzer) elemObject(obj types.Object) types.Object {
+	if obj == nil || obj.Pkg() != a.pkg {
+		return nil
+	}
+	if elem, ok := a.elems[obj]; ok {
+		return elem
+	}
+	t := elemType(obj.Type())
+	if !holdsBoolMap(t) {
+		return nil
+	}
+	elem := types.NewVar(obj.Pos(), obj.Pkg(), obj.Name()+"[]", t)
+	a.elems[obj] = elem
+	holder, ok := a.holders[obj]
+	if !ok {
+		holder = obj
+	}
+	a.holders[elem] = holder
+	if mi := a.infoFor(elem); mi != nil {
+		// Rewriting the held maps means rewriting the holder's type.
+		mi.unfixable = true
+		if mi.obj == elem {
+			mi.holder = holder
+			mi.end = mi.pos + token.Pos(len(holder.Name()))
+		}
+	}
+	return elem
+}
+
+// elemType returns the type of the elements of the map, slice, array or
+// array pointer t, or nil.
+func elemType(t types.Type) types.Type {
+	switch u := typeUnderlying(t).(type) {
+	case *types.Map:
+		return u.Elem()
+	case *types.Slice:
+		return u.Elem()
+	case *types.Array:
+		return u.Elem()
+	case *types.Pointer:
+		if arr, ok := u.Elem().Underlying().(*types.Array); ok {
+			return arr.Elem()
+		}
+	}
+	return nil
+}
+
+// holdsBoolMap reports whether t is a bool map, or holds one at any depth.
+func holdsBoolMap(t types.Type) bool {
+	for ; t != nil; t = elemType(t) {
+		if m, ok := typeUnderlying(t).(*types.Map); ok && isBool(m.Elem()) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackedMap returns the tracked map obj is, or the one standing for the
+// maps it holds at any depth, or nil.
+func (a *analyzer) trackedMap(obj types.Object) *mapInfo {
+	for ; obj != nil; obj = a.elemObject(obj) {
+		if mi := a.infoFor(obj); mi != nil {
+			return mi
+		}
+	}
+	return nil
+}
+
+// handleRangeElems makes the value variable of a range loop over a map,
+// slice or array of bool maps an alias of the maps held, as in
+// for _, inner := range index.
+func (a *analyzer) handleRangeElems(rng *ast.RangeStmt) {
+	if rng.Value == nil {
+		return
+	}
+	mi := a.infoFor(a.objectOfAssignable(rng.Value))
+	if mi == nil {
+		return
+	}
+	if src := a.infoFor(a.elemObject(a.mapObject(ast.Unparen(rng.X)))); src != nil {
+		a.mapAliases = append(a.mapAliases, [2]*mapInfo{mi, src})
+	}
+}
+
+// handleAppend makes the maps append and copy store in a slice of bool maps
+// aliases of the maps it holds, as in buckets = append(buckets, m).
+func (a *analyzer) handleAppend(call *ast.CallExpr) {
+	if len(call.Args) < 2 {
+		return
+	}
+	elem := a.infoFor(a.elemObject(a.mapObject(ast.Unparen(call.Args[0]))))
+	if elem == nil {
+		return
+	}
+	if call.Ellipsis.IsValid() || a.builtin(call) == "copy" {
+		// The elements of another slice.
+		if src := a.infoFor(a.elemObject(a.mapObject(ast.Unparen(call.Args[1])))); src != nil {
+			a.mapAliases = append(a.mapAliases, [2]*mapInfo{elem, src})
+		}
+		return
+	}
+	for _, arg := range call.Args[1:] {
+		elem.recordInit(a, arg)
+	}
+}
@@ -0,0 +1,108 @@
+package boolset
+
+import (
+	"context"
+	"go/ast"
+	"go/types"
+	"log/slog"
+	"text/template"
+)
+
+// Options configure AnalyzeWithOptions. They mirror the analyzer's flags;
+// the zero value selects what Analyze reports, as DefaultOptions does.
+type Options struct {
+	// MinWrites is the number of true writes a map needs before it is
+	// reported. Values below 1 are treated as 1.
+	MinWrites int
+	// ExcludeFields skips maps stored in struct fields.
+	ExcludeFields bool
+	// ExcludeGlobals skips package-level maps.
+	ExcludeGlobals bool
+	// KeyTypeFilter, if set, reports whether maps with the given key type
+	// are reported.
+	KeyTypeFilter func(key types.Type) bool
+	// Checks lists the IDs of the rules to run, such as RuleMapBoolSet. A
+	// nil slice runs every rule.
+	Checks []string
+	// Severities maps rule IDs to the severity their diagnostics are
+	// reported with. Rules not listed keep their default, SeverityWarning
+	// for RuleMapBoolSet.
+	Severities map[string]Severity
+	// Messages maps rule IDs to templates replacing the message of their
+	// diagnostics, to rephrase or localise them. A template is executed
+	// with the Finding, whose Message holds the default text; the default
+	// is kept if execution fails.
+	Messages map[string]*template.Template
+	// Locale selects the language of messages without a template in
+	// Messages, as a code such as "de" or a locale such as "de_DE.UTF-8";
+	// LocaleFromEnv reads it from the environment. Messages stay in English
+	// for locales Locales does not list.
+	Locale string
+	// Filter, if set, is called with each finding before it is emitted
+	// and drops those it returns false for, so embedders can apply their
+	// own suppression rules. Dropped maps are not Reported in the Result.
+	Filter func(Finding) bool
+	// Logger, if set, receives debug-level records explaining what is not
+	// reported: maps with true writes excluded by these options or dropped
+	// by Filter, findings offered without a fix because the map escapes or
+	// cannot be rewritten, and packages RunBatch skips.
+	Logger *slog.Logger
+	// Metrics, if set, has the counts of every run using these options
+	// added to it.
+	Metrics *Metrics
+	// SSA decides whether stored values are true on the package's SSA
+	// form, following them through phi nodes, calls to functions of the
+	// package and variables captured or addressed, at the cost of building
+	// it. The syntax-based analysis decides for packages whose type
+	// information cannot be built into SSA, such as one without
+	// Selections or Implicits.
+	SSA bool
+	// IncludeSerialized reports maps encoded with encoding/json, yaml,
+	// xml, gob or toml, directly, as struct fields of encoded values or as
+	// fields with a tag for them. Converting them changes the encoded
+	// form, so they are excluded by default.
+	IncludeSerialized bool
+}
+
+// DefaultOptions returns the options Analyze uses.
+func DefaultOptions() Options {
+	s := defaultSettings()
+	return Options{MinWrites: s.minWrites, ExcludeFields: !s.includeFields, ExcludeGlobals: !s.includeGlobals}
+}
+
+// AnalyzeWithOptions is like Analyze, reporting only what opts select.
+func AnalyzeWithOptions(pkg *types.Package, files []*ast.File, info *types.Info, opts Options) []Diagnostic {
+	return diagnostics(AnalyzeFindings(pkg, files, info, opts))
+}
+
+// AnalyzeFindings is like AnalyzeWithOptions, describing each reported map
+// in detail.
+func AnalyzeFindings(pkg *types.Package, files []*ast.File, info *types.Info, opts Options) []Finding {
+	s := opts.settings()
+	findings, _, _ := analyze(context.Background(), pkg, files, info, nil, &s, nil)
+	return findings
+}
+
+func (opts Options) settings() settings {
+	s := settings{
+		minWrites:         max(opts.MinWrites, 1),
+		includeFields:     !opts.ExcludeFields,
+		includeGlobals:    !opts.ExcludeGlobals,
+		keyFilter:         opts.KeyTypeFilter,
+		severities:        opts.Severities,
+		messages:          opts.Messages,
+		catalog:           catalog(opts.Locale),
+		filter:            opts.Filter,
+		logger:            opts.Logger,
+		metrics:           opts.Metrics,
+		ssa:               opts.SSA,
+		includeSerialized: opts.IncludeSerialized,
+	}
+	if opts.Checks != nil {
+		s.checks = make(map[string]struct{}, len(opts.Checks))
+		for _, id := range opts.Checks {
+			s.checks[id] = struct{}{}
+		}
+	}
+	return s
+}
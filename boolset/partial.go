@@ -0,0 +1,73 @@
+package boolset
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+)
+
+// AnalyzeFile analyses a single file of a type-checked package, for tools
+// that re-analyse only what changed. info must cover the whole package.
+// Only maps declared in file and referenced nowhere else are reported;
+// package-level maps used across files need Analyze.
+func AnalyzeFile(pkg *types.Package, file *ast.File, info *types.Info) []Diagnostic {
+	if file == nil {
+		return nil
+	}
+	return analyzePartial(pkg, file, info)
+}
+
+// AnalyzeFuncDecl analyses a single function declaration of a type-checked
+// package. info must cover the whole package. Only maps declared within
+// decl, such as its locals and parameters, are reported.
+func AnalyzeFuncDecl(pkg *types.Package, decl *ast.FuncDecl, info *types.Info) []Diagnostic {
+	if decl == nil {
+		return nil
+	}
+	return analyzePartial(pkg, decl, info)
+}
+
+func analyzePartial(pkg *types.Package, n ast.Node, info *types.Info) []Diagnostic {
+	s := defaultSettings()
+	var findings []Finding
+	analyzeNodes(context.Background(), pkg, []ast.Node{n}, nil, true, info, &s, nil, func(f Finding) {
+		findings = append(findings, f)
+	})
+	return diagnostics(findings)
+}
+
+// dropEscaping forgets the maps declared or referenced outside nodes.
+func (a *analyzer) dropEscaping(nodes []ast.Node) {
+	inside := func(pos token.Pos) bool {
+		for _, n := range nodes {
+			if pos >= n.Pos() && pos < n.End() {
+				return true
+			}
+		}
+		return false
+	}
+	// Aliases share one record; forgetting one forgets them all.
+	drop := make(map[*mapInfo]bool)
+	for obj, mi := range a.results {
+		if !inside(obj.Pos()) {
+			drop[mi] = true
+		}
+	}
+	for id, obj := range a.info.Uses {
+		if inside(id.Pos()) {
+			continue
+		}
+		// Maps held by the map used are reached through it too.
+		for ; obj != nil; obj = a.elems[obj] {
+			if mi, ok := a.results[obj]; ok {
+				drop[mi] = true
+			}
+		}
+	}
+	for obj, mi := range a.results {
+		if drop[mi] {
+			delete(a.results, obj)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// testingPrinters are the methods of the testing package that format their
+// arguments as fmt.Print does.
+var testingPrinters = map[string]bool{
+	"Log": true, "Logf": true,
+	"Error": true, "Errorf": true,
+	"Fatal": true, "Fatalf": true,
+	"Skip": true, "Skipf": true,
+}
+
+// isPrinter reports whether fn formats its arguments as text: the
+// functions and methods of fmt and log, and the logging methods of testing.
+func isPrinter(fn *types.Func) bool {
+	switch fn.Pkg().Path() {
+	case "fmt", "log":
+		return true
+	case "testing":
+		return testingPrinters[fn.Name()]
+	}
+	return false
+}
+
+// handlePrint records the tracked maps the call to the printing function
+// fn formats, which map[T]struct{} would print as map[a:{}] rather than
+// map[a:true]: maps passed directly, and the bool map fields of the structs
+// passed, which %v prints whether exported or not.
+func (a *analyzer) handlePrint(call *ast.CallExpr, fn *types.Func) {
+	reason := "printed by " + fn.FullName()
+	for _, arg := range call.Args {
+		if mi := a.escapingMap(arg); mi != nil {
+			mi.recordEscape(reason)
+			continue
+		}
+		a.mapFields(a.info.TypeOf(arg), false, make(map[types.Type]bool), func(mi *mapInfo) {
+			mi.recordEscape(reason)
+		})
+	}
+}
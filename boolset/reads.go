@@ -0,0 +1,67 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/token"
+
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// collectReads records where tracked maps are read for membership: index
+// expressions outside assignments, comma-ok lookups and range loops. These
+// are the sites a conversion to map[T]struct{} has to rewrite by hand.
+// nodes are walked with in when it is not nil.
+func (a *analyzer) collectReads(nodes []ast.Node, in *inspector.Inspector) {
+	// Index expressions already accounted for as writes or comma-ok
+	// lookups by their enclosing assignment.
+	handled := make(map[*ast.IndexExpr]bool)
+	visit := func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			for _, lhs := range n.Lhs {
+				if idx, ok := ast.Unparen(lhs).(*ast.IndexExpr); ok {
+					handled[idx] = true
+				}
+			}
+			if len(n.Lhs) == 2 && len(n.Rhs) == 1 {
+				if idx, ok := ast.Unparen(n.Rhs[0]).(*ast.IndexExpr); ok {
+					handled[idx] = true
+					a.recordRead(idx.X, idx.Pos(), idx.End(), "comma-ok lookup")
+				}
+			}
+		case *ast.ValueSpec:
+			if len(n.Names) == 2 && len(n.Values) == 1 {
+				if idx, ok := ast.Unparen(n.Values[0]).(*ast.IndexExpr); ok {
+					handled[idx] = true
+					a.recordRead(idx.X, idx.Pos(), idx.End(), "comma-ok lookup")
+				}
+			}
+		case *ast.IndexExpr:
+			if !handled[n] {
+				a.recordRead(n.X, n.Pos(), n.End(), "lookup")
+			}
+		case *ast.RangeStmt:
+			a.recordRead(n.X, n.Pos(), n.X.End(), "range")
+		}
+	}
+	if in != nil {
+		in.Preorder([]ast.Node{(*ast.AssignStmt)(nil), (*ast.ValueSpec)(nil), (*ast.IndexExpr)(nil), (*ast.RangeStmt)(nil)}, visit)
+		return
+	}
+	for _, root := range nodes {
+		ast.Inspect(root, func(n ast.Node) bool {
+			visit(n)
+			return true
+		})
+	}
+}
+
+func (a *analyzer) recordRead(x ast.Expr, pos, end token.Pos, kind string) {
+	obj := a.mapObject(ast.Unparen(x))
+	if obj == nil {
+		return
+	}
+	if mi, ok := a.results[obj]; ok {
+		mi.reads = append(mi.reads, RelatedInformation{Pos: pos, End: end, Message: kind})
+	}
+}
@@ -0,0 +1,22 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// handleReflect records the tracked maps the call to fn, of the reflect
+// package, can inspect: maps passed directly, and the bool map fields of the
+// structs passed, which reflection reaches whether exported or not.
+func (a *analyzer) handleReflect(call *ast.CallExpr, fn *types.Func) {
+	reason := "used through " + fn.FullName()
+	for _, arg := range call.Args {
+		if mi := a.escapingMap(arg); mi != nil {
+			mi.recordEscape(reason)
+			continue
+		}
+		a.mapFields(a.info.TypeOf(arg), false, make(map[types.Type]bool), func(mi *mapInfo) {
+			mi.recordEscape(reason)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package boolset
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Reporter receives the findings of a run, for output formats and sinks
+// such as databases or message queues. Begin is called before the first
+// finding and End after the last, even when there are none; Report is
+// called once per finding, in output order. An error stops the run.
+type Reporter interface {
+	Begin() error
+	Report(PositionedFinding) error
+	End() error
+}
+
+var reporters = struct {
+	mu  sync.Mutex
+	new map[string]func(io.Writer) Reporter
+}{new: map[string]func(io.Writer) Reporter{
+	"text": func(w io.Writer) Reporter { return textReporter{w} },
+	"json": func(w io.Writer) Reporter { return jsonReporter{json.NewEncoder(w)} },
+}}
+
+// RegisterReporter makes a reporter available under name to NewReporter,
+// and so to boolsetlint's -format flag in builds that import the package
+// registering it. newReporter is called once per run with the writer
+// output goes to. RegisterReporter panics if name is already registered.
+func RegisterReporter(name string, newReporter func(w io.Writer) Reporter) {
+	reporters.mu.Lock()
+	defer reporters.mu.Unlock()
+	if _, ok := reporters.new[name]; ok {
+		panic(fmt.Sprintf("boolset: RegisterReporter called twice for %q", name))
+	}
+	reporters.new[name] = newReporter
+}
+
+// NewReporter returns the reporter registered under name, writing to w.
+// The built-in reporters are "text", printing file:line:column: message
+// (rule) lines, and "json", printing a JSON object per finding and line.
+func NewReporter(name string, w io.Writer) (Reporter, bool) {
+	reporters.mu.Lock()
+	newReporter, ok := reporters.new[name]
+	reporters.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	return newReporter(w), true
+}
+
+// Reporters returns the names of the registered reporters, sorted.
+func Reporters() []string {
+	reporters.mu.Lock()
+	defer reporters.mu.Unlock()
+	names := make([]string, 0, len(reporters.new))
+	for name := range reporters.new {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type textReporter struct {
+	w io.Writer
+}
+
+func (textReporter) Begin() error { return nil }
+
+func (r textReporter) Report(f PositionedFinding) error {
+	_, err := fmt.Fprintf(r.w, "%s:%d:%d: %s (%s)\n", f.Position.Filename, f.Position.Line, f.Position.Column, f.Message, f.Rule)
+	return err
+}
+
+func (textReporter) End() error { return nil }
+
+type jsonReporter struct {
+	enc *json.Encoder
+}
+
+func (jsonReporter) Begin() error { return nil }
+
+func (r jsonReporter) Report(f PositionedFinding) error {
+	return r.enc.Encode(f)
+}
+
+func (jsonReporter) End() error { return nil }
@@ -0,0 +1,100 @@
+package boolset
+
+import (
+	"context"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"sort"
+)
+
+// Result is the analyzer's model of how a package uses its map[T]bool
+// values. It is returned as the analyzer's result so other analyzers can
+// require boolset instead of re-implementing the tracking.
+type Result struct {
+	// Maps holds every tracked map variable or field, including maps
+	// declared in other packages that this package writes to.
+	Maps map[types.Object]*MapUsage
+}
+
+// MapUsage profiles the reads and writes of one map[T]bool object.
+type MapUsage struct {
+	Object types.Object
+	// KeyType is the key type as printed in diagnostics.
+	KeyType string
+	// Writes lists the index assignments and composite literal entries
+	// storing into the map, in source order per file.
+	Writes []Write
+	// Reads lists the map's membership reads, in source order: lookups,
+	// comma-ok lookups and range loops.
+	Reads []RelatedInformation
+	// OtherUses counts references to the map that are neither writes nor
+	// initialisations: membership reads, passing the map around, and so on.
+	OtherUses int
+	// OnlyTrue reports whether every write is provably true.
+	OnlyTrue bool
+	// Reported is set when the package's diagnostics include this map.
+	Reported bool
+}
+
+// Write is a single store into a map.
+type Write struct {
+	Pos token.Pos
+	// Value is the stored value, or the map whose values maps.Copy or
+	// maps.Clone stores.
+	Value ast.Expr
+	// True is set when Value is provably true.
+	True bool
+}
+
+// Inventory returns every map[T]bool object the analysis tracks in the
+// package, whether or not Analyze reports it, sorted by declaration
+// position. Teams can use it to measure how widespread the pattern is or to
+// build their own policies from the write and read profiles.
+func Inventory(pkg *types.Package, files []*ast.File, info *types.Info) []*MapUsage {
+	s := defaultSettings()
+	res, err := analyzeFunc(context.Background(), pkg, files, info, nil, &s, nil, func(Finding) {})
+	if err != nil {
+		return nil
+	}
+	maps := make([]*MapUsage, 0, len(res.Maps))
+	for _, usage := range res.Maps {
+		maps = append(maps, usage)
+	}
+	sort.Slice(maps, func(i, j int) bool {
+		if pi, pj := maps[i].Object.Pos(), maps[j].Object.Pos(); pi != pj {
+			return pi < pj
+		}
+		return maps[i].KeyType < maps[j].KeyType
+	})
+	return maps
+}
+
+func sortedReads(reads []RelatedInformation) []RelatedInformation {
+	reads = append([]RelatedInformation(nil), reads...)
+	sort.Slice(reads, func(i, j int) bool { return reads[i].Pos < reads[j].Pos })
+	return reads
+}
+
+var resultType = reflect.TypeOf((*Result)(nil))
+
+func (a *analyzer) result(reported map[*mapInfo]bool) *Result {
+	res := &Result{Maps: make(map[types.Object]*MapUsage, len(a.results))}
+	for obj, mi := range a.results {
+		other := mi.uses - mi.knownUses
+		if other < 0 {
+			other = 0
+		}
+		res.Maps[obj] = &MapUsage{
+			Object:    obj,
+			KeyType:   mi.keyType,
+			Writes:    mi.writes,
+			Reads:     sortedReads(mi.reads),
+			OtherUses: other,
+			OnlyTrue:  mi.onlyTrue,
+			Reported:  reported[mi],
+		}
+	}
+	return res
+}
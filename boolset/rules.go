@@ -0,0 +1,79 @@
+package boolset
+
+import (
+	"runtime/debug"
+	"sort"
+)
+
+// modulePath is the module the package is built from, looked up in the
+// build information of the binary importing it.
+const modulePath = "github.com/arturmelanchyk/boolset"
+
+// Rule describes a check the analysis can report.
+type Rule struct {
+	// ID is the stable identifier diagnostics carry in Diagnostic.Rule.
+	ID string
+	// Name is a short, human-readable name for the rule.
+	Name string
+	// Summary is a one-line description of what the rule reports.
+	Summary string
+	// URL documents the rule.
+	URL string
+	// Severity is the severity the rule reports with unless Options
+	// override it.
+	Severity Severity
+}
+
+// Rules returns the rules the analysis can report, sorted by ID: BS0001 and
+// the checks added with RegisterCheck.
+func Rules() []Rule {
+	rules := []Rule{{
+		ID:       RuleMapBoolSet,
+		Name:     "map-bool-set",
+		Summary:  "map[T]bool used as a set",
+		URL:      RuleURL(RuleMapBoolSet),
+		Severity: ruleSeverities[RuleMapBoolSet],
+	}}
+	registry.mu.Lock()
+	for _, c := range registry.checks {
+		rules = append(rules, c.rule)
+	}
+	registry.mu.Unlock()
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// Version returns the version of the boolset module linked into the running
+// binary, as recorded in its build information, or "(devel)" when the module
+// is built from a work tree or the binary carries no build information.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	return moduleVersion(info)
+}
+
+// moduleVersion finds the boolset module in info.
+func moduleVersion(info *debug.BuildInfo) string {
+	mod := &info.Main
+	if mod.Path != modulePath {
+		mod = nil
+		for _, dep := range info.Deps {
+			if dep.Path == modulePath {
+				mod = dep
+				break
+			}
+		}
+	}
+	if mod == nil {
+		return "(devel)"
+	}
+	if mod.Replace != nil {
+		mod = mod.Replace
+	}
+	if mod.Version == "" {
+		return "(devel)"
+	}
+	return mod.Version
+}
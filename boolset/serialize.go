@@ -0,0 +1,122 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// serializers are the import paths of encoding packages: a map they encode
+// reads {"a":true} where map[T]struct{} would read {"a":{}}.
+var serializers = []string{
+	"encoding/json",
+	"encoding/xml",
+	"encoding/gob",
+	"gopkg.in/yaml.v2",
+	"gopkg.in/yaml.v3",
+	"sigs.k8s.io/yaml",
+	"github.com/goccy/go-yaml",
+	"github.com/BurntSushi/toml",
+	"github.com/pelletier/go-toml",
+	"github.com/pelletier/go-toml/v2",
+}
+
+// serializationTags are the struct tag keys encoding packages read.
+var serializationTags = []string{"json", "xml", "yaml", "toml"}
+
+func isSerializer(path string) bool {
+	for _, s := range serializers {
+		if path == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isDecoder reports whether fn, of an encoding package, stores decoded
+// values in its arguments rather than encoding them.
+func isDecoder(fn *types.Func) bool {
+	return strings.HasPrefix(fn.Name(), "Unmarshal") || strings.HasPrefix(fn.Name(), "Decode")
+}
+
+// handleSerialization records the tracked maps the call to the encoding
+// function fn encodes: maps passed directly, and the bool map fields,
+// exported and of this package, of the structs passed.
+func (a *analyzer) handleSerialization(call *ast.CallExpr, fn *types.Func) {
+	reason := "encoded by " + fn.FullName()
+	for _, arg := range call.Args {
+		if mi := a.escapingMap(arg); mi != nil {
+			mi.recordSerialized(reason)
+			continue
+		}
+		a.mapFields(a.info.TypeOf(arg), true, make(map[types.Type]bool), func(mi *mapInfo) {
+			mi.recordSerialized(reason)
+		})
+	}
+}
+
+// mapFields calls record for the bool map fields of this package in the
+// struct t, or the structs it points to or holds, and in the structs those
+// embed or hold. With exported set, only exported fields and embedded
+// structs are followed, as encoding packages do.
+func (a *analyzer) mapFields(t types.Type, exported bool, seen map[types.Type]bool, record func(*mapInfo)) {
+	if t == nil || seen[t] {
+		return
+	}
+	seen[t] = true
+	switch u := t.Underlying().(type) {
+	case *types.Pointer:
+		a.mapFields(u.Elem(), exported, seen, record)
+	case *types.Slice:
+		a.mapFields(u.Elem(), exported, seen, record)
+	case *types.Array:
+		a.mapFields(u.Elem(), exported, seen, record)
+	case *types.Struct:
+		for i := range u.NumFields() {
+			f := u.Field(i)
+			if exported && !f.Exported() && !f.Embedded() {
+				continue
+			}
+			if f.Pkg() == a.pkg {
+				if mi := a.trackedMap(f); mi != nil {
+					record(mi)
+					continue
+				}
+			}
+			a.mapFields(f.Type(), exported, seen, record)
+		}
+	}
+}
+
+// handleTags records the bool map fields declared with a struct tag for an
+// encoding package, which name them in an encoded form.
+func (a *analyzer) handleTags(field *ast.Field) {
+	if field.Tag == nil {
+		return
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return
+	}
+	for _, key := range serializationTags {
+		value, ok := reflect.StructTag(tag).Lookup(key)
+		if !ok || value == "-" {
+			continue
+		}
+		for _, name := range field.Names {
+			if mi := a.trackedMap(a.info.Defs[name]); mi != nil {
+				mi.recordSerialized("has a " + key + " tag")
+			}
+		}
+		return
+	}
+}
+
+// recordSerialized notes why mi is encoded, keeping the first reason.
+func (mi *mapInfo) recordSerialized(reason string) {
+	if mi.serialized == "" {
+		mi.serialized = reason
+	}
+}
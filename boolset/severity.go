@@ -0,0 +1,58 @@
+package boolset
+
+import "fmt"
+
+// Severity ranks how seriously a diagnostic should be taken, for output
+// formats and tools with their own severity schemes.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+// ruleSeverities holds each rule's severity unless Options override it.
+var ruleSeverities = map[string]Severity{
+	RuleMapBoolSet: SeverityWarning,
+}
+
+// String returns "info", "warning" or "error".
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText encodes s as its String form.
+func (s Severity) MarshalText() ([]byte, error) {
+	if s < SeverityInfo || s > SeverityError {
+		return nil, fmt.Errorf("boolset: invalid severity %d", int(s))
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes "info", "warning" or "error".
+func (s *Severity) UnmarshalText(text []byte) error {
+	for _, v := range []Severity{SeverityInfo, SeverityWarning, SeverityError} {
+		if string(text) == v.String() {
+			*s = v
+			return nil
+		}
+	}
+	return fmt.Errorf("boolset: unknown severity %q", text)
+}
+
+// severity returns the severity rule is reported with.
+func (s *settings) severity(rule string) Severity {
+	if sev, ok := s.severities[rule]; ok {
+		return sev
+	}
+	return ruleSeverities[rule]
+}
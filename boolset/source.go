@@ -0,0 +1,81 @@
+package boolset
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+)
+
+// AnalyzeSource parses and type-checks src, a package's Go files keyed by
+// file name, and analyses it with the default options, for tests and tools
+// that hold source rather than a loaded package. Imports are type-checked
+// from their source in GOROOT and GOPATH. Findings are sorted by position
+// and name their package by its package clause. Parse and type errors are
+// returned, joined, with no findings.
+func AnalyzeSource(src map[string]string) ([]PositionedFinding, error) {
+	return New().AnalyzeSource(context.Background(), src)
+}
+
+// AnalyzeSource is like the AnalyzeSource function, with the Linter's
+// options.
+func (l *Linter) AnalyzeSource(ctx context.Context, src map[string]string) ([]PositionedFinding, error) {
+	names := make([]string, 0, len(src))
+	for name := range src {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fset := token.NewFileSet()
+	var (
+		files []*ast.File
+		errs  []error
+	)
+	for _, name := range names {
+		file, err := parser.ParseFile(fset, name, src[name], parser.ParseComments)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		files = append(files, file)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Instances:  make(map[*ast.Ident]types.Instance),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Implicits:  make(map[ast.Node]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Scopes:     make(map[ast.Node]*types.Scope),
+	}
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error:    func(err error) { errs = append(errs, err) },
+	}
+	pkg, _ := conf.Check(files[0].Name.Name, fset, files, info)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	s := l.opts.settings()
+	var findings []PositionedFinding
+	_, err := analyzeFunc(ctx, pkg, files, info, nil, &s, nil, func(f Finding) {
+		findings = append(findings, positioned(fset, pkg.Path(), f))
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortPositioned(findings)
+	return findings, nil
+}
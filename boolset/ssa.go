@@ -0,0 +1,265 @@
+package boolset
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/ssa"
+)
+
+// ssaTruth builds pkg in SSA form and decides, for each map update in it,
+// whether the stored value is true, following bool values through phi
+// nodes, local variables whose address is taken or captured by closures,
+// and calls to functions of the package. The result is keyed by the
+// position of the update: the Lbrack of an index expression or the Colon
+// of a composite literal element. Values looked up in, or ranged over from,
+// another map are left out: whether that map only stores true is for the
+// analyzer to decide.
+func ssaTruth(pkg *types.Package, files []*ast.File, info *types.Info) (truth map[token.Pos]bool, err error) {
+	defer func() {
+		// The builder panics on syntax and type information it cannot
+		// use, such as a types.Info lacking Selections.
+		if r := recover(); r != nil {
+			truth, err = nil, fmt.Errorf("building SSA: %v", r)
+		}
+	}()
+	prog := ssa.NewProgram(token.NewFileSet(), 0)
+	created := make(map[*types.Package]bool)
+	var create func([]*types.Package)
+	create = func(pkgs []*types.Package) {
+		for _, p := range pkgs {
+			if !created[p] {
+				created[p] = true
+				prog.CreatePackage(p, nil, nil, true)
+				create(p.Imports())
+			}
+		}
+	}
+	create(pkg.Imports())
+	ssapkg := prog.CreatePackage(pkg, files, info, false)
+	ssapkg.Build()
+
+	t := &ssaTracker{pkg: ssapkg, visiting: make(map[ssaGoal]bool)}
+	truth = make(map[token.Pos]bool)
+	var visit func(fn *ssa.Function)
+	visit = func(fn *ssa.Function) {
+		for _, b := range fn.Blocks {
+			for _, instr := range b.Instrs {
+				if u, ok := instr.(*ssa.MapUpdate); ok && u.Pos().IsValid() {
+					if !fromMap(u.Value) {
+						truth[u.Pos()] = t.isTrue(u.Value)
+					}
+				}
+			}
+		}
+		for _, anon := range fn.AnonFuncs {
+			visit(anon)
+		}
+	}
+	for _, mem := range ssapkg.Members {
+		switch mem := mem.(type) {
+		case *ssa.Function:
+			visit(mem)
+		case *ssa.Type:
+			// The declared methods, including those of generic types,
+			// which have no method values.
+			if named, ok := mem.Type().(*types.Named); ok {
+				for i := range named.NumMethods() {
+					if fn := prog.FuncValue(named.Method(i)); fn != nil {
+						visit(fn)
+					}
+				}
+			}
+		}
+	}
+	return truth, nil
+}
+
+// ssaTracker decides whether SSA values are true.
+type ssaTracker struct {
+	pkg *ssa.Package
+	// visiting holds the goals being decided. A goal met again is on a
+	// cycle, such as through a phi node of a loop, and assumed to hold:
+	// the cycle adds no value that is not decided elsewhere.
+	visiting map[ssaGoal]bool
+}
+
+// ssaGoal is the question whether a value always holds want.
+type ssaGoal struct {
+	v    ssa.Value
+	want bool
+}
+
+func (t *ssaTracker) isTrue(v ssa.Value) bool {
+	return t.is(v, true)
+}
+
+// is reports whether v always holds want.
+func (t *ssaTracker) is(v ssa.Value, want bool) bool {
+	goal := ssaGoal{v, want}
+	if t.visiting[goal] {
+		return true
+	}
+	t.visiting[goal] = true
+	defer delete(t.visiting, goal)
+
+	switch v := v.(type) {
+	case *ssa.Const:
+		return v.Value != nil && v.Value.Kind() == constant.Bool && constant.BoolVal(v.Value) == want
+	case *ssa.Phi:
+		for _, e := range v.Edges {
+			if !t.is(e, want) {
+				return false
+			}
+		}
+		return true
+	case *ssa.ChangeType:
+		return t.is(v.X, want)
+	case *ssa.Convert:
+		return t.is(v.X, want)
+	case *ssa.UnOp:
+		switch v.Op {
+		case token.NOT:
+			return t.is(v.X, !want)
+		case token.MUL:
+			return t.storesOnly(v.X, want)
+		}
+	case *ssa.Call:
+		return t.returns(v, 0, want)
+	case *ssa.Extract:
+		if call, ok := v.Tuple.(*ssa.Call); ok {
+			return t.returns(call, v.Index, want)
+		}
+	}
+	return false
+}
+
+// returns reports whether the result at index of call, to a function of
+// the package, always holds want.
+func (t *ssaTracker) returns(call *ssa.Call, index int, want bool) bool {
+	fn := call.Call.StaticCallee()
+	if fn == nil || fn.Pkg != t.pkg || len(fn.Blocks) == 0 {
+		return false
+	}
+	returned := false
+	for _, b := range fn.Blocks {
+		if ret, ok := b.Instrs[len(b.Instrs)-1].(*ssa.Return); ok {
+			if index >= len(ret.Results) || !t.is(ret.Results[index], want) {
+				return false
+			}
+			returned = true
+		}
+	}
+	return returned
+}
+
+// storesOnly reports whether the variable at addr, a local allocation or
+// a closure's captured variable, always holds want when loaded.
+func (t *ssaTracker) storesOnly(addr ssa.Value, want bool) bool {
+	switch addr := addr.(type) {
+	case *ssa.Alloc:
+		return t.storedFirst(addr) && t.storesTo(addr, want)
+	case *ssa.FreeVar:
+		fn := addr.Parent()
+		index := -1
+		for i, fv := range fn.FreeVars {
+			if fv == addr {
+				index = i
+			}
+		}
+		parent := fn.Parent()
+		if index < 0 || parent == nil {
+			return false
+		}
+		bound := false
+		for _, b := range parent.Blocks {
+			for _, instr := range b.Instrs {
+				if mc, ok := instr.(*ssa.MakeClosure); ok && mc.Fn == fn {
+					if !t.storesOnly(mc.Bindings[index], want) {
+						return false
+					}
+					bound = true
+				}
+			}
+		}
+		return bound
+	}
+	return false
+}
+
+// storedFirst reports whether the first use of alloc in its block stores
+// to it, so its zero value is never loaded.
+func (t *ssaTracker) storedFirst(alloc *ssa.Alloc) bool {
+	refs := *alloc.Referrers()
+	seen := false
+	for _, instr := range alloc.Block().Instrs {
+		if instr == ssa.Instruction(alloc) {
+			seen = true
+			continue
+		}
+		if !seen || !refersTo(refs, instr) {
+			continue
+		}
+		store, ok := instr.(*ssa.Store)
+		return ok && store.Addr == alloc
+	}
+	return false
+}
+
+// storesTo reports whether every store to the variable at addr, directly
+// or through the closures capturing it, stores want, and whether the
+// address is otherwise only loaded.
+func (t *ssaTracker) storesTo(addr ssa.Value, want bool) bool {
+	for _, instr := range *addr.Referrers() {
+		switch instr := instr.(type) {
+		case *ssa.Store:
+			if instr.Addr != addr || !t.is(instr.Val, want) {
+				return false
+			}
+		case *ssa.UnOp:
+			if instr.Op != token.MUL {
+				return false
+			}
+		case *ssa.DebugRef:
+		case *ssa.MakeClosure:
+			fn, ok := instr.Fn.(*ssa.Function)
+			if !ok {
+				return false
+			}
+			for i, b := range instr.Bindings {
+				if b == addr && !t.storesTo(fn.FreeVars[i], want) {
+					return false
+				}
+			}
+		default:
+			// The address escapes, for example into a call.
+			return false
+		}
+	}
+	return true
+}
+
+// fromMap reports whether v is a value read from a map, by lookup or by
+// ranging over it.
+func fromMap(v ssa.Value) bool {
+	switch v := v.(type) {
+	case *ssa.Lookup:
+		return true
+	case *ssa.Extract:
+		next, ok := v.Tuple.(*ssa.Next)
+		return ok && !next.IsString
+	}
+	return false
+}
+
+func refersTo(refs []ssa.Instruction, instr ssa.Instruction) bool {
+	for _, r := range refs {
+		if r == instr {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package printer
+
+import "fmt"
+
+// Printed is written through a package that imports fmt, so the analysis
+// also runs on fmt's dependencies, unsafe among them.
+func Printed(names []string) {
+	seen := map[string]bool{} // want `map\[string\]bool only stores "true" values`
+	for _, name := range names {
+		seen[name] = true
+	}
+	fmt.Println(len(seen))
+}
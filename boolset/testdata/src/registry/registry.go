@@ -0,0 +1,15 @@
+package registry
+
+// Handlers is populated by the packages that import registry.
+var Handlers = map[string]bool{} // want Handlers:"set\\(0 writes\\)"
+
+// Seen is written here and reported here.
+var Seen = map[string]bool{} // want Seen:"set\\(1 writes\\)" `map\[string\]bool only stores "true" values`
+
+// Enabled stores false, so it is not a set.
+var Enabled = map[string]bool{}
+
+func init() {
+	Seen["registry"] = true
+	Enabled["registry"] = false
+}
@@ -0,0 +1,9 @@
+package user
+
+import "registry"
+
+func init() {
+	registry.Handlers["user"] = true // want `map\[string\]bool only stores "true" values`
+	registry.Seen["user"] = true
+	registry.Enabled["user"] = true
+}
@@ -0,0 +1,414 @@
+package boolset
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+)
+
+// boolAssign is an assignment to a local bool variable.
+type boolAssign struct {
+	// at is where the assignment takes effect: the end of its statement.
+	at token.Pos
+	// value is the assigned expression, or nil for the zero value or a
+	// value the analysis cannot see, such as one of a call's results.
+	value ast.Expr
+}
+
+func (a *analyzer) trackVarAssignment(lhs ast.Expr, rhs ast.Expr, tok token.Token, at token.Pos) {
+	ident, ok := lhs.(*ast.Ident)
+	if !ok {
+		return
+	}
+	if ident.Name == "_" {
+		return
+	}
+	var obj types.Object
+	if tok == token.DEFINE {
+		obj = a.info.Defs[ident]
+	} else {
+		obj = a.info.Uses[ident]
+	}
+	if obj == nil {
+		obj = a.info.Defs[ident]
+	}
+	if obj == nil {
+		return
+	}
+	if tok != token.ASSIGN && tok != token.DEFINE {
+		// Compound assignments such as &= leave the value unknown.
+		rhs = nil
+	}
+	a.recordVarAssignment(obj, rhs, at)
+}
+
+// handleRange records the unknown values a range loop assigns to bool
+// variables declared outside it.
+func (a *analyzer) handleRange(rng *ast.RangeStmt) {
+	if rng.Tok != token.ASSIGN {
+		return
+	}
+	for _, e := range []ast.Expr{rng.Key, rng.Value} {
+		if e != nil {
+			a.trackVarAssignment(e, nil, rng.Tok, e.End())
+		}
+	}
+}
+
+func (a *analyzer) recordVarAssignment(obj types.Object, rhs ast.Expr, at token.Pos) {
+	v, ok := obj.(*types.Var)
+	if !ok {
+		return
+	}
+	if !isBool(v.Type()) {
+		return
+	}
+	if !a.isLocalVar(v) {
+		return
+	}
+	a.boolAssigns[obj] = append(a.boolAssigns[obj], boolAssign{at: at, value: rhs})
+}
+
+// handleBoolAddress records the local bool variable x, if it is one, as
+// having its address taken.
+func (a *analyzer) handleBoolAddress(x ast.Expr) {
+	id, ok := ast.Unparen(x).(*ast.Ident)
+	if !ok {
+		return
+	}
+	if v, ok := a.info.Uses[id].(*types.Var); ok && isBool(v.Type()) && a.isLocalVar(v) {
+		a.addressedBools[v] = true
+	}
+}
+
+// handleMethodValue records the local bool variable a method with a pointer
+// receiver is selected on, which takes its address, as in flag.Set().
+func (a *analyzer) handleMethodValue(sel *ast.SelectorExpr) {
+	s := a.info.Selections[sel]
+	if s == nil || s.Kind() == types.FieldVal {
+		return
+	}
+	sig, ok := s.Obj().Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return
+	}
+	if _, ptr := sig.Recv().Type().Underlying().(*types.Pointer); ptr {
+		a.handleBoolAddress(sel.X)
+	}
+}
+
+// resolveWrites decides which of the recorded map writes store true, now
+// that every assignment to the variables they store is known. A value read
+// from another tracked map is true if that map only stores true, so maps are
+// first all assumed to, and those with a write that is not true are ruled
+// out until none is left.
+func (a *analyzer) resolveWrites() {
+	for changed := true; changed; {
+		changed = false
+		for _, mi := range a.results {
+			if !mi.onlyTrue {
+				continue
+			}
+			for i := range mi.writes {
+				if !a.writeTrue(mi, i) {
+					mi.onlyTrue = false
+					changed = true
+					break
+				}
+			}
+		}
+	}
+	for _, mi := range a.results {
+		for i := range mi.writes {
+			w := &mi.writes[i]
+			if w.True = a.writeTrue(mi, i); w.True {
+				mi.trueCount++
+			}
+		}
+	}
+}
+
+// writeTrue reports whether the i'th write to mi stores true. In SSA mode,
+// the SSA form decides the writes it has an update for.
+func (a *analyzer) writeTrue(mi *mapInfo, i int) bool {
+	if isTrue, ok := a.ssaTruth[mi.sites[i]]; ok {
+		return isTrue
+	}
+	return a.isDefinitelyTrue(mi.writes[i].Value)
+}
+
+// isDefinitelyTrue reports whether expr is true whenever it is evaluated.
+func (a *analyzer) isDefinitelyTrue(expr ast.Expr) bool {
+	return a.trueAt(expr, make(map[token.Pos]bool))
+}
+
+// trueAt is isDefinitelyTrue, with the positions of the variable reads
+// being decided in visiting: a read met again is on a cycle of assignments
+// and not trusted.
+func (a *analyzer) trueAt(expr ast.Expr, visiting map[token.Pos]bool) bool {
+	if expr == nil {
+		return false
+	}
+	if tv, ok := a.info.Types[expr]; ok && tv.Value != nil {
+		if tv.Value.Kind() == constant.Bool {
+			return constant.BoolVal(tv.Value)
+		}
+	}
+	if t := a.info.TypeOf(expr); t != nil {
+		// maps.Copy and maps.Clone store the values of a whole map.
+		if _, ok := t.Underlying().(*types.Map); ok {
+			return a.storesOnlyTrue(expr)
+		}
+	}
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if obj := a.info.Uses[e]; obj != nil {
+			return a.objectIsDefinitelyTrue(obj, e.Pos(), visiting)
+		}
+		if obj := a.info.Defs[e]; obj != nil {
+			return a.objectIsDefinitelyTrue(obj, e.Pos(), visiting)
+		}
+	case *ast.ParenExpr:
+		return a.trueAt(e.X, visiting)
+	case *ast.IndexExpr:
+		// A lookup in a map that only stores true yields true for the
+		// keys it holds, which a copy such as dst[k] = src[k] carries
+		// over; for other keys it yields false.
+		return a.storesOnlyTrue(e.X) && a.keyHeld(e)
+	}
+	return false
+}
+
+// keyHeld reports whether the key of the lookup e is known to be in the map
+// looked up: the key of a range loop over that map, or a key the lookup is
+// guarded by a membership test of, as in if _, ok := m[k]; ok or if m[k].
+func (a *analyzer) keyHeld(e *ast.IndexExpr) bool {
+	key, ok := ast.Unparen(e.Index).(*ast.Ident)
+	if !ok {
+		return false
+	}
+	k, ok := a.info.Uses[key].(*types.Var)
+	if !ok {
+		return false
+	}
+	m := a.mapObject(e.X)
+	if m == nil {
+		return false
+	}
+	body := a.funcAt(e.Pos())
+	if body == nil || assignedIn(a.info, body, k) {
+		return false
+	}
+	// lookup reports whether x looks up k in m.
+	lookup := func(x ast.Expr) bool {
+		ix, ok := ast.Unparen(x).(*ast.IndexExpr)
+		if !ok || a.mapObject(ix.X) != m {
+			return false
+		}
+		id, ok := ast.Unparen(ix.Index).(*ast.Ident)
+		return ok && a.info.Uses[id] == k
+	}
+	held := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		if held || n == nil || e.Pos() < n.Pos() || e.Pos() >= n.End() {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.RangeStmt:
+			id, ok := n.Key.(*ast.Ident)
+			held = ok && a.info.Defs[id] == k && a.mapObject(n.X) == m && e.Pos() >= n.Body.Pos()
+		case *ast.IfStmt:
+			if e.Pos() < n.Body.Pos() || e.Pos() >= n.Body.End() {
+				break
+			}
+			if lookup(n.Cond) {
+				// m stores only true, so m[k] holds when it is true.
+				held = true
+			} else if asg, ok := n.Init.(*ast.AssignStmt); ok && len(asg.Lhs) == 2 && len(asg.Rhs) == 1 && lookup(asg.Rhs[0]) {
+				okVar, isIdent := asg.Lhs[1].(*ast.Ident)
+				cond, isCond := ast.Unparen(n.Cond).(*ast.Ident)
+				held = isIdent && isCond && okVar.Name != "_" && a.info.ObjectOf(okVar) == a.info.Uses[cond]
+			}
+		}
+		return true
+	})
+	return held
+}
+
+// assignedIn reports whether body assigns to v or takes its address, after
+// which its value no longer names the key it was defined with.
+func assignedIn(info *types.Info, body ast.Node, v *types.Var) bool {
+	assigned := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE {
+				break
+			}
+			for _, lhs := range n.Lhs {
+				if id, ok := ast.Unparen(lhs).(*ast.Ident); ok && info.Uses[id] == v {
+					assigned = true
+				}
+			}
+		case *ast.IncDecStmt:
+			if id, ok := ast.Unparen(n.X).(*ast.Ident); ok && info.Uses[id] == v {
+				assigned = true
+			}
+		case *ast.UnaryExpr:
+			if id, ok := ast.Unparen(n.X).(*ast.Ident); ok && n.Op == token.AND && info.Uses[id] == v {
+				assigned = true
+			}
+		}
+		return !assigned
+	})
+	return assigned
+}
+
+// storesOnlyTrue reports whether x is a tracked map found, so far, to store
+// true and nothing else.
+func (a *analyzer) storesOnlyTrue(x ast.Expr) bool {
+	mi, ok := a.results[a.mapObject(x)]
+	return ok && mi.onlyTrue && (len(mi.writes) > 0 || mi.upstreamWrites > 0)
+}
+
+func (a *analyzer) objectIsDefinitelyTrue(obj types.Object, pos token.Pos, visiting map[token.Pos]bool) bool {
+	switch o := obj.(type) {
+	case *types.Const:
+		if v := o.Val(); v != nil && v.Kind() == constant.Bool {
+			return constant.BoolVal(v)
+		}
+	case *types.Var:
+		if visiting[pos] {
+			return false
+		}
+		visiting[pos] = true
+		defer delete(visiting, pos)
+		return a.varTrueAt(obj, pos, visiting)
+	}
+	return false
+}
+
+// varTrueAt reports whether the local variable obj is true when read at
+// pos: every assignment that reaches pos through the control flow graph of
+// the function body pos is in must store true. Assignments in function
+// literals that body does not enclose may run at any time, so they must all
+// store true, except those of the literals the body defers, which run once
+// it is done reading. When the value reaching pos is the one the body
+// started with, the variable is captured: a deferred literal starts with
+// the values reaching the exits of the function deferring it, and any other
+// with those of every assignment in the enclosing functions.
+func (a *analyzer) varTrueAt(obj types.Object, pos token.Pos, visiting map[token.Pos]bool) bool {
+	fn := a.funcAt(pos)
+	if fn == nil || a.addressedBools[obj] {
+		// Assignments through a pointer, as in p := &flag; *p = false or
+		// mutate(&flag), are not followed.
+		return false
+	}
+	var outer []boolAssign
+	for _, asg := range a.boolAssigns[obj] {
+		switch f := a.funcAt(asg.at - 1); {
+		case f == fn:
+		case encloses(f, fn):
+			outer = append(outer, asg)
+		case a.deferredIn(f) == fn:
+		default:
+			if !a.trueAt(asg.value, visiting) {
+				return false
+			}
+		}
+	}
+	defs, ok := a.reachingDefs(fn, obj, pos)
+	if !ok || len(defs) == 0 {
+		return false
+	}
+	if parent := a.deferredIn(fn); parent != nil {
+		defs = a.entryDefs(defs, parent, obj)
+	}
+	for _, d := range defs {
+		if d.over != nil {
+			// Ranging over a map yields only the values it stores.
+			if !a.storesOnlyTrue(d.over) {
+				return false
+			}
+			continue
+		}
+		if !d.entry {
+			if !a.trueAt(d.value, visiting) {
+				return false
+			}
+			continue
+		}
+		if len(outer) == 0 {
+			return false
+		}
+		for _, asg := range outer {
+			if !a.trueAt(asg.value, visiting) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// deferredIn returns the body of the function deferring the function
+// literal whose body is fn, or nil if fn is not deferred.
+func (a *analyzer) deferredIn(fn *ast.BlockStmt) *ast.BlockStmt {
+	if fn == nil || !a.deferred[fn] {
+		return nil
+	}
+	return a.funcAt(fn.Pos() - 1)
+}
+
+// entryDefs replaces the entry definition in defs, of a literal deferred by
+// the function body parent, with the assignments reaching parent's exits.
+func (a *analyzer) entryDefs(defs []boolDef, parent *ast.BlockStmt, obj types.Object) []boolDef {
+	var out []boolDef
+	for _, d := range defs {
+		if d.entry {
+			out = append(out, a.exitDefs(parent, obj)...)
+		} else {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// funcAt returns the body of the innermost function containing pos, or nil
+// outside function bodies.
+func (a *analyzer) funcAt(pos token.Pos) *ast.BlockStmt {
+	var inner *ast.BlockStmt
+	for _, body := range a.funcs {
+		if pos >= body.Pos() && pos < body.End() && (inner == nil || body.Pos() > inner.Pos()) {
+			inner = body
+		}
+	}
+	return inner
+}
+
+// encloses reports whether the function body outer contains inner.
+func encloses(outer, inner *ast.BlockStmt) bool {
+	return outer != nil && inner != nil && outer != inner && inner.Pos() >= outer.Pos() && inner.End() <= outer.End()
+}
+
+func (a *analyzer) isLocalVar(v *types.Var) bool {
+	if v == nil {
+		return false
+	}
+	if v.IsField() {
+		return false
+	}
+	scope := v.Parent()
+	if scope == nil {
+		return false
+	}
+	pkg := v.Pkg()
+	if pkg != nil && scope == pkg.Scope() {
+		return false
+	}
+	// Parameters live in func scope but initial value unknown; skip them.
+	if v.Pos() == token.NoPos {
+		return false
+	}
+	return true
+}
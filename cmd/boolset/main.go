@@ -0,0 +1,16 @@
+// Command boolset runs the boolset analyzer with the standard go/analysis
+// driver, which provides package loading and the usual -json, -fix, -test
+// and -c flags:
+//
+//	boolset ./...
+//	boolset -fix ./...
+package main
+
+import (
+	"github.com/arturmelanchyk/boolset/boolset"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(boolset.NewAnalyzer())
+}
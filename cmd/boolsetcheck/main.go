@@ -0,0 +1,15 @@
+// Command boolsetcheck runs every boolset analyzer in a single pass with the
+// standard go/analysis multichecker driver. Analyzers are selected with the
+// framework's flags, e.g. -boolset=false disables the boolset analyzer.
+//
+//	boolsetcheck ./...
+package main
+
+import (
+	"github.com/arturmelanchyk/boolset/boolset"
+	"golang.org/x/tools/go/analysis/multichecker"
+)
+
+func main() {
+	multichecker.Main(boolset.Analyzers()...)
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const defaultBaselineFile = "boolset-baseline.json"
+
+// baselineEntry identifies a recorded finding. Line numbers are left out so
+// unrelated edits to a file do not resurface known findings.
+type baselineEntry struct {
+	File    string `json:"file"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type baselineFile struct {
+	Findings []baselineEntry `json:"findings"`
+}
+
+// baseline holds how many times each finding was recorded. It is read-only
+// once loaded and safe to share between packages analysed in parallel.
+type baseline struct {
+	counts map[baselineEntry]int
+}
+
+func loadBaseline(path string) (*baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file baselineFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	b := &baseline{counts: make(map[baselineEntry]int)}
+	for _, entry := range file.Findings {
+		b.counts[entry]++
+	}
+	return b, nil
+}
+
+// filter drops the findings recorded in the baseline. A file keeps reporting
+// a message once it occurs more often than recorded.
+func (b *baseline) filter(findings []finding) []finding {
+	if b == nil {
+		return findings
+	}
+	seen := make(map[baselineEntry]int)
+	var kept []finding
+	for _, f := range findings {
+		entry := newBaselineEntry(f)
+		seen[entry]++
+		if seen[entry] > b.counts[entry] {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// newBaselineEntry records the file relative to the working directory so the
+// baseline can be committed and reused from other checkouts.
+func newBaselineEntry(f finding) baselineEntry {
+	file := f.pos.Filename
+	if abs, err := filepath.Abs(file); err == nil {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil {
+				file = rel
+			}
+		}
+	}
+	return baselineEntry{File: filepath.ToSlash(file), Rule: f.rule, Message: f.message}
+}
+
+func writeBaseline(path string, findings []finding) error {
+	file := baselineFile{Findings: []baselineEntry{}}
+	for _, f := range findings {
+		file.Findings = append(file.Findings, newBaselineEntry(f))
+	}
+	sort.Slice(file.Findings, func(i, j int) bool {
+		a, b := file.Findings[i], file.Findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Message < b.Message
+	})
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func baselineCommand(fs *flag.FlagSet) func(options, []string) int {
+	output := fs.String("o", defaultBaselineFile, "write the baseline to `file`")
+	return func(opts options, args []string) int {
+		targets, err := expandTargets(args, opts)
+		if err != nil {
+			return reportError(err)
+		}
+		findings, err := collectTargets(targets, opts)
+		if err != nil {
+			return reportError(err)
+		}
+		if err := writeBaseline(*output, findings); err != nil {
+			return reportError(err)
+		}
+		if _, err := fmt.Fprintf(os.Stderr, "boolsetlint: recorded %d finding(s) in %s\n", len(findings), *output); err != nil {
+			exit(exitFailure)
+		}
+		return exitOK
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/build"
+	"go/token"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+
+	"github.com/arturmelanchyk/boolset/boolset"
+)
+
+// resultCache stores per-package findings on disk, keyed by everything that
+// can influence them: tool version, build configuration, message locale,
+// source contents and the sources of dependencies, as fingerprinted by
+// writeDeps. A cache with memory enabled also keeps results in-process,
+// which is what keeps the daemon warm.
+type resultCache struct {
+	dir string
+
+	mu     sync.Mutex
+	memory map[string][]finding
+}
+
+// cachedFinding is the on-disk form of a finding.
+type cachedFinding struct {
+	Pos      token.Position
+	End      token.Position
+	Rule     string
+	Severity boolset.Severity
+	Message  string
+	Text     string       `json:",omitempty"`
+	Object   string       `json:",omitempty"`
+	Package  string       `json:",omitempty"`
+	KeyType  string       `json:",omitempty"`
+	ElemType string       `json:",omitempty"`
+	Writes   int          `json:",omitempty"`
+	Reads    int          `json:",omitempty"`
+	Edits    []cachedEdit `json:",omitempty"`
+}
+
+type cachedEdit struct {
+	Pos     token.Position
+	End     token.Position
+	NewText string
+}
+
+// defaultCacheDir returns the cache location used when -cache-dir is not given.
+func defaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "boolsetlint")
+}
+
+// analyzeCached returns the findings for the named files, reusing a previous
+// run's result when none of the inputs changed, and otherwise the type
+// information opts.types keeps warm, if any. The key is computed without
+// running the go command, so exports, which does, is only called on a miss.
+func analyzeCached(opts options, ctx *build.Context, dir string, names []string, exports func() map[string]string, stats *pkgStats) ([]finding, error) {
+	cache, l := opts.cache, opts.linter()
+	if cache == nil || len(names) == 0 {
+		return analyzeFiles(l, dir, names, exports(), stats)
+	}
+	keys, err := cacheKey(ctx, dir, names, opts)
+	if err != nil {
+		return analyzeFiles(l, dir, names, exports(), stats)
+	}
+	if findings, ok := cache.load(keys.result); ok {
+		return findings, nil
+	}
+	missing := len(stats.missing)
+	var findings []finding
+	if opts.types != nil {
+		findings, err = opts.types.analyze(l, ctx, dir, names, keys, exports, stats)
+	} else {
+		findings, err = analyzeFiles(l, dir, names, exports(), stats)
+	}
+	if err != nil || len(stats.missing) > missing {
+		// Partial results are returned but never cached.
+		return findings, err
+	}
+	// The cache is best-effort; a failed write only costs the next run.
+	_ = cache.store(keys.result, findings)
+	return findings, nil
+}
+
+// packageKeys fingerprint the inputs of a group of files: files their
+// contents, deps what they import, and result everything their findings
+// depend on.
+type packageKeys struct {
+	files  string
+	deps   string
+	result string
+}
+
+func cacheKey(ctx *build.Context, dir string, names []string, opts options) (packageKeys, error) {
+	h := sha256.New()
+	var imports []string
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return packageKeys{}, err
+		}
+		fmt.Fprintf(h, "file %s\n", name)
+		h.Write(data)
+		imports = append(imports, fileImports(name, data)...)
+	}
+	var keys packageKeys
+	keys.files = hex.EncodeToString(h.Sum(nil))
+
+	h.Reset()
+	fmt.Fprintf(h, "target %s/%s tags %q\n", ctx.GOOS, ctx.GOARCH, ctx.BuildTags)
+	fmt.Fprintf(h, "goflags %q %q\n", opts.goFlags(), os.Getenv("GOFLAGS"))
+	if err := writeDeps(h, dir, imports, opts); err != nil {
+		return packageKeys{}, err
+	}
+	keys.deps = hex.EncodeToString(h.Sum(nil))
+
+	h.Reset()
+	fmt.Fprintf(h, "tool %s %s\n", toolVersion(), runtime.Version())
+	fmt.Fprintf(h, "locale %s\n", messageLocale)
+	fmt.Fprintf(h, "settings serialized=%t ssa=%t\n", opts.includeSerialized, opts.ssa)
+	fmt.Fprintf(h, "dir %s\n", dir)
+	fmt.Fprintf(h, "files %s\ndeps %s\n", keys.files, keys.deps)
+	keys.result = hex.EncodeToString(h.Sum(nil))
+	return keys, nil
+}
+
+// toolVersion identifies the running binary for cache invalidation.
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version := info.Main.Version
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision", "vcs.modified":
+			version += " " + setting.Value
+		}
+	}
+	if version == "(devel)" || version == "" {
+		// Local builds without VCS stamping change with every edit.
+		if exe, err := os.Executable(); err == nil {
+			if stat, err := os.Stat(exe); err == nil {
+				version += fmt.Sprintf(" %s %d", exe, stat.ModTime().UnixNano())
+			}
+		}
+	}
+	return version
+}
+
+func (c *resultCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key)
+}
+
+func (c *resultCache) load(key string) ([]finding, bool) {
+	if c.memory != nil {
+		c.mu.Lock()
+		findings, ok := c.memory[key]
+		c.mu.Unlock()
+		if ok {
+			return findings, true
+		}
+	}
+	if c.dir == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	var entries []cachedFinding
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, false
+	}
+	findings := make([]finding, 0, len(entries))
+	for _, e := range entries {
+		f := finding{
+			pos:      e.Pos,
+			end:      e.End,
+			rule:     e.Rule,
+			severity: e.Severity,
+			message:  e.Message,
+			text:     e.Text,
+			object:   e.Object,
+			pkg:      e.Package,
+			keyType:  e.KeyType,
+			elemType: e.ElemType,
+			writes:   e.Writes,
+			reads:    e.Reads,
+		}
+		for _, ed := range e.Edits {
+			f.edits = append(f.edits, edit{pos: ed.Pos, end: ed.End, newText: ed.NewText})
+		}
+		findings = append(findings, f)
+	}
+	return findings, true
+}
+
+func (c *resultCache) store(key string, findings []finding) error {
+	if c.memory != nil {
+		c.mu.Lock()
+		c.memory[key] = findings
+		c.mu.Unlock()
+	}
+	if c.dir == "" {
+		return nil
+	}
+	entries := make([]cachedFinding, 0, len(findings))
+	for _, f := range findings {
+		entry := cachedFinding{
+			Pos:      f.pos,
+			End:      f.end,
+			Rule:     f.rule,
+			Severity: f.severity,
+			Message:  f.message,
+			Text:     f.text,
+			Object:   f.object,
+			Package:  f.pkg,
+			KeyType:  f.keyType,
+			ElemType: f.elemType,
+			Writes:   f.writes,
+			Reads:    f.reads,
+		}
+		for _, e := range f.edits {
+			entry.Edits = append(entry.Edits, cachedEdit{Pos: e.pos, End: e.end, NewText: e.newText})
+		}
+		entries = append(entries, entry)
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write through a temporary file so concurrent runs never read a partial entry.
+	tmp, err := os.CreateTemp(filepath.Dir(path), key+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/arturmelanchyk/boolset/boolset"
+)
+
+// Exit codes let CI scripts tell code that needs fixing from a broken run.
+const (
+	exitOK       = 0
+	exitFindings = 1 // issues were reported
+	exitUsage    = 2 // invalid flags or arguments
+	exitFailure  = 3 // packages could not be loaded or analysed
+)
+
+// command is a boolsetlint subcommand.
+type command struct {
+	name    string
+	args    string
+	summary string
+	// analysis is set for commands that load packages; they accept the
+	// shared analysis flags.
+	analysis bool
+	// setup registers the command's own flags and returns the function that
+	// runs it once the flags are parsed.
+	setup func(fs *flag.FlagSet) func(opts options, args []string) int
+}
+
+func commands() []command {
+	return []command{
+		{name: "lint", args: "[packages]", summary: "report map[T]bool sets (default command)", analysis: true, setup: lintCommand},
+		{name: "fix", args: "[packages]", summary: "rewrite provably safe sets to map[T]struct{}", analysis: true, setup: fixCommand},
+		{name: "baseline", args: "[packages]", summary: "record current findings so lint only reports new ones", analysis: true, setup: baselineCommand},
+		{name: "doctor", args: "[packages]", summary: "check the environment and explain empty results", analysis: true, setup: doctorCommand},
+		{name: "explain", args: "[rule]", summary: "describe a rule and how to suppress it", setup: explainCommand},
+		{name: "version", summary: "print the version, VCS revision and Go version", setup: versionCommand},
+		{name: "serve", summary: "run a daemon answering requests on a unix socket", analysis: true, setup: serveCommand},
+		{name: "lsp", summary: "run a language server over stdio", analysis: true, setup: lspCommand},
+		{name: "help", args: "[command]", summary: "show help for a command", setup: helpCommand},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// runCommand runs the subcommand named by args[0], or lint when args does not
+// start with a command name, and returns the process exit code.
+func runCommand(args []string) int {
+	cmd, _ := findCommand("lint")
+	if len(args) > 0 {
+		if named, ok := findCommand(args[0]); ok {
+			cmd = named
+			args = args[1:]
+		}
+	}
+
+	fs := flag.NewFlagSet("boolsetlint "+cmd.name, flag.ContinueOnError)
+	fs.Usage = func() { commandUsage(fs, cmd) }
+	var shared sharedFlags
+	if cmd.analysis {
+		shared.register(fs)
+	}
+	run := cmd.setup(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	var opts options
+	if cmd.analysis {
+		var err error
+		if opts, err = shared.options(); err != nil {
+			return reportError(err)
+		}
+		defer stopProfiles()
+	}
+	return run(opts, fs.Args())
+}
+
+func commandUsage(fs *flag.FlagSet, cmd command) {
+	out := fs.Output()
+	synopsis := "boolsetlint " + cmd.name
+	if cmd.analysis {
+		synopsis += " [flags]"
+	}
+	if cmd.args != "" {
+		synopsis += " " + cmd.args
+	}
+	if _, err := fmt.Fprintf(out, "usage: %s\n\n%s\n", synopsis, cmd.summary); err != nil {
+		exit(exitFailure)
+	}
+	if cmd.name == "lint" {
+		if _, err := fmt.Fprintln(out, "\nCommands:"); err != nil {
+			exit(exitFailure)
+		}
+		for _, c := range commands() {
+			if _, err := fmt.Fprintf(out, "  %-9s %s\n", c.name, c.summary); err != nil {
+				exit(exitFailure)
+			}
+		}
+	}
+	var hasFlags bool
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		if _, err := fmt.Fprintln(out, "\nFlags:"); err != nil {
+			exit(exitFailure)
+		}
+		fs.PrintDefaults()
+	}
+}
+
+// sharedFlags are accepted by every command that analyses packages.
+type sharedFlags struct {
+	opts       options
+	cacheDir   string
+	noCache    bool
+	cpuProfile string
+	memProfile string
+	traceFile  string
+	verbose    bool
+	enable     ruleList
+	disable    ruleList
+}
+
+func (f *sharedFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.opts.tests, "tests", defaultTests(), "include _test.go files (default from $BOOLSETLINT_TESTS)")
+	fs.Var(&f.opts.goos, "goos", "target `GOOS` to analyse; repeatable (default host)")
+	fs.Var(&f.opts.goarch, "goarch", "target `GOARCH` to analyse; repeatable (default host)")
+	fs.BoolVar(&f.opts.recurseModules, "recurse-modules", false, "descend into nested modules when expanding ... patterns")
+	fs.BoolVar(&f.opts.noGitignore, "no-gitignore", false, "do not skip directories excluded by .gitignore when expanding ... patterns")
+	fs.BoolVar(&f.opts.followSymlinks, "follow-symlinks", false, "follow symlinked directories when expanding ... patterns")
+	fs.StringVar(&f.opts.mod, "mod", "", "module download mode passed to the go command (readonly, vendor or mod)")
+	fs.StringVar(&f.opts.modfile, "modfile", "", "alternate go.mod `file` passed to the go command")
+	f.opts.paths = pathRel
+	fs.Var(&f.opts.paths, "path-mode", "how file names are printed: abs, rel (to the working directory) or module")
+	fs.IntVar(&f.opts.parallel, "p", runtime.GOMAXPROCS(0), "number of packages analysed in parallel")
+	fs.StringVar(&f.cacheDir, "cache-dir", defaultCacheDir(), "`directory` for cached per-package results")
+	fs.BoolVar(&f.noCache, "no-cache", false, "always re-analyse packages instead of reusing cached results")
+	fs.StringVar(&f.cpuProfile, "cpuprofile", "", "write a CPU profile to `file`")
+	fs.StringVar(&f.memProfile, "memprofile", "", "write a heap profile to `file` on exit")
+	fs.StringVar(&f.traceFile, "trace", "", "write an execution trace to `file`")
+	fs.BoolVar(&f.verbose, "v", false, "print per-package load, type-check and analysis durations")
+	fs.Var(&f.enable, "enable", "run only these `rules` (IDs or all); repeatable")
+	fs.Var(&f.disable, "disable", "skip these `rules` (IDs or all); repeatable")
+	fs.BoolVar(&f.opts.includeSerialized, "include-serialized", false, "report maps encoded with encoding/json, yaml, xml, gob or toml, whose encoded form the fix changes")
+	fs.BoolVar(&f.opts.ssa, "ssa", false, "decide which stored values are true on the SSA form: more precise, but slower")
+}
+
+// options starts the requested profiles and returns the analysis options.
+func (f *sharedFlags) options() (options, error) {
+	opts := f.opts
+	opts.disabled = disabledRules(f.enable, f.disable)
+	if err := startProfiles(f.cpuProfile, f.memProfile, f.traceFile); err != nil {
+		return opts, err
+	}
+	if f.verbose {
+		opts.timings = &timingLog{w: os.Stderr}
+	}
+	if !f.noCache && f.cacheDir != "" {
+		opts.cache = &resultCache{dir: f.cacheDir}
+	}
+	return opts, nil
+}
+
+// withMemoryCache returns opts with results and type information also kept
+// in memory, for the long-running commands that analyse the same packages
+// repeatedly.
+func withMemoryCache(opts options) options {
+	if opts.cache == nil {
+		opts.cache = &resultCache{}
+	}
+	opts.cache.memory = make(map[string][]finding)
+	opts.types = newTypeCache()
+	return opts
+}
+
+// reportError prints err and returns the exit code for a failed command.
+func reportError(err error) int {
+	if _, err := fmt.Fprintln(os.Stderr, err); err != nil {
+		exit(exitFailure)
+	}
+	return exitFailure
+}
+
+// reportUsage prints err and returns the exit code for invalid arguments.
+func reportUsage(err error) int {
+	reportError(err)
+	return exitUsage
+}
+
+func lintCommand(fs *flag.FlagSet) func(options, []string) int {
+	watchMode := fs.Bool("watch", false, "keep running and re-analyse packages whenever their files change")
+	listFiles := fs.Bool("list-files", false, "print the files that would be analysed and exit")
+	listPackages := fs.Bool("list-packages", false, "print the package directories that would be analysed and exit")
+	baselineFile := fs.String("baseline", "", "do not report findings recorded in the baseline `file`")
+	sarifFile := fs.String("sarif", "", "also write the reported findings to `file` as a SARIF 2.1.0 log")
+	metricsFile := fs.String("metrics-out", "", "write finding counts and run duration to `file` in Prometheus text format")
+	var pr githubPR
+	fs.Var(&pr, "github-pr", "post findings on changed lines of the pull request `owner/repo#number` as review comments (token from $GITHUB_TOKEN)")
+	format := formatFlag("text")
+	fs.Var(&format, "format", "print findings with the named reporter: "+strings.Join(boolset.Reporters(), ", "))
+	showVersion := fs.Bool("version", false, "print the version, VCS revision and Go version and exit")
+	return func(opts options, args []string) int {
+		if *showVersion {
+			return printVersion()
+		}
+		opts.format = string(format)
+		if *baselineFile != "" {
+			b, err := loadBaseline(*baselineFile)
+			if err != nil {
+				return reportError(err)
+			}
+			opts.baseline = b
+		}
+		targets, err := expandTargets(args, opts)
+		if err != nil {
+			return reportError(err)
+		}
+		if *listFiles || *listPackages {
+			if err := list(os.Stdout, targets, opts, *listFiles); err != nil {
+				return reportError(err)
+			}
+			return exitOK
+		}
+
+		var github *githubClient
+		if pr.owner != "" {
+			// Fail before analysing when the review cannot be posted.
+			if github, err = newGitHubClient(); err != nil {
+				return reportUsage(err)
+			}
+		}
+		if *sarifFile != "" || github != nil {
+			opts.reported = &findingLog{}
+		}
+		if *metricsFile != "" {
+			opts.metrics = newMetricsLog()
+		}
+		start := time.Now()
+		// An interrupt stops the run after the packages in progress, so
+		// -sarif and -metrics-out still record the partial results.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		totalIssues, hadError := run(ctx, targets, opts)
+		interrupted := ctx.Err() != nil
+		stop()
+		if *sarifFile != "" {
+			if err := writeSARIF(*sarifFile, opts.reported.sorted()); err != nil {
+				return reportError(err)
+			}
+		}
+		// A partial review would read as if the rest of the change were clean.
+		if github != nil && !interrupted {
+			if _, err := postReview(github, pr, opts.reported.sorted()); err != nil {
+				return reportError(err)
+			}
+		}
+		if opts.metrics != nil {
+			opts.metrics.duration = time.Since(start)
+			if err := opts.metrics.write(*metricsFile); err != nil {
+				return reportError(err)
+			}
+		}
+		if *watchMode && !interrupted {
+			if err := watch(args, targets, opts); err != nil {
+				return reportError(err)
+			}
+			return exitOK
+		}
+		if hadError {
+			return exitFailure
+		}
+		if totalIssues > 0 {
+			return exitFindings
+		}
+		return exitOK
+	}
+}
+
+// formatFlag names a boolset reporter for -format.
+type formatFlag string
+
+func (f *formatFlag) String() string { return string(*f) }
+
+func (f *formatFlag) Set(value string) error {
+	if _, ok := boolset.NewReporter(value, io.Discard); !ok {
+		return fmt.Errorf("unknown format %q; formats: %s", value, strings.Join(boolset.Reporters(), ", "))
+	}
+	*f = formatFlag(value)
+	return nil
+}
+
+func serveCommand(fs *flag.FlagSet) func(options, []string) int {
+	socket := fs.String("socket", defaultSocket(), "unix `socket` to listen on")
+	httpAddr := fs.String("http", "", "also answer requests over HTTP on the loopback `addr` (e.g. localhost:7878)")
+	return func(opts options, _ []string) int {
+		// The daemon runs until interrupted, then returns so profiles are
+		// flushed.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		if err := serve(ctx, *socket, *httpAddr, withMemoryCache(opts)); err != nil {
+			return reportError(err)
+		}
+		return exitOK
+	}
+}
+
+func lspCommand(*flag.FlagSet) func(options, []string) int {
+	return func(opts options, _ []string) int {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		// Reading stdin cannot be interrupted; an interrupt returns
+		// without waiting for it, as an exit without shutdown would.
+		done := make(chan int, 1)
+		go func() { done <- newLSPServer(os.Stdin, os.Stdout, withMemoryCache(opts)).run() }()
+		select {
+		case code := <-done:
+			return code
+		case <-ctx.Done():
+			return 1
+		}
+	}
+}
+
+func helpCommand(fs *flag.FlagSet) func(options, []string) int {
+	return func(_ options, args []string) int {
+		name := "lint"
+		if len(args) > 0 {
+			name = args[0]
+		}
+		cmd, ok := findCommand(name)
+		if !ok {
+			return reportUsage(fmt.Errorf("unknown command %q; commands: %s", name, commandNames()))
+		}
+		cmdFlags := flag.NewFlagSet("boolsetlint "+cmd.name, flag.ContinueOnError)
+		cmdFlags.SetOutput(fs.Output())
+		if cmd.analysis {
+			new(sharedFlags).register(cmdFlags)
+		}
+		cmd.setup(cmdFlags)
+		commandUsage(cmdFlags, cmd)
+		return exitOK
+	}
+}
+
+func commandNames() string {
+	var names []string
+	for _, cmd := range commands() {
+		names = append(names, cmd.name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// errUncacheable reports packages whose dependencies cannot be fingerprinted,
+// so their results must not be cached.
+var errUncacheable = errors.New("dependencies cannot be fingerprinted")
+
+// localModule is a module whose sources are edited in place: the main
+// module, workspace modules and directory replacements.
+type localModule struct {
+	path string
+	dir  string
+}
+
+// writeDeps hashes into h what a package importing imports can depend on
+// besides its own files, without running the go command: the go.mod that
+// pins the version of every other module, and the sources of the packages
+// imported, directly or not, from local modules or the vendor directory.
+// Standard library packages change only with the toolchain, which the cache
+// key covers separately.
+func writeDeps(h io.Writer, dir string, imports []string, opts options) error {
+	root := moduleRoot(dir)
+	if root == "" {
+		// Outside a module only the standard library can be resolved.
+		for _, path := range imports {
+			if !isStandard(path) {
+				return errUncacheable
+			}
+		}
+		return nil
+	}
+	gomod := filepath.Join(root, "go.mod")
+	if opts.modfile != "" {
+		// The go command runs in dir, resolving -modfile against it.
+		gomod = opts.modfile
+		if !filepath.IsAbs(gomod) {
+			gomod = filepath.Join(dir, gomod)
+		}
+	}
+	data, err := os.ReadFile(gomod)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(h, "go.mod %s\n", gomod)
+	h.Write(data)
+	// Vendored sources can be edited in place, so they are hashed like
+	// local ones, along with vendor/modules.txt.
+	vendor := ""
+	if data, err := os.ReadFile(filepath.Join(root, "vendor", "modules.txt")); err == nil {
+		fmt.Fprintf(h, "vendor\n")
+		h.Write(data)
+		vendor = filepath.Join(root, "vendor")
+	}
+	modules, err := localModules(h, root, data)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	queue := append([]string(nil), imports...)
+	for len(queue) > 0 {
+		path := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		pkgDir, ok := localDir(modules, path)
+		if !ok && vendor != "" && !isStandard(path) {
+			pkgDir, ok = filepath.Join(vendor, filepath.FromSlash(path)), true
+		}
+		if !ok {
+			// The go.mod above pins other modules' versions.
+			continue
+		}
+		deps, err := writeSources(h, path, pkgDir)
+		if err != nil {
+			return err
+		}
+		queue = append(queue, deps...)
+	}
+	return nil
+}
+
+// localModules returns the main module, the directory replacements of its
+// go.mod, data, and the modules of the workspace it belongs to, hashing the
+// go.work file into h.
+func localModules(h io.Writer, root string, data []byte) ([]localModule, error) {
+	file, err := modfile.ParseLax("go.mod", data, nil)
+	if err != nil {
+		return nil, err
+	}
+	var modules []localModule
+	if file.Module != nil {
+		modules = append(modules, localModule{file.Module.Mod.Path, root})
+	}
+	modules = appendReplaces(modules, root, file.Replace)
+
+	work := workFile(root)
+	if work == "" {
+		return modules, nil
+	}
+	data, err = os.ReadFile(work)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(h, "go.work %s\n", work)
+	h.Write(data)
+	wf, err := modfile.ParseWork(work, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	workDir := filepath.Dir(work)
+	for _, use := range wf.Use {
+		dir := use.Path
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workDir, dir)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			return nil, err
+		}
+		if path := modfile.ModulePath(data); path != "" {
+			modules = append(modules, localModule{path, dir})
+		}
+	}
+	return appendReplaces(modules, workDir, wf.Replace), nil
+}
+
+// appendReplaces appends the replacements by directories, relative to dir,
+// to modules.
+func appendReplaces(modules []localModule, dir string, replaces []*modfile.Replace) []localModule {
+	for _, r := range replaces {
+		if r.New.Version != "" {
+			continue
+		}
+		target := r.New.Path
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		modules = append(modules, localModule{r.Old.Path, target})
+	}
+	return modules
+}
+
+// workFile returns the go.work file the go command uses for the module at
+// root, or "" when it runs in single-module mode.
+func workFile(root string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+	default:
+		return gowork
+	}
+	for dir := root; ; {
+		path := filepath.Join(dir, "go.work")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// localDir returns the directory of the package at path when it belongs to
+// one of modules, preferring the longest module path.
+func localDir(modules []localModule, path string) (string, bool) {
+	best := -1
+	for i, m := range modules {
+		if path != m.path && !strings.HasPrefix(path, m.path+"/") {
+			continue
+		}
+		if best < 0 || len(m.path) > len(modules[best].path) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return "", false
+	}
+	m := modules[best]
+	return filepath.Join(m.dir, filepath.FromSlash(strings.TrimPrefix(path[len(m.path):], "/"))), true
+}
+
+// writeSources hashes the non-test Go files of the package at path, in dir,
+// into h and returns their imports. Files excluded by build constraints are
+// included, which only costs a cache miss when they change.
+func writeSources(h io.Writer, path, dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		// The import does not resolve; type-checking reports it.
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var imports []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(h, "dep %s %s\n", path, name)
+		h.Write(data)
+		imports = append(imports, fileImports(name, data)...)
+	}
+	return imports, nil
+}
+
+// fileImports returns the import paths of the Go source in data. Files that
+// do not parse import nothing; type-checking reports them.
+func fileImports(name string, data []byte) []string {
+	file, err := parser.ParseFile(token.NewFileSet(), name, data, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+	imports := make([]string, 0, len(file.Imports))
+	for _, spec := range file.Imports {
+		if path, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imports = append(imports, path)
+		}
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// isStandard reports whether path names a standard library package, whose
+// first element, unlike a module path's, has no dot.
+func isStandard(path string) bool {
+	elem, _, _ := strings.Cut(path, "/")
+	return !strings.Contains(elem, ".")
+}
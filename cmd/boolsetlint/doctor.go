@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/build"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// doctor checks the environment analysis depends on and explains why a run
+// might silently report nothing.
+type doctor struct {
+	w      io.Writer
+	opts   options
+	failed bool
+}
+
+func doctorCommand(*flag.FlagSet) func(options, []string) int {
+	return func(opts options, args []string) int {
+		d := &doctor{w: os.Stdout, opts: opts}
+		d.run(args)
+		if d.failed {
+			return exitFailure
+		}
+		return exitOK
+	}
+}
+
+func (d *doctor) run(args []string) {
+	if !d.checkToolchain() {
+		d.report("fail", "remaining checks need the go command")
+		return
+	}
+	d.checkModule()
+	d.checkExportData()
+	d.checkCache()
+	d.checkPackages(args)
+}
+
+func (d *doctor) report(status, format string, args ...any) {
+	if status == "fail" {
+		d.failed = true
+	}
+	if _, err := fmt.Fprintf(d.w, "%-4s  %s\n", status, fmt.Sprintf(format, args...)); err != nil {
+		exit(exitFailure)
+	}
+}
+
+func (d *doctor) checkToolchain() bool {
+	path, err := exec.LookPath("go")
+	if err != nil {
+		d.report("fail", "go toolchain: %v; dependencies cannot be resolved through the go command", err)
+		return false
+	}
+	version, err := goCommand("", "env", "GOVERSION")
+	if err != nil {
+		d.report("fail", "go toolchain: %s: %v", path, err)
+		return false
+	}
+	d.report("ok", "go toolchain: %s (%s)", version, path)
+	return true
+}
+
+func (d *doctor) checkModule() {
+	wd, err := os.Getwd()
+	if err != nil {
+		d.report("fail", "module: %v", err)
+		return
+	}
+	root := moduleRoot(wd)
+	if root == "" {
+		d.report("warn", "module: %s is not inside a module; imports are resolved without go.mod", wd)
+		return
+	}
+	args := append([]string{"list", "-m"}, d.opts.goFlags()...)
+	modPath, err := goCommand(root, args...)
+	if err != nil {
+		d.report("fail", "module: %s: %v", root, err)
+		return
+	}
+	d.report("ok", "module: %s (%s)", modPath, root)
+}
+
+// checkExportData verifies that the go command reports export data and that
+// the compiler importer can read it, using the standard library.
+func (d *doctor) checkExportData() {
+	file, err := goCommand("", "list", "-export", "-f", "{{.Export}}", "fmt")
+	if err == nil && file == "" {
+		err = errors.New("no export data reported")
+	}
+	if err == nil {
+		_, err = newImporter(token.NewFileSet(), map[string]string{"fmt": file}).Import("fmt")
+	}
+	if err != nil {
+		d.report("warn", "export data: %v; dependencies will be type-checked from source, which is slower", err)
+		return
+	}
+	d.report("ok", "export data: compiler importer reads %s", file)
+}
+
+func (d *doctor) checkCache() {
+	if d.opts.cache == nil || d.opts.cache.dir == "" {
+		d.report("ok", "cache: disabled")
+		return
+	}
+	dir := d.opts.cache.dir
+	err := os.MkdirAll(dir, 0755)
+	if err == nil {
+		var f *os.File
+		if f, err = os.CreateTemp(dir, "doctor*"); err == nil {
+			f.Close()
+			err = os.Remove(f.Name())
+		}
+	}
+	if err != nil {
+		d.report("warn", "cache: %s is not writable: %v; every run re-analyses all packages", dir, err)
+		return
+	}
+	d.report("ok", "cache: %s", dir)
+}
+
+// checkPackages reports what the patterns select and, for directories that
+// contribute no files, why.
+func (d *doctor) checkPackages(args []string) {
+	targets, err := expandTargets(args, d.opts)
+	if err != nil {
+		d.report("fail", "packages: %v", err)
+		return
+	}
+	packages, files := 0, 0
+	for _, target := range targets {
+		dir := filepath.FromSlash(packagePath(target))
+		hasGo, err := hasGoFiles(dir)
+		if err != nil {
+			d.report("fail", "packages: %v", err)
+			continue
+		}
+		if !hasGo {
+			// Intermediate directories matched by ... patterns.
+			continue
+		}
+		selected := 0
+		for _, ctx := range d.opts.buildContexts() {
+			groups, err := packageFiles(&ctx, dir, d.opts)
+			if err != nil {
+				d.report("fail", "packages: %s: %v", dir, err)
+				selected = -1
+				break
+			}
+			for _, group := range groups {
+				selected += len(group)
+			}
+		}
+		if selected < 0 {
+			continue
+		}
+		if selected == 0 {
+			d.report("warn", "packages: %s: %s", dir, d.whyEmpty(dir))
+			continue
+		}
+		packages++
+		files += selected
+	}
+	if packages == 0 {
+		d.report("warn", "packages: nothing to analyse; no findings will be reported")
+		return
+	}
+	d.report("ok", "packages: %d package(s), %d file(s) selected", packages, files)
+}
+
+// whyEmpty explains why dir contributes no files under the current options.
+func (d *doctor) whyEmpty(dir string) string {
+	ctx := build.Default
+	ctx.Dir = moduleRoot(dir)
+	pkg, _ := ctx.ImportDir(dir, 0)
+	switch {
+	case pkg == nil:
+		return "no files selected"
+	case !d.opts.tests && len(pkg.GoFiles) == 0 && len(pkg.TestGoFiles)+len(pkg.XTestGoFiles) > 0:
+		return "only test files; pass -tests to analyse them"
+	case len(pkg.IgnoredGoFiles) > 0:
+		return fmt.Sprintf("all files excluded by build constraints (%s); try -goos/-goarch", strings.Join(pkg.IgnoredGoFiles, ", "))
+	}
+	return "no files selected"
+}
+
+// goCommand runs the go command in dir and returns its trimmed output.
+func goCommand(dir string, args ...string) (string, error) {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+func expandTargets(args []string, opts options) ([]string, error) {
+	if len(args) == 0 {
+		args = []string{"."}
+	}
+
+	seen := make(map[string]struct{})
+	var targets []string
+	for _, arg := range args {
+		expanded, err := expandArg(arg, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, target := range expanded {
+			clean := filepath.Clean(target)
+			if _, ok := seen[clean]; ok {
+				continue
+			}
+			seen[clean] = struct{}{}
+			targets = append(targets, clean)
+		}
+	}
+	return targets, nil
+}
+
+func expandArg(arg string, opts options) ([]string, error) {
+	if strings.Contains(arg, "...") {
+		dirs, err := expandEllipsis(arg, opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(dirs) == 0 {
+			return nil, fmt.Errorf("pattern %q matched no directories", arg)
+		}
+		return dirs, nil
+	}
+	return []string{arg}, nil
+}
+
+func expandEllipsis(pattern string, opts options) ([]string, error) {
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	root := walkRoot(pattern)
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+
+	w := &dirWalker{opts: opts, root: root}
+	if !opts.noGitignore {
+		w.ignore = newGitignore(root)
+	}
+	if opts.followSymlinks {
+		w.visited = make(map[string]struct{})
+	}
+
+	var dirs []string
+	w.visit = func(path string) {
+		candidate := normalizeForMatch(path)
+		if re.MatchString(candidate) || (candidate != "." && re.MatchString(candidate+"/")) {
+			dirs = append(dirs, path)
+		}
+	}
+	if err := w.walk(root); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// dirWalker walks the directory tree below root for ellipsis expansion.
+type dirWalker struct {
+	opts   options
+	root   string
+	ignore *gitignore
+	// visited holds the resolved paths of walked directories when symlinks
+	// are followed, so cycles terminate and shared targets are seen once.
+	visited map[string]struct{}
+	visit   func(path string)
+}
+
+func (w *dirWalker) walk(path string) error {
+	if w.visited != nil {
+		real, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return err
+		}
+		if real, err = filepath.Abs(real); err != nil {
+			return err
+		}
+		if _, ok := w.visited[real]; ok {
+			return nil
+		}
+		w.visited[real] = struct{}{}
+	}
+	if path != w.root {
+		if w.ignore != nil && (filepath.Base(path) == ".git" || w.ignore.ignored(path, true)) {
+			return nil
+		}
+		// Like the go command, "..." stops at nested modules unless asked otherwise.
+		if !w.opts.recurseModules && isModuleRoot(path) {
+			return nil
+		}
+	}
+	if w.ignore != nil {
+		w.ignore.load(path)
+	}
+	w.visit(path)
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		child := filepath.Join(path, entry.Name())
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 && w.opts.followSymlinks {
+			// Dangling links are skipped rather than failing the walk.
+			info, err := os.Stat(child)
+			isDir = err == nil && info.IsDir()
+		}
+		if !isDir {
+			continue
+		}
+		if err := w.walk(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func walkRoot(pattern string) string {
+	idx := strings.Index(pattern, "...")
+	root := pattern
+	if idx != -1 {
+		root = pattern[:idx]
+	}
+	root = filepath.Clean(filepath.FromSlash(root))
+	if root == "" {
+		return "."
+	}
+	return root
+}
+
+// windowsPaths selects Windows path semantics (backslashes, volume names and
+// case-insensitive matching) for pattern expansion.
+var windowsPaths = runtime.GOOS == "windows"
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return compilePatternOS(pattern, windowsPaths)
+}
+
+func compilePatternOS(pattern string, windows bool) (*regexp.Regexp, error) {
+	norm := normalizePath(pattern, windows)
+	var sb strings.Builder
+	if windows {
+		sb.WriteString("(?i)")
+	}
+	sb.WriteString("^")
+	for i := 0; i < len(norm); {
+		if strings.HasPrefix(norm[i:], "...") {
+			sb.WriteString(".*")
+			i += 3
+			continue
+		}
+		switch norm[i] {
+		case '*':
+			sb.WriteString("[^/]*")
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(norm[i : i+1]))
+		}
+		i++
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+func normalizeForMatch(path string) string {
+	return normalizePath(path, windowsPaths)
+}
+
+// normalizePath converts path to the slash-separated form used for matching.
+// With windows set, backslashes are separators and a leading drive letter
+// ("C:") or UNC share ("//server/share") is kept as the path's volume.
+func normalizePath(path string, windows bool) string {
+	if path == "" {
+		return "."
+	}
+	var vol string
+	if windows {
+		path = strings.ReplaceAll(path, `\`, "/")
+		vol = volumeName(path)
+		path = path[len(vol):]
+	} else {
+		path = filepath.ToSlash(path)
+	}
+	if strings.HasPrefix(path, "/") || strings.HasPrefix(vol, "//") {
+		if strings.HasSuffix(path, "/") && path != "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		return vol + path
+	}
+	// Relative, or drive-relative such as "C:pkg/...".
+	for strings.HasPrefix(path, "./") {
+		path = path[2:]
+	}
+	if path == "" {
+		path = "."
+	}
+	if strings.HasSuffix(path, "/") {
+		path = strings.TrimSuffix(path, "/")
+	}
+	if vol != "" && path == "." {
+		return vol
+	}
+	return vol + path
+}
+
+// volumeName returns the drive letter or UNC share prefix of a
+// slash-separated Windows path.
+func volumeName(path string) string {
+	if len(path) >= 2 && path[1] == ':' && isLetter(path[0]) {
+		return path[:2]
+	}
+	if !strings.HasPrefix(path, "//") || strings.HasPrefix(path, "///") {
+		return ""
+	}
+	// "//server/share" including the share component.
+	rest := path[2:]
+	server := strings.IndexByte(rest, '/')
+	if server <= 0 {
+		return ""
+	}
+	share := strings.IndexByte(rest[server+1:], '/')
+	if share == -1 {
+		return path
+	}
+	if share == 0 {
+		return ""
+	}
+	return path[:2+server+1+share]
+}
+
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isModuleRoot(dir string) bool {
+	info, err := os.Stat(filepath.Join(dir, "go.mod"))
+	return err == nil && !info.IsDir()
+}
+
+// moduleRoot returns the closest directory at or above dir containing a go.mod,
+// or the empty string if dir is not inside a module.
+func moduleRoot(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if isModuleRoot(abs) {
+			return abs
+		}
+		parent := filepath.Dir(abs)
+		if parent == abs {
+			return ""
+		}
+		abs = parent
+	}
+}
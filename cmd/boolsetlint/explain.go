@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func explainCommand(*flag.FlagSet) func(options, []string) int {
+	return func(_ options, args []string) int {
+		if len(args) == 0 {
+			for _, r := range rules {
+				if _, err := fmt.Fprintf(os.Stdout, "%s  %s\n", r.id, r.title); err != nil {
+					exit(exitFailure)
+				}
+			}
+			return exitOK
+		}
+		for i, id := range args {
+			r, ok := findRule(id)
+			if !ok {
+				return reportUsage(fmt.Errorf("unknown rule %q; run \"boolsetlint explain\" to list rules", id))
+			}
+			if i > 0 {
+				if _, err := fmt.Fprintln(os.Stdout); err != nil {
+					exit(exitFailure)
+				}
+			}
+			if _, err := fmt.Fprintf(os.Stdout, "%s: %s\n\n%s", r.id, r.title, r.doc); err != nil {
+				exit(exitFailure)
+			}
+		}
+		return exitOK
+	}
+}
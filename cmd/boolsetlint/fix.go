@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+func fixCommand(*flag.FlagSet) func(options, []string) int {
+	return func(opts options, args []string) int {
+		targets, err := expandTargets(args, opts)
+		if err != nil {
+			return reportError(err)
+		}
+		findings, collectErr := collectTargets(targets, opts)
+		remaining, fixed, err := applyFixes(findings)
+		for _, f := range remaining {
+			opts.writeFinding(os.Stderr, f)
+		}
+		if fixed > 0 {
+			if _, err := fmt.Fprintf(os.Stderr, "boolsetlint fixed %d issue(s)\n", fixed); err != nil {
+				exit(exitFailure)
+			}
+		}
+		if len(remaining) > 0 {
+			if _, err := fmt.Fprintf(os.Stderr, "boolsetlint found %d issue(s)\n", len(remaining)); err != nil {
+				exit(exitFailure)
+			}
+		}
+		if err := errors.Join(collectErr, err); err != nil {
+			return reportError(err)
+		}
+		if len(remaining) > 0 {
+			return exitFindings
+		}
+		return exitOK
+	}
+}
+
+// collectTargets returns the findings of every target in output order.
+func collectTargets(targets []string, opts options) ([]finding, error) {
+	targets = append([]string(nil), targets...)
+	sort.SliceStable(targets, func(i, j int) bool {
+		return packagePath(targets[i]) < packagePath(targets[j])
+	})
+	var findings []finding
+	var errs []error
+	for _, target := range targets {
+		found, err := collectPath(target, opts)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		findings = append(findings, found...)
+	}
+	return findings, errors.Join(errs...)
+}
+
+// applyFixes rewrites the files touched by the findings' edits and returns
+// the findings that could not be fixed. A finding is fixed as a whole or not
+// at all; edits overlapping an earlier finding's leave it unfixed.
+func applyFixes(findings []finding) (remaining []finding, fixed int, err error) {
+	type span struct{ start, end int }
+	accepted := make(map[string][]span)
+	byFile := make(map[string][]edit)
+	overlaps := func(e edit) bool {
+		for _, s := range accepted[e.pos.Filename] {
+			if e.pos.Offset < s.end && s.start < e.end.Offset || e.pos.Offset == s.start {
+				return true
+			}
+		}
+		return false
+	}
+	for _, f := range findings {
+		ok := len(f.edits) > 0
+		for _, e := range f.edits {
+			if overlaps(e) {
+				ok = false
+				break
+			}
+		}
+		if !ok {
+			remaining = append(remaining, f)
+			continue
+		}
+		for _, e := range f.edits {
+			accepted[e.pos.Filename] = append(accepted[e.pos.Filename], span{e.pos.Offset, e.end.Offset})
+			byFile[e.pos.Filename] = append(byFile[e.pos.Filename], e)
+		}
+		fixed++
+	}
+
+	files := make([]string, 0, len(byFile))
+	for file := range byFile {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	var errs []error
+	for _, file := range files {
+		if err := applyEdits(file, byFile[file]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return remaining, fixed, errors.Join(errs...)
+}
+
+// applyEdits applies non-overlapping edits to file in place.
+func applyEdits(file string, edits []edit) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if src, err = rewrite(file, src, edits); err != nil {
+		return err
+	}
+	return os.WriteFile(file, src, info.Mode().Perm())
+}
+
+// rewrite returns src, the contents of file, with non-overlapping edits
+// applied.
+func rewrite(file string, src []byte, edits []edit) ([]byte, error) {
+	edits = append([]edit(nil), edits...)
+	sort.Slice(edits, func(i, j int) bool { return edits[i].pos.Offset > edits[j].pos.Offset })
+	src = append([]byte(nil), src...)
+	for _, e := range edits {
+		if e.pos.Offset < 0 || e.end.Offset > len(src) || e.pos.Offset > e.end.Offset {
+			return nil, fmt.Errorf("%s: edit out of range; file changed during analysis", file)
+		}
+		src = append(src[:e.pos.Offset], append([]byte(e.newText), src[e.end.Offset:]...)...)
+	}
+	return src, nil
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// githubPR identifies a pull request for -github-pr, written owner/repo#123.
+type githubPR struct {
+	owner  string
+	repo   string
+	number int
+}
+
+var githubPRPattern = regexp.MustCompile(`^([\w.-]+)/([\w.-]+)#([0-9]+)$`)
+
+func (p *githubPR) String() string {
+	if p.owner == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s#%d", p.owner, p.repo, p.number)
+}
+
+func (p *githubPR) Set(value string) error {
+	m := githubPRPattern.FindStringSubmatch(value)
+	if m == nil {
+		return fmt.Errorf("must be owner/repo#number")
+	}
+	number, err := strconv.Atoi(m[3])
+	if err != nil {
+		return err
+	}
+	*p = githubPR{owner: m[1], repo: m[2], number: number}
+	return nil
+}
+
+// githubClient calls the GitHub REST API. base defaults to api.github.com;
+// GitHub Actions sets GITHUB_API_URL for GitHub Enterprise Server.
+type githubClient struct {
+	base  string
+	token string
+}
+
+func newGitHubClient() (*githubClient, error) {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		return nil, errors.New("-github-pr: GITHUB_TOKEN is not set")
+	}
+	base := os.Getenv("GITHUB_API_URL")
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	return &githubClient{base: strings.TrimSuffix(base, "/"), token: token}, nil
+}
+
+func (c *githubClient) do(method, path string, body, out any) error {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.base+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("github: %s %s: %s: %s", method, path, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// getPages fetches every page of a list endpoint, appending the items
+// decoded from each page to *out.
+func getPages[T any](c *githubClient, path string, out *[]T) error {
+	const perPage = 100
+	for page := 1; ; page++ {
+		var items []T
+		if err := c.do(http.MethodGet, fmt.Sprintf("%s?per_page=%d&page=%d", path, perPage, page), nil, &items); err != nil {
+			return err
+		}
+		*out = append(*out, items...)
+		if len(items) < perPage {
+			return nil
+		}
+	}
+}
+
+type githubFile struct {
+	Filename string `json:"filename"`
+	Patch    string `json:"patch"`
+}
+
+type githubComment struct {
+	Path      string `json:"path"`
+	Line      int    `json:"line"`
+	StartLine int    `json:"start_line,omitempty"`
+	Side      string `json:"side"`
+	StartSide string `json:"start_side,omitempty"`
+	Body      string `json:"body"`
+}
+
+type githubReview struct {
+	Event    string          `json:"event"`
+	Body     string          `json:"body"`
+	Comments []githubComment `json:"comments"`
+}
+
+// fileDiff describes the new side of a file's patch: the lines the pull
+// request adds and the line ranges of its hunks.
+type fileDiff struct {
+	added map[int]bool
+	hunks [][2]int
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -[0-9,]+ \+([0-9]+)(?:,([0-9]+))? @@`)
+
+func parsePatch(patch string) fileDiff {
+	d := fileDiff{added: make(map[int]bool)}
+	line := 0
+	for _, text := range strings.Split(patch, "\n") {
+		if m := hunkHeader.FindStringSubmatch(text); m != nil {
+			start, _ := strconv.Atoi(m[1])
+			count := 1
+			if m[2] != "" {
+				count, _ = strconv.Atoi(m[2])
+			}
+			d.hunks = append(d.hunks, [2]int{start, start + count - 1})
+			line = start
+			continue
+		}
+		switch {
+		case strings.HasPrefix(text, "+"):
+			d.added[line] = true
+			line++
+		case strings.HasPrefix(text, "-"), strings.HasPrefix(text, `\`):
+			// Removed lines and "\ No newline at end of file" do not
+			// exist on the new side.
+		default:
+			line++
+		}
+	}
+	return d
+}
+
+// inOneHunk reports whether every line from first to last lies in a single
+// hunk, which GitHub requires of a multi-line comment.
+func (d fileDiff) inOneHunk(first, last int) bool {
+	for _, h := range d.hunks {
+		if first >= h[0] && last <= h[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// postReview comments on the findings that fall on lines added by the pull
+// request, in one review. Findings already commented on by an earlier run are
+// skipped. It returns the number of comments posted.
+func postReview(client *githubClient, pr githubPR, findings []finding) (int, error) {
+	prPath := fmt.Sprintf("/repos/%s/%s/pulls/%d", pr.owner, pr.repo, pr.number)
+	var files []githubFile
+	if err := getPages(client, prPath+"/files", &files); err != nil {
+		return 0, err
+	}
+	diffs := make(map[string]fileDiff, len(files))
+	for _, f := range files {
+		diffs[f.Filename] = parsePatch(f.Patch)
+	}
+	var existing []githubComment
+	if err := getPages(client, prPath+"/comments", &existing); err != nil {
+		return 0, err
+	}
+	posted := make(map[githubComment]bool, len(existing))
+	for _, c := range existing {
+		posted[githubComment{Path: c.Path, Line: c.Line, Body: c.Body}] = true
+	}
+
+	var comments []githubComment
+	for _, f := range findings {
+		path, ok := repoPath(f.pos.Filename)
+		if !ok {
+			continue
+		}
+		diff, ok := diffs[path]
+		if !ok || !diff.added[f.pos.Line] {
+			continue
+		}
+		c := reviewComment(path, f, diff)
+		if posted[githubComment{Path: c.Path, Line: c.Line, Body: c.Body}] {
+			continue
+		}
+		comments = append(comments, c)
+	}
+	if len(comments) == 0 {
+		return 0, nil
+	}
+	review := githubReview{
+		Event:    "COMMENT",
+		Body:     fmt.Sprintf("boolsetlint found %d issue(s) on lines changed by this pull request.", len(comments)),
+		Comments: comments,
+	}
+	if err := client.do(http.MethodPost, prPath+"/reviews", review, nil); err != nil {
+		return 0, err
+	}
+	return len(comments), nil
+}
+
+// reviewComment describes f, attaching its fix as a suggestion when every
+// edit lies within one hunk of the diff.
+func reviewComment(path string, f finding, diff fileDiff) githubComment {
+	c := githubComment{Path: path, Line: f.pos.Line, Side: "RIGHT", Body: fmt.Sprintf("%s (%s)", f.display(), f.rule)}
+	if len(f.edits) == 0 {
+		return c
+	}
+	first, last := f.pos.Line, f.pos.Line
+	for _, e := range f.edits {
+		first = min(first, e.pos.Line)
+		last = max(last, e.end.Line)
+	}
+	if !diff.inOneHunk(first, last) {
+		return c
+	}
+	src, err := os.ReadFile(f.pos.Filename)
+	if err != nil {
+		return c
+	}
+	fixed, err := rewrite(f.pos.Filename, src, f.edits)
+	if err != nil {
+		return c
+	}
+	oldLines := strings.Split(string(src), "\n")
+	newLines := strings.Split(string(fixed), "\n")
+	// The rewrite replaces tokens within lines; anything else cannot be
+	// expressed as a replacement of the same line range.
+	if len(oldLines) != len(newLines) || last > len(newLines) {
+		return c
+	}
+	c.Body += "\n\n```suggestion\n" + strings.Join(newLines[first-1:last], "\n") + "\n```"
+	if first < last {
+		c.StartLine, c.StartSide, c.Line = first, "RIGHT", last
+	}
+	return c
+}
+
+// repoPath returns filename relative to the root of its git work tree, in
+// the slash-separated form GitHub uses.
+func repoPath(filename string) (string, bool) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", false
+	}
+	for dir := filepath.Dir(abs); ; {
+		if isGitRoot(dir) {
+			rel, err := filepath.Rel(dir, abs)
+			if err != nil {
+				return "", false
+			}
+			return filepath.ToSlash(rel), true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
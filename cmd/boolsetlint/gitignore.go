@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ignoreRule is a single compiled .gitignore pattern.
+type ignoreRule struct {
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+	// anchored patterns are matched against the path relative to base rather
+	// than against the final path element only.
+	anchored bool
+}
+
+// gitignore accumulates the rules of every .gitignore file seen so far.
+type gitignore struct {
+	rules []ignoreRule
+}
+
+// newGitignore loads the .gitignore files of root's ancestors, stopping at the
+// enclosing git work tree, so patterns like "./sub/..." still honour rules
+// declared at the repository top level.
+func newGitignore(root string) *gitignore {
+	g := &gitignore{}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return g
+	}
+	var ancestors []string
+	for dir := abs; !isGitRoot(dir); {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			// Not inside a work tree; only rules below root apply.
+			ancestors = nil
+			break
+		}
+		dir = parent
+		ancestors = append(ancestors, dir)
+	}
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		g.load(ancestors[i])
+	}
+	return g
+}
+
+func isGitRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// load appends the rules of dir/.gitignore, if present.
+func (g *gitignore) load(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	f, err := os.Open(filepath.Join(abs, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(abs, scanner.Text()); ok {
+			g.rules = append(g.rules, rule)
+		}
+	}
+}
+
+// ignored reports whether path is excluded by the loaded rules. The last
+// matching rule wins, mirroring git.
+func (g *gitignore) ignored(path string, isDir bool) bool {
+	if g == nil || len(g.rules) == 0 {
+		return false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	ignored := false
+	for _, rule := range g.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, abs)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		rel = filepath.ToSlash(rel)
+		if !rule.anchored {
+			rel = rel[strings.LastIndex(rel, "/")+1:]
+		}
+		if rule.re.MatchString(rel) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func parseIgnoreRule(base, line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	rule := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	re, err := regexp.Compile(globToRegexp(line))
+	if err != nil {
+		return ignoreRule{}, false
+	}
+	rule.re = re
+	return rule, true
+}
+
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); {
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 3
+		case strings.HasPrefix(glob[i:], "/**"):
+			sb.WriteString("(/.*)?")
+			i += 3
+		case strings.HasPrefix(glob[i:], "**"):
+			sb.WriteString(".*")
+			i += 2
+		case glob[i] == '*':
+			sb.WriteString("[^/]*")
+			i++
+		case glob[i] == '?':
+			sb.WriteString("[^/]")
+			i++
+		case glob[i] == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end == -1 {
+				sb.WriteString(regexp.QuoteMeta(glob[i:]))
+				i = len(glob)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + class + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			i++
+		}
+	}
+	sb.WriteString("$")
+	return sb.String()
+}
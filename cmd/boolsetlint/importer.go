@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/build"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// listExports asks the go command for the export data of dir's dependencies,
+// so type information is resolved exactly like the project's own builds:
+// GOFLAGS from the environment, -mod and -modfile all apply. The result maps
+// import paths to export data files.
+func listExports(ctx *build.Context, dir string, opts options) (map[string]string, error) {
+	args := []string{"list", "-e", "-export", "-deps", "-f", "{{.ImportPath}}\t{{.Export}}"}
+	if opts.tests {
+		args = append(args, "-test")
+	}
+	args = append(args, opts.goFlags()...)
+	args = append(args, ".")
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS="+ctx.GOOS, "GOARCH="+ctx.GOARCH)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("go list: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	exports := make(map[string]string)
+	variants := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		path, file, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok || file == "" {
+			continue
+		}
+		// With -test, the package under test is also listed compiled with
+		// its _test.go files, as "p [p.test]", and so are the packages
+		// importing it that the external tests import. Those are the
+		// variants the external test package sees, and nothing else
+		// imports them.
+		if path, variant, ok := strings.Cut(path, " "); ok {
+			if strings.HasPrefix(variant, "[") && strings.HasSuffix(variant, ".test]") {
+				variants[path] = file
+			}
+			continue
+		}
+		exports[path] = file
+	}
+	maps.Copy(exports, variants)
+	return exports, scanner.Err()
+}
+
+// newImporter returns an importer that prefers the given export data files
+// and falls back to the compiler's own export data and finally to
+// type-checking dependencies from source, so a fresh checkout without a prior
+// build (or a cross-compiled target) still gets full type information.
+func newImporter(fset *token.FileSet, exports map[string]string) types.Importer {
+	var chain fallbackImporter
+	if len(exports) > 0 {
+		chain = append(chain, importer.ForCompiler(fset, "gc", func(path string) (io.ReadCloser, error) {
+			file, ok := exports[path]
+			if !ok {
+				return nil, fmt.Errorf("no export data for %q", path)
+			}
+			return os.Open(file)
+		}).(types.ImporterFrom))
+	}
+	chain = append(chain,
+		importer.Default().(types.ImporterFrom),
+		importer.ForCompiler(fset, "source", nil).(types.ImporterFrom),
+	)
+	return chain
+}
+
+// fallbackImporter tries each importer in turn, returning the first package
+// that imports successfully.
+type fallbackImporter []types.ImporterFrom
+
+func (f fallbackImporter) Import(path string) (*types.Package, error) {
+	return f.ImportFrom(path, "", 0)
+}
+
+func (f fallbackImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	var errs []error
+	for _, imp := range f {
+		pkg, err := imp.ImportFrom(path, dir, mode)
+		if err == nil {
+			return pkg, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// list prints what a run over targets would analyse without analysing it:
+// one line per file when files is set, otherwise one line per package
+// directory. Directories without selected files are omitted.
+func list(w io.Writer, targets []string, opts options, files bool) error {
+	for _, target := range targets {
+		dir := target
+		if info, err := os.Stat(target); err != nil {
+			return err
+		} else if !info.IsDir() {
+			dir = filepath.Dir(target)
+		}
+
+		seen := make(map[string]struct{})
+		var names []string
+		for _, ctx := range opts.buildContexts() {
+			groups, err := packageFiles(&ctx, dir, opts)
+			if err != nil {
+				return err
+			}
+			for _, group := range groups {
+				for _, name := range group {
+					if _, ok := seen[name]; ok {
+						continue
+					}
+					seen[name] = struct{}{}
+					names = append(names, name)
+				}
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+		if !files {
+			if _, err := fmt.Fprintln(w, dir); err != nil {
+				return err
+			}
+			continue
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, err := fmt.Fprintln(w, filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/arturmelanchyk/boolset/boolset"
+)
+
+// lspServer implements the subset of the Language Server Protocol needed to
+// publish boolset diagnostics and offer the map[T]struct{} rewrite as a quick
+// fix. Packages are analysed from disk when a document is opened or saved;
+// documents with unsaved changes get no diagnostics or fixes, since their
+// positions on disk no longer match the buffer.
+type lspServer struct {
+	opts options
+	in   *bufio.Reader
+	out  io.Writer
+
+	// findings holds the last published findings per document URI.
+	findings map[string][]finding
+	// published lists the documents of each directory that last received
+	// diagnostics, so fixed files get their diagnostics cleared.
+	published map[string][]string
+	// dirty holds the documents changed since they were last opened or
+	// saved.
+	dirty    map[string]bool
+	shutdown bool
+	// encoding is the position encoding negotiated with the client.
+	encoding string
+}
+
+type lspRequest struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspCodeAction struct {
+	Title       string          `json:"title"`
+	Kind        string          `json:"kind"`
+	Diagnostics []lspDiagnostic `json:"diagnostics"`
+	Edit        struct {
+		Changes map[string][]lspTextEdit `json:"changes"`
+	} `json:"edit"`
+}
+
+type lspTextDocument struct {
+	URI string `json:"uri"`
+}
+
+const (
+	lspSeverityError       = 1
+	lspSeverityWarning     = 2
+	lspSeverityInformation = 3
+	lspMethodNotFound      = -32601
+	lspInvalidParams       = -32602
+	lspServerNotReady      = -32002
+	lspKindQuickFix        = "quickfix"
+	lspSyncIncremental     = 2
+
+	// lspMaxMessage bounds the Content-Length of a message, so a corrupt
+	// header cannot make the server allocate without limit.
+	lspMaxMessage = 64 << 20
+
+	lspEncodingUTF8  = "utf-8"
+	lspEncodingUTF16 = "utf-16"
+)
+
+func newLSPServer(in io.Reader, out io.Writer, opts options) *lspServer {
+	return &lspServer{
+		opts:      opts,
+		in:        bufio.NewReader(in),
+		out:       out,
+		findings:  make(map[string][]finding),
+		published: make(map[string][]string),
+		dirty:     make(map[string]bool),
+		encoding:  lspEncodingUTF16,
+	}
+}
+
+// run serves requests until the client sends exit, returning the process
+// exit code mandated by the protocol.
+func (s *lspServer) run() int {
+	for {
+		req, err := s.read()
+		if err != nil {
+			return 1
+		}
+		if req.Method == "exit" {
+			if s.shutdown {
+				return 0
+			}
+			return 1
+		}
+		result, rpcErr := s.handle(req)
+		if len(req.ID) == 0 {
+			// Notifications never get a response.
+			continue
+		}
+		if err := s.respond(req.ID, result, rpcErr); err != nil {
+			return 1
+		}
+	}
+}
+
+func (s *lspServer) handle(req lspRequest) (any, *lspError) {
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			Capabilities struct {
+				General struct {
+					PositionEncodings []string `json:"positionEncodings"`
+				} `json:"general"`
+			} `json:"capabilities"`
+		}
+		if len(req.Params) > 0 {
+			if err := json.Unmarshal(req.Params, &params); err != nil {
+				return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+			}
+		}
+		// Byte columns are used as they are when the client accepts them;
+		// UTF-16, the protocol's default, is what every client supports.
+		s.encoding = lspEncodingUTF16
+		for _, encoding := range params.Capabilities.General.PositionEncodings {
+			if encoding == lspEncodingUTF8 {
+				s.encoding = lspEncodingUTF8
+			}
+		}
+		return map[string]any{
+			"capabilities": map[string]any{
+				"positionEncoding": s.encoding,
+				"textDocumentSync": map[string]any{
+					"openClose": true,
+					"change":    lspSyncIncremental,
+					"save":      true,
+				},
+				"codeActionProvider": map[string]any{
+					"codeActionKinds": []string{lspKindQuickFix},
+				},
+			},
+			"serverInfo": map[string]any{"name": "boolsetlint"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen", "textDocument/didSave":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		delete(s.dirty, params.TextDocument.URI)
+		if err := s.analyze(params.TextDocument.URI); err != nil {
+			return nil, &lspError{Code: lspServerNotReady, Message: err.Error()}
+		}
+		return nil, nil
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		// The findings of the file on disk no longer match the buffer;
+		// they return once it is saved.
+		uri := params.TextDocument.URI
+		if s.dirty[uri] {
+			return nil, nil
+		}
+		s.dirty[uri] = true
+		delete(s.findings, uri)
+		if err := s.notify("textDocument/publishDiagnostics", map[string]any{
+			"uri":         uri,
+			"diagnostics": []lspDiagnostic{},
+		}); err != nil {
+			return nil, &lspError{Code: lspServerNotReady, Message: err.Error()}
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		// Closing discards unsaved changes.
+		delete(s.dirty, params.TextDocument.URI)
+		return nil, nil
+	case "textDocument/codeAction":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+			Range        lspRange        `json:"range"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, &lspError{Code: lspInvalidParams, Message: err.Error()}
+		}
+		return s.codeActions(params.TextDocument.URI, params.Range), nil
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	}
+	return nil, &lspError{Code: lspMethodNotFound, Message: "method not supported: " + req.Method}
+}
+
+// analyze re-analyses the package containing uri and publishes diagnostics
+// for every document of that package.
+func (s *lspServer) analyze(uri string) error {
+	path, err := uriToPath(uri)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	// Files that fail to parse are skipped; publish what could be analysed.
+	findings, analyzeErr := collectDir(dir, s.opts)
+
+	byURI := make(map[string][]finding)
+	for _, f := range findings {
+		u := pathToURI(f.pos.Filename)
+		if s.dirty[u] {
+			byURI[u] = nil
+			continue
+		}
+		byURI[u] = append(byURI[u], f)
+	}
+	for _, u := range s.published[dir] {
+		if _, ok := byURI[u]; !ok {
+			byURI[u] = nil
+		}
+	}
+
+	uris := make([]string, 0, len(byURI))
+	for u := range byURI {
+		uris = append(uris, u)
+	}
+	sort.Strings(uris)
+	s.published[dir] = nil
+	for _, u := range uris {
+		list := byURI[u]
+		if len(list) == 0 {
+			delete(s.findings, u)
+		} else {
+			s.findings[u] = list
+			s.published[dir] = append(s.published[dir], u)
+		}
+		conv := s.converter()
+		diags := make([]lspDiagnostic, 0, len(list))
+		for _, f := range list {
+			diags = append(diags, toLSPDiagnostic(f, conv))
+		}
+		err := s.notify("textDocument/publishDiagnostics", map[string]any{
+			"uri":         u,
+			"diagnostics": diags,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return analyzeErr
+}
+
+func (s *lspServer) codeActions(uri string, rng lspRange) []lspCodeAction {
+	actions := []lspCodeAction{}
+	conv := s.converter()
+	for _, f := range s.findings[uri] {
+		line := f.pos.Line - 1
+		if len(f.edits) == 0 || line < rng.Start.Line || line > rng.End.Line || s.editsDirty(f) {
+			continue
+		}
+		action := lspCodeAction{
+			Title:       "Convert to map[T]struct{}",
+			Kind:        lspKindQuickFix,
+			Diagnostics: []lspDiagnostic{toLSPDiagnostic(f, conv)},
+		}
+		action.Edit.Changes = make(map[string][]lspTextEdit)
+		for _, e := range f.edits {
+			u := pathToURI(e.pos.Filename)
+			action.Edit.Changes[u] = append(action.Edit.Changes[u], lspTextEdit{
+				Range:   lspRange{Start: conv(e.pos), End: conv(e.end)},
+				NewText: e.newText,
+			})
+		}
+		actions = append(actions, action)
+	}
+	return actions
+}
+
+// editsDirty reports whether the fix of f edits a document with unsaved
+// changes, whose offsets on disk no longer match the buffer.
+func (s *lspServer) editsDirty(f finding) bool {
+	for _, e := range f.edits {
+		if s.dirty[pathToURI(e.pos.Filename)] {
+			return true
+		}
+	}
+	return false
+}
+
+func toLSPDiagnostic(f finding, conv func(token.Position) lspPosition) lspDiagnostic {
+	rng := lspRange{Start: conv(f.pos), End: conv(f.pos)}
+	if f.end.IsValid() {
+		rng.End = conv(f.end)
+	}
+	return lspDiagnostic{
+		Range:    rng,
+		Severity: lspSeverity(f.severity),
+		Code:     f.rule,
+		Source:   "boolset",
+		Message:  f.display(),
+	}
+}
+
+// lspSeverity returns the diagnostic severity of findings of severity sev.
+func lspSeverity(sev boolset.Severity) int {
+	switch sev {
+	case boolset.SeverityInfo:
+		return lspSeverityInformation
+	case boolset.SeverityError:
+		return lspSeverityError
+	}
+	return lspSeverityWarning
+}
+
+// converter returns a function converting positions to the negotiated
+// encoding, reading each file the positions lie in at most once.
+func (s *lspServer) converter() func(token.Position) lspPosition {
+	if s.encoding == lspEncodingUTF8 {
+		return toLSPPosition
+	}
+	sources := make(map[string][]byte)
+	return func(pos token.Position) lspPosition {
+		src, ok := sources[pos.Filename]
+		if !ok {
+			// An unreadable file keeps byte columns, which are right
+			// for ASCII lines.
+			src, _ = os.ReadFile(pos.Filename)
+			sources[pos.Filename] = src
+		}
+		return toUTF16Position(pos, src)
+	}
+}
+
+// toLSPPosition converts to zero-based lines and byte columns, for the utf-8
+// position encoding.
+func toLSPPosition(pos token.Position) lspPosition {
+	return lspPosition{Line: pos.Line - 1, Character: pos.Column - 1}
+}
+
+// toUTF16Position converts to zero-based lines and columns counted in UTF-16
+// code units of the line in src, for the utf-16 position encoding.
+func toUTF16Position(pos token.Position, src []byte) lspPosition {
+	p := toLSPPosition(pos)
+	start := pos.Offset - p.Character
+	if start < 0 || pos.Offset > len(src) {
+		return p
+	}
+	p.Character = 0
+	for _, r := range string(src[start:pos.Offset]) {
+		p.Character += utf16.RuneLen(r)
+	}
+	return p
+}
+
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported document URI %q", uri)
+	}
+	path := u.Path
+	if windowsPaths {
+		// file:///C:/src/p.go carries a leading slash before the drive letter.
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path), nil
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (s *lspServer) read() (lspRequest, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return lspRequest{}, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return lspRequest{}, errors.New("missing Content-Length header")
+	}
+	if length < 0 || length > lspMaxMessage {
+		return lspRequest{}, fmt.Errorf("invalid Content-Length %d", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return lspRequest{}, err
+	}
+	var req lspRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return lspRequest{}, err
+	}
+	return req, nil
+}
+
+func (s *lspServer) respond(id json.RawMessage, result any, rpcErr *lspError) error {
+	msg := map[string]any{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		msg["error"] = rpcErr
+	} else {
+		msg["result"] = result
+	}
+	return s.write(msg)
+}
+
+func (s *lspServer) notify(method string, params any) error {
+	return s.write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *lspServer) write(msg any) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = s.out.Write(body)
+	return err
+}
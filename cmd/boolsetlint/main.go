@@ -1,289 +1,701 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"errors"
-	"flag"
 	"fmt"
 	"go/ast"
 	"go/build"
-	"go/importer"
 	"go/parser"
 	"go/token"
 	"go/types"
+	"io"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/arturmelanchyk/boolset/boolset"
 )
 
-func main() {
-	flag.Parse()
-	targets, err := expandTargets(flag.Args())
-	if err != nil {
-		if _, err := fmt.Fprintln(os.Stderr, err); err != nil {
-			os.Exit(2)
+// options holds the CLI settings shared by every inspected package.
+type options struct {
+	tests          bool
+	goos           stringList
+	goarch         stringList
+	recurseModules bool
+	noGitignore    bool
+	followSymlinks bool
+	mod            string
+	modfile        string
+	// cache is nil when results must not be reused across runs.
+	cache *resultCache
+	// types keeps type information warm across the runs of long-running
+	// commands; nil otherwise.
+	types *typeCache
+	// timings collects per-package durations in verbose mode; nil otherwise.
+	timings *timingLog
+	// parallel bounds how many packages are analysed at once.
+	parallel int
+	// out receives findings and warnings; os.Stderr when nil.
+	out io.Writer
+	// baseline suppresses previously recorded findings; nil reports all.
+	baseline *baseline
+	// paths controls how file names are printed in findings.
+	paths pathMode
+	// disabled holds the IDs of rules whose findings are dropped.
+	disabled map[string]struct{}
+	// reported collects reported findings for -sarif and -github-pr; nil
+	// otherwise.
+	reported *findingLog
+	// metrics counts reported findings for -metrics-out; nil otherwise.
+	metrics *metricsLog
+	// includeSerialized and ssa are the analysis settings of the same
+	// names on boolset.Analyzer.
+	includeSerialized bool
+	ssa               bool
+	// format names the boolset reporter run writes findings with; text
+	// when empty.
+	format string
+	// pending, when set, receives the findings inspectDir would print, so
+	// that run can report them in package order.
+	pending *[]finding
+}
+
+// output returns the writer findings and warnings are printed to.
+func (o options) output() io.Writer {
+	if o.out == nil {
+		return os.Stderr
+	}
+	return o.out
+}
+
+// stringList is a repeatable string flag that also accepts comma-separated values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
 		}
-		os.Exit(1)
 	}
+	return nil
+}
+
+// finding is a diagnostic with its position resolved against the file set.
+type finding struct {
+	pos token.Position
+	// end is the end of the reported expression; invalid when unknown.
+	end      token.Position
+	rule     string
+	severity boolset.Severity
+	message  string
+	// text is message in the user's locale, shown in place of message;
+	// empty when it is not translated. message, in English, identifies the
+	// finding in baselines and SARIF fingerprints.
+	text string
+	// object names the map the finding is about, qualified by the
+	// declaration enclosing it, e.g. "(*Server).init.seen".
+	object string
+	// pkg is the import path of the package the map was reported in.
+	pkg string
+	// keyType, elemType, writes and reads carry the details of
+	// boolset.Finding.
+	keyType  string
+	elemType string
+	writes   int
+	reads    int
+	// edits convert the map to map[T]struct{}; nil when no safe fix exists.
+	edits []edit
+}
+
+// messageLocale is the locale findings are shown in.
+var messageLocale = boolset.LocaleFromEnv()
+
+// display returns the message to show for f.
+func (f finding) display() string {
+	if f.text != "" {
+		return f.text
+	}
+	return f.message
+}
+
+// edit is a boolset.TextEdit with resolved positions.
+type edit struct {
+	pos     token.Position
+	end     token.Position
+	newText string
+}
+
+// findingLog collects the findings reported by a run. It is safe for
+// concurrent use by packages analysed in parallel.
+type findingLog struct {
+	mu       sync.Mutex
+	findings []finding
+}
+
+// add records findings. It does nothing on a nil log.
+func (l *findingLog) add(findings []finding) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	l.findings = append(l.findings, findings...)
+	l.mu.Unlock()
+}
+
+// sorted returns the recorded findings ordered by file and offset, so the
+// result does not depend on scheduling.
+func (l *findingLog) sorted() []finding {
+	findings := append([]finding(nil), l.findings...)
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].pos.Filename != findings[j].pos.Filename {
+			return findings[i].pos.Filename < findings[j].pos.Filename
+		}
+		return findings[i].pos.Offset < findings[j].pos.Offset
+	})
+	return findings
+}
+
+// pkgStats accumulates what happened while analysing one package directory.
+type pkgStats struct {
+	pkgTiming
+	// missing holds the imports that could not be resolved; findings for
+	// the package may be incomplete.
+	missing map[string]struct{}
+}
+
+// findingKey identifies a finding independently of its edits.
+type findingKey struct {
+	pos     token.Position
+	rule    string
+	message string
+}
+
+func main() {
+	exit(runCommand(os.Args[1:]))
+}
+
+// run inspects the targets in parallel, printing findings ordered by package
+// path, file and offset followed by a summary line, and reports the number of issues and whether any target failed.
+// Once ctx is done no further packages are started, and the run counts as failed.
+func run(ctx context.Context, targets []string, opts options) (int, bool) {
+	type result struct {
+		out      bytes.Buffer
+		findings []finding
+		count    int
+		err      error
+	}
+	results := make([]result, len(targets))
+	workers := opts.parallel
+	if workers < 1 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, path := range targets {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			pkgOpts := opts
+			pkgOpts.out = &results[i].out
+			pkgOpts.pending = &results[i].findings
+			results[i].count, results[i].err = inspectPath(path, pkgOpts)
+		}()
+	}
+	wg.Wait()
+
+	// Output is buffered per package and printed in package path order so
+	// that it does not depend on scheduling.
+	order := make([]int, len(targets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return packagePath(targets[order[i]]) < packagePath(targets[order[j]])
+	})
 
 	hadError := false
 	totalIssues := 0
-	for _, path := range targets {
-		count, err := inspectPath(path)
-		totalIssues += count
-		if err != nil {
-			if _, err := fmt.Fprintln(os.Stderr, err); err != nil {
-				os.Exit(2)
+	w := opts.output()
+	reporter := opts.reporter()
+	if err := reporter.Begin(); err != nil {
+		exit(exitFailure)
+	}
+	for _, i := range order {
+		r := &results[i]
+		if _, err := r.out.WriteTo(w); err != nil {
+			exit(exitFailure)
+		}
+		for _, f := range r.findings {
+			if err := reporter.Report(opts.public(f)); err != nil {
+				exit(exitFailure)
+			}
+		}
+		totalIssues += r.count
+		if r.err != nil {
+			if _, err := fmt.Fprintln(w, r.err); err != nil {
+				exit(exitFailure)
 			}
 			hadError = true
 		}
 	}
-	if totalIssues > 0 {
-		if _, err := fmt.Fprintf(os.Stderr, "boolsetlint found %d issue(s)\n", totalIssues); err != nil {
-			os.Exit(2)
+	if err := reporter.End(); err != nil {
+		exit(exitFailure)
+	}
+	if ctx.Err() != nil {
+		if _, err := fmt.Fprintln(w, "boolsetlint: interrupted; results are incomplete"); err != nil {
+			exit(exitFailure)
 		}
+		hadError = true
+	}
+	if err := opts.timings.summary(slowestPackages); err != nil {
+		exit(exitFailure)
 	}
-	if hadError || totalIssues > 0 {
-		os.Exit(1)
+	if totalIssues > 0 {
+		if _, err := fmt.Fprintf(w, "boolsetlint found %d issue(s)\n", totalIssues); err != nil {
+			exit(exitFailure)
+		}
 	}
+	return totalIssues, hadError
 }
 
-func inspectPath(path string) (int, error) {
-	info, err := os.Stat(path)
-	if err != nil {
-		return 0, err
+// defaultTests reports whether test files are analysed when -tests is not given.
+func defaultTests() bool {
+	v, ok := os.LookupEnv("BOOLSETLINT_TESTS")
+	if !ok {
+		return false
+	}
+	enabled, err := strconv.ParseBool(v)
+	return err == nil && enabled
+}
+
+// goFlags returns the go command flags that control dependency resolution.
+// GOFLAGS from the environment is honoured by the go command itself.
+func (o options) goFlags() []string {
+	var flags []string
+	if o.mod != "" {
+		flags = append(flags, "-mod="+o.mod)
 	}
-	if info.IsDir() {
-		return inspectDir(path)
+	if o.modfile != "" {
+		flags = append(flags, "-modfile="+o.modfile)
 	}
-	return inspectDir(filepath.Dir(path))
+	return flags
 }
 
-func inspectDir(dir string) (int, error) {
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return 0, err
-	}
-	hasGo := false
-	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".go" {
-			hasGo = true
-			break
+// buildContexts returns one build context per requested GOOS/GOARCH pair.
+func (o options) buildContexts() []build.Context {
+	goos := o.goos
+	if len(goos) == 0 {
+		goos = stringList{build.Default.GOOS}
+	}
+	goarch := o.goarch
+	if len(goarch) == 0 {
+		goarch = stringList{build.Default.GOARCH}
+	}
+	contexts := make([]build.Context, 0, len(goos)*len(goarch))
+	for _, targetOS := range goos {
+		for _, arch := range goarch {
+			ctx := build.Default
+			ctx.GOOS = targetOS
+			ctx.GOARCH = arch
+			contexts = append(contexts, ctx)
 		}
 	}
-	if !hasGo {
-		return 0, nil
-	}
+	return contexts
+}
 
-	buildPkg, err := build.Default.ImportDir(dir, 0)
-	if err != nil {
-		var noGo *build.NoGoError
-		if errors.As(err, &noGo) {
-			return 0, nil
-		}
-		return 0, err
+// packagePath returns the slash-separated package directory of target, the
+// key output is ordered by.
+func packagePath(target string) string {
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		target = filepath.Dir(target)
 	}
+	return filepath.ToSlash(filepath.Clean(target))
+}
 
-	files, fileSet, err := parseFiles(dir, buildPkg.GoFiles)
+func inspectPath(path string, opts options) (int, error) {
+	info, err := os.Stat(path)
 	if err != nil {
 		return 0, err
 	}
-	if len(files) == 0 {
-		return 0, nil
+	if info.IsDir() {
+		return inspectDir(path, opts)
 	}
+	return inspectDir(filepath.Dir(path), opts)
+}
 
-	pkgName := files[0].Name.Name
+// inspectDir prints the findings for the package in dir and returns their count.
+// Findings are printed even when some files could not be analysed.
+func inspectDir(dir string, opts options) (int, error) {
+	findings, err := collectDir(dir, opts)
+	findings = opts.baseline.filter(findings)
+	opts.reported.add(findings)
+	opts.metrics.add(dir, findings)
+	if opts.pending != nil {
+		*opts.pending = append(*opts.pending, findings...)
+		return len(findings), err
+	}
+	for _, f := range findings {
+		opts.writeFinding(opts.output(), f)
+	}
+	return len(findings), err
+}
 
-	conf := types.Config{
-		Importer: importer.Default(),
-		Error:    func(err error) {},
+// writeFinding prints f to w in the text format.
+func (o options) writeFinding(w io.Writer, f finding) {
+	r, _ := boolset.NewReporter("text", w)
+	if err := r.Report(o.public(f)); err != nil {
+		exit(exitFailure)
 	}
-	info := &types.Info{
-		Types:      make(map[ast.Expr]types.TypeAndValue),
-		Defs:       make(map[*ast.Ident]types.Object),
-		Uses:       make(map[*ast.Ident]types.Object),
-		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+}
+
+// reporter returns the reporter selected by -format. Text goes where
+// warnings go; other formats are meant for programs and go to stdout.
+func (o options) reporter() boolset.Reporter {
+	format, w := o.format, o.output()
+	if format == "" {
+		format = "text"
+	} else if format != "text" && o.out == nil {
+		w = os.Stdout
+	}
+	r, ok := boolset.NewReporter(format, w)
+	if !ok {
+		// -format is validated when parsed.
+		panic("unknown format " + format)
+	}
+	return r
+}
+
+// public converts f for boolset reporters, with the file name as -path-mode
+// prints it.
+func (o options) public(f finding) boolset.PositionedFinding {
+	p := boolset.PositionedFinding{Position: f.pos, End: f.end}
+	p.Position.Filename = o.paths.display(f.pos.Filename)
+	if p.End.IsValid() {
+		p.End.Filename = p.Position.Filename
+	}
+	p.Rule = f.rule
+	p.Severity = f.severity
+	p.Message = f.display()
+	p.URL = boolset.RuleURL(f.rule)
+	p.Package = f.pkg
+	p.Name = f.object[strings.LastIndex(f.object, ".")+1:]
+	p.KeyType = f.keyType
+	p.ElemType = f.elemType
+	p.Writes = f.writes
+	p.Reads = f.reads
+	p.Fixable = len(f.edits) > 0
+	for _, e := range f.edits {
+		p.Edits = append(p.Edits, boolset.PositionedEdit{Position: e.pos, End: e.end, NewText: e.newText})
+	}
+	return p
+}
+
+// collectPath returns the sorted findings for the package containing path.
+func collectPath(path string, opts options) ([]finding, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return collectDir(path, opts)
 	}
+	return collectDir(filepath.Dir(path), opts)
+}
 
-	pkgTypes, err := conf.Check(pkgName, fileSet, files, info)
-	if pkgTypes == nil {
-		return 0, err
+// collectDir returns the sorted findings for the package in dir across every
+// requested build context. Files that fail to parse are skipped and reported
+// through the returned error alongside the findings of the remaining files.
+func collectDir(dir string, opts options) ([]finding, error) {
+	if hasGo, err := hasGoFiles(dir); !hasGo {
+		return nil, err
 	}
 
-	diagnostics := boolset.Analyze(pkgTypes, files, info)
-	if len(diagnostics) == 0 {
-		return 0, nil
+	stats := &pkgStats{pkgTiming: pkgTiming{dir: dir}, missing: make(map[string]struct{})}
+	defer opts.timings.record(&stats.pkgTiming)
+
+	// Findings from files shared between platforms are reported once.
+	seen := make(map[findingKey]struct{})
+	var findings []finding
+	seenErrs := make(map[string]struct{})
+	var errs []error
+	for _, ctx := range opts.buildContexts() {
+		found, err := inspectDirContext(&ctx, dir, opts, stats)
+		if err != nil {
+			if _, ok := seenErrs[err.Error()]; !ok {
+				seenErrs[err.Error()] = struct{}{}
+				errs = append(errs, err)
+			}
+		}
+		for _, f := range found {
+			key := findingKey{pos: f.pos, rule: f.rule, message: f.message}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			findings = append(findings, f)
+		}
 	}
 
-	sort.Slice(diagnostics, func(i, j int) bool {
-		return fileSet.Position(diagnostics[i].Pos).Offset < fileSet.Position(diagnostics[j].Pos).Offset
+	findings = filterRules(findings, opts.disabled)
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].pos.Filename != findings[j].pos.Filename {
+			return findings[i].pos.Filename < findings[j].pos.Filename
+		}
+		return findings[i].pos.Offset < findings[j].pos.Offset
 	})
 
-	for _, diag := range diagnostics {
-		pos := fileSet.Position(diag.Pos)
-		if _, err := fmt.Fprintf(os.Stderr, "%s:%d:%d: %s\n", pos.Filename, pos.Line, pos.Column, diag.Message); err != nil {
-			os.Exit(2)
+	if len(stats.missing) > 0 {
+		missing := make([]string, 0, len(stats.missing))
+		for path := range stats.missing {
+			missing = append(missing, path)
+		}
+		sort.Strings(missing)
+		if _, err := fmt.Fprintf(opts.output(), "boolsetlint: warning: %s: could not import %s; results may be partial\n", dir, strings.Join(missing, ", ")); err != nil {
+			exit(exitFailure)
 		}
 	}
-	return len(diagnostics), nil
+	return findings, errors.Join(errs...)
 }
 
-func parseFiles(dir string, names []string) ([]*ast.File, *token.FileSet, error) {
-	if len(names) == 0 {
-		return nil, nil, nil
+// hasGoFiles reports whether dir directly contains any .go file, regardless
+// of build constraints.
+func hasGoFiles(dir string) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
 	}
-	fset := token.NewFileSet()
-	files := make([]*ast.File, 0, len(names))
-	for _, name := range names {
-		path := filepath.Join(dir, name)
-		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
-		if err != nil {
-			return nil, nil, err
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".go" {
+			return true, nil
 		}
-		files = append(files, file)
 	}
-	return files, fset, nil
+	return false, nil
 }
 
-func expandTargets(args []string) ([]string, error) {
-	if len(args) == 0 {
-		args = []string{"."}
+// missingImport reports the import path of a type-checking error caused by
+// an unresolvable import.
+func missingImport(err error) (string, bool) {
+	var typeErr types.Error
+	if !errors.As(err, &typeErr) {
+		return "", false
+	}
+	rest, ok := strings.CutPrefix(typeErr.Msg, "could not import ")
+	if !ok {
+		return "", false
+	}
+	path, _, _ := strings.Cut(rest, " ")
+	return path, true
+}
+
+func inspectDirContext(ctx *build.Context, dir string, opts options, stats *pkgStats) ([]finding, error) {
+	start := time.Now()
+	groups, err := packageFiles(ctx, dir, opts)
+	stats.load += time.Since(start)
+	if err != nil || len(groups) == 0 {
+		return nil, err
 	}
 
-	seen := make(map[string]struct{})
-	var targets []string
-	for _, arg := range args {
-		expanded, err := expandArg(arg)
-		if err != nil {
-			return nil, err
-		}
-		for _, target := range expanded {
-			clean := filepath.Clean(target)
-			if _, ok := seen[clean]; ok {
-				continue
-			}
-			seen[clean] = struct{}{}
-			targets = append(targets, clean)
+	// Listing export data builds dependencies, so it is left until a group
+	// of files misses the cache.
+	var exports map[string]string
+	listed := false
+	loadExports := func() map[string]string {
+		if !listed {
+			listed = true
+			start := time.Now()
+			// Without export data from the go command, importer.Default is used.
+			exports, _ = listExports(ctx, dir, opts)
+			stats.load += time.Since(start)
 		}
+		return exports
 	}
-	return targets, nil
-}
 
-func expandArg(arg string) ([]string, error) {
-	if strings.Contains(arg, "...") {
-		dirs, err := expandEllipsis(arg)
+	var findings []finding
+	var errs []error
+	for _, names := range groups {
+		found, err := analyzeCached(opts, ctx, dir, names, loadExports, stats)
 		if err != nil {
-			return nil, err
+			errs = append(errs, err)
 		}
-		if len(dirs) == 0 {
-			return nil, fmt.Errorf("pattern %q matched no directories", arg)
-		}
-		return dirs, nil
+		findings = append(findings, found...)
 	}
-	return []string{arg}, nil
+	return findings, errors.Join(errs...)
 }
 
-func expandEllipsis(pattern string) ([]string, error) {
-	re, err := compilePattern(pattern)
+// packageFiles returns the file names of dir selected by ctx, grouped by the
+// package they are type-checked in. External test packages (package foo_test)
+// form a separate group.
+func packageFiles(ctx *build.Context, dir string, opts options) ([][]string, error) {
+	// Resolve imports relative to the module that owns dir, not the working directory.
+	ctx.Dir = moduleRoot(dir)
+	buildPkg, err := ctx.ImportDir(dir, 0)
 	if err != nil {
+		var noGo *build.NoGoError
+		if errors.As(err, &noGo) {
+			return nil, nil
+		}
 		return nil, err
 	}
-	root := walkRoot(pattern)
-	if _, err := os.Stat(root); err != nil {
-		return nil, err
+
+	names := buildPkg.GoFiles
+	if opts.tests {
+		names = append(append([]string(nil), names...), buildPkg.TestGoFiles...)
+	}
+	var groups [][]string
+	if len(names) > 0 {
+		groups = append(groups, names)
 	}
+	if opts.tests && len(buildPkg.XTestGoFiles) > 0 {
+		groups = append(groups, buildPkg.XTestGoFiles)
+	}
+	return groups, nil
+}
 
-	var dirs []string
-	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info == nil || !info.IsDir() {
-			return nil
-		}
-		candidate := normalizeForMatch(path)
-		if re.MatchString(candidate) || (candidate != "." && re.MatchString(candidate+"/")) {
-			dirs = append(dirs, path)
-		}
-		return nil
-	})
-	if err != nil {
+// linter returns the linter packages are analysed with.
+func (o options) linter() *boolset.Linter {
+	return boolset.New(boolset.WithSerialized(o.includeSerialized), boolset.WithSSA(o.ssa))
+}
+
+func analyzeFiles(l *boolset.Linter, dir string, names []string, exports map[string]string, stats *pkgStats) ([]finding, error) {
+	fset := token.NewFileSet()
+	checked, err := checkFiles(fset, newImporter(fset, exports), dir, names, stats)
+	if checked == nil {
 		return nil, err
 	}
+	return analyzeChecked(l, checked, stats)
+}
 
-	sort.Strings(dirs)
-	return dirs, nil
+// checkedPackage is a parsed and type-checked group of files.
+type checkedPackage struct {
+	dir   string
+	fset  *token.FileSet
+	files []*ast.File
+	pkg   *types.Package
+	info  *types.Info
+	// parseErr lists the files skipped for syntax errors, and missing the
+	// imports that could not be resolved.
+	parseErr error
+	missing  []string
 }
 
-func walkRoot(pattern string) string {
-	idx := strings.Index(pattern, "...")
-	root := pattern
-	if idx != -1 {
-		root = pattern[:idx]
+// checkFiles parses the named files into fset and type-checks them with
+// imp. It returns nil, and the errors, when no package could be checked.
+func checkFiles(fset *token.FileSet, imp types.Importer, dir string, names []string, stats *pkgStats) (*checkedPackage, error) {
+	start := time.Now()
+	files, parseErr := parseFiles(fset, dir, names)
+	stats.load += time.Since(start)
+	if len(files) == 0 {
+		return nil, parseErr
+	}
+
+	c := &checkedPackage{dir: dir, fset: fset, files: files, parseErr: parseErr}
+	conf := types.Config{
+		Importer: imp,
+		Error: func(err error) {
+			// Other type errors are tolerated; the analyser skips what
+			// it cannot resolve.
+			if path, ok := missingImport(err); ok {
+				stats.missing[path] = struct{}{}
+				c.missing = append(c.missing, path)
+			}
+		},
 	}
-	root = filepath.Clean(filepath.FromSlash(root))
-	if root == "" {
-		return "."
+	c.info = &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		// The SSA builder of -ssa needs the rest.
+		Instances:    make(map[*ast.Ident]types.Instance),
+		Implicits:    make(map[ast.Node]types.Object),
+		Scopes:       make(map[ast.Node]*types.Scope),
+		FileVersions: make(map[*ast.File]string),
 	}
-	return root
+
+	start = time.Now()
+	pkg, err := conf.Check(files[0].Name.Name, fset, files, c.info)
+	stats.check += time.Since(start)
+	if pkg == nil {
+		return nil, errors.Join(parseErr, err)
+	}
+	c.pkg = pkg
+	return c, nil
 }
 
-func compilePattern(pattern string) (*regexp.Regexp, error) {
-	norm := normalizeForMatch(pattern)
-	var sb strings.Builder
-	sb.WriteString("^")
-	for i := 0; i < len(norm); {
-		if strings.HasPrefix(norm[i:], "...") {
-			sb.WriteString(".*")
-			i += 3
-			continue
-		}
-		switch norm[i] {
-		case '*':
-			sb.WriteString("[^/]*")
-		case '?':
-			sb.WriteString("[^/]")
-		default:
-			sb.WriteString(regexp.QuoteMeta(norm[i : i+1]))
-		}
-		i++
+// analyzeChecked runs the analysis on c with l.
+func analyzeChecked(l *boolset.Linter, c *checkedPackage, stats *pkgStats) ([]finding, error) {
+	pkgName := c.pkg.Name()
+	pkgPath := importPath(c.dir)
+	if pkgPath == "" {
+		pkgPath = pkgName
+	} else if strings.HasSuffix(pkgName, "_test") {
+		// External test packages are named after the package they test.
+		pkgPath += "_test"
 	}
-	sb.WriteString("$")
-	return regexp.Compile(sb.String())
-}
 
-func normalizeForMatch(path string) string {
-	if path == "" {
-		return "."
+	start := time.Now()
+	reported, err := l.Analyze(context.Background(), c.pkg, c.files, c.info)
+	stats.analyze += time.Since(start)
+	if err != nil {
+		return nil, err
 	}
-	path = filepath.ToSlash(path)
-	if isAbsPath(path) {
-		if strings.HasSuffix(path, "/") && path != "/" {
-			path = strings.TrimSuffix(path, "/")
+	findings := make([]finding, 0, len(reported))
+	for _, diag := range reported {
+		f := finding{
+			pos:      c.fset.Position(diag.Pos),
+			end:      c.fset.Position(diag.End),
+			rule:     diag.Rule,
+			severity: diag.Severity,
+			message:  diag.Message,
+			object:   objectName(c.files, diag.Object),
+			pkg:      pkgPath,
+			keyType:  diag.KeyType,
+			elemType: diag.ElemType,
+			writes:   diag.Writes,
+			reads:    diag.Reads,
 		}
-		return path
-	}
-	for strings.HasPrefix(path, "./") {
-		path = path[2:]
-	}
-	if path == "" {
-		return "."
-	}
-	if strings.HasSuffix(path, "/") {
-		path = strings.TrimSuffix(path, "/")
+		if text := boolset.Localize(diag, messageLocale).Message; text != diag.Message {
+			f.text = text
+		}
+		for _, e := range diag.Edits {
+			f.edits = append(f.edits, edit{pos: c.fset.Position(e.Pos), end: c.fset.Position(e.End), newText: e.NewText})
+		}
+		findings = append(findings, f)
 	}
-	return path
+	return findings, c.parseErr
 }
 
-func isAbsPath(path string) bool {
-	if filepath.IsAbs(path) {
-		return true
+// parseFiles parses the named files into fset, skipping those with syntax
+// errors. The returned error lists every skipped file.
+func parseFiles(fset *token.FileSet, dir string, names []string) ([]*ast.File, error) {
+	if len(names) == 0 {
+		return nil, nil
 	}
-	if len(path) >= 2 && path[1] == ':' {
-		return true
+	files := make([]*ast.File, 0, len(names))
+	var errs []error
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		file, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("skipping %s: %w", path, err))
+			continue
+		}
+		files = append(files, file)
 	}
-	return strings.HasPrefix(path, "/")
+	return files, errors.Join(errs...)
 }
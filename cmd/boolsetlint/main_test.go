@@ -1,16 +1,49 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"go/build"
+	"go/token"
+	"go/types"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"reflect"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/arturmelanchyk/boolset/boolset"
 )
 
+func TestMain(m *testing.M) {
+	// Tests compare messages in English, whatever the developer's locale.
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		os.Unsetenv(name)
+	}
+	messageLocale = boolset.LocaleFromEnv()
+	os.Exit(m.Run())
+}
+
 func TestExpandTargetsDefault(t *testing.T) {
 	t.Parallel()
 
-	targets, err := expandTargets(nil)
+	targets, err := expandTargets(nil, options{})
 	if err != nil {
 		t.Fatalf("expandTargets returned error: %v", err)
 	}
@@ -31,7 +64,7 @@ func TestExpandTargetsEllipsis(t *testing.T) {
 
 	withWorkingDir(t, tmp)
 
-	targets, err := expandTargets([]string{"./..."})
+	targets, err := expandTargets([]string{"./..."}, options{})
 	if err != nil {
 		t.Fatalf("expandTargets returned error: %v", err)
 	}
@@ -41,6 +74,1798 @@ func TestExpandTargetsEllipsis(t *testing.T) {
 	}
 }
 
+func TestExpandTargetsNestedModules(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmp, "pkg"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmp, "nested", "sub"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/root\n")
+	writeFile(t, filepath.Join(tmp, "nested", "go.mod"), "module example.com/nested\n")
+
+	withWorkingDir(t, tmp)
+
+	targets, err := expandTargets([]string{"./..."}, options{})
+	if err != nil {
+		t.Fatalf("expandTargets returned error: %v", err)
+	}
+	want := []string{".", "pkg"}
+	if !reflect.DeepEqual(targets, want) {
+		t.Fatalf("unexpected targets %v, want %v", targets, want)
+	}
+
+	targets, err = expandTargets([]string{"./..."}, options{recurseModules: true})
+	if err != nil {
+		t.Fatalf("expandTargets returned error: %v", err)
+	}
+	want = []string{".", "nested", filepath.Join("nested", "sub"), "pkg"}
+	if !reflect.DeepEqual(targets, want) {
+		t.Fatalf("unexpected targets %v, want %v", targets, want)
+	}
+	if root := moduleRoot(filepath.Join("nested", "sub")); filepath.Base(root) != "nested" {
+		t.Fatalf("unexpected module root %q", root)
+	}
+}
+
+func TestExpandTargetsGitignore(t *testing.T) {
+	tmp := t.TempDir()
+	for _, dir := range []string{".git", "build", filepath.Join("node_modules", "pkg"), filepath.Join("pkg", "gen"), filepath.Join("pkg", "keep")} {
+		if err := os.MkdirAll(filepath.Join(tmp, dir), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	writeFile(t, filepath.Join(tmp, ".gitignore"), "# output\n/build/\nnode_modules\n")
+	writeFile(t, filepath.Join(tmp, "pkg", ".gitignore"), "*\n!keep\n")
+
+	withWorkingDir(t, tmp)
+
+	targets, err := expandTargets([]string{"./..."}, options{})
+	if err != nil {
+		t.Fatalf("expandTargets returned error: %v", err)
+	}
+	want := []string{".", "pkg", filepath.Join("pkg", "keep")}
+	if !reflect.DeepEqual(targets, want) {
+		t.Fatalf("unexpected targets %v, want %v", targets, want)
+	}
+
+	targets, err = expandTargets([]string{"./pkg/..."}, options{})
+	if err != nil {
+		t.Fatalf("expandTargets returned error: %v", err)
+	}
+	want = []string{"pkg", filepath.Join("pkg", "keep")}
+	if !reflect.DeepEqual(targets, want) {
+		t.Fatalf("unexpected targets %v, want %v", targets, want)
+	}
+
+	targets, err = expandTargets([]string{"./..."}, options{noGitignore: true})
+	if err != nil {
+		t.Fatalf("expandTargets returned error: %v", err)
+	}
+	if len(targets) != 8 {
+		t.Fatalf("expected every directory without gitignore filtering, got %v", targets)
+	}
+}
+
+func TestExpandTargetsSymlinks(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmp, "shared", "sub"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(tmp, "svc"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.Symlink(filepath.Join(tmp, "shared"), filepath.Join(tmp, "svc", "lib")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	// A link back to an ancestor must not make the walk loop forever.
+	if err := os.Symlink(tmp, filepath.Join(tmp, "shared", "sub", "loop")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+
+	withWorkingDir(t, tmp)
+
+	targets, err := expandTargets([]string{"./..."}, options{})
+	if err != nil {
+		t.Fatalf("expandTargets returned error: %v", err)
+	}
+	want := []string{".", "shared", filepath.Join("shared", "sub"), "svc"}
+	if !reflect.DeepEqual(targets, want) {
+		t.Fatalf("unexpected targets %v, want %v", targets, want)
+	}
+
+	targets, err = expandTargets([]string{"./svc/..."}, options{followSymlinks: true})
+	if err != nil {
+		t.Fatalf("expandTargets returned error: %v", err)
+	}
+	want = []string{"svc", filepath.Join("svc", "lib"), filepath.Join("svc", "lib", "sub"), filepath.Join("svc", "lib", "sub", "loop")}
+	if !reflect.DeepEqual(targets, want) {
+		t.Fatalf("unexpected targets %v, want %v", targets, want)
+	}
+
+	targets, err = expandTargets([]string{"./..."}, options{followSymlinks: true})
+	if err != nil {
+		t.Fatalf("expandTargets returned error: %v", err)
+	}
+	want = []string{".", "shared", filepath.Join("shared", "sub"), "svc"}
+	if !reflect.DeepEqual(targets, want) {
+		t.Fatalf("unexpected targets %v, want %v", targets, want)
+	}
+}
+
+func TestNormalizePathWindows(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: `.\pkg\...`, want: "pkg/..."},
+		{path: `C:\src\pkg\`, want: "C:/src/pkg"},
+		{path: `C:\`, want: "C:/"},
+		{path: `C:pkg\...`, want: "C:pkg/..."},
+		{path: `C:.\pkg`, want: "C:pkg"},
+		{path: `C:.`, want: "C:"},
+		{path: `\\server\share\...`, want: "//server/share/..."},
+		{path: `\\server\share\`, want: "//server/share/"},
+		{path: `\\server\share`, want: "//server/share"},
+		{path: `\\server\share\dir\`, want: "//server/share/dir"},
+	}
+	for _, tc := range tests {
+		if got := normalizePath(tc.path, true); got != tc.want {
+			t.Errorf("normalizePath(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestCompilePatternWindows(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{pattern: `C:\Src\...`, path: `c:\src\pkg`, want: true},
+		{pattern: `\\server\share\...`, path: `\\SERVER\share\pkg\sub`, want: true},
+		{pattern: `\\server\share\pkg\...`, path: `\\server\other\pkg`, want: false},
+		{pattern: `C:...`, path: `C:.`, want: true},
+		{pattern: `C:...`, path: `C:pkg\sub`, want: true},
+		{pattern: `.\Pkg\...`, path: `pkg`, want: false},
+		{pattern: `.\Pkg\...`, path: `pkg\sub`, want: true},
+	}
+	for _, tc := range tests {
+		re, err := compilePatternOS(tc.pattern, true)
+		if err != nil {
+			t.Fatalf("compilePatternOS(%q) returned error: %v", tc.pattern, err)
+		}
+		if got := re.MatchString(normalizePath(tc.path, true)); got != tc.want {
+			t.Errorf("pattern %q matching %q = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestInspectDirTests(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "p.go"), "package p\n")
+	writeFile(t, filepath.Join(tmp, "p_test.go"), `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`)
+	writeFile(t, filepath.Join(tmp, "x_test.go"), `package p_test
+
+func g() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`)
+
+	count, err := inspectDir(tmp, options{})
+	if err != nil {
+		t.Fatalf("inspectDir returned error: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected 0 issues without -tests, got %d", count)
+	}
+
+	count, err = inspectDir(tmp, options{tests: true})
+	if err != nil {
+		t.Fatalf("inspectDir returned error: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 issues with -tests, got %d", count)
+	}
+}
+
+func TestListExportsExternalTest(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/m\n\ngo 1.22\n")
+	writeFile(t, filepath.Join(tmp, "p.go"), "package m\n\nfunc key() string { return \"a\" }\n")
+	writeFile(t, filepath.Join(tmp, "export_test.go"), "package m\n\nvar Key = key\n")
+	writeFile(t, filepath.Join(tmp, "m_test.go"), "package m_test\n\nimport \"example.com/m\"\n\nvar _ = m.Key\n")
+
+	plain, err := listExports(&build.Default, tmp, options{})
+	if err != nil {
+		t.Fatalf("listExports: %v", err)
+	}
+	tests, err := listExports(&build.Default, tmp, options{tests: true})
+	if err != nil {
+		t.Fatalf("listExports -test: %v", err)
+	}
+	// The external test package imports the package compiled with its
+	// _test.go files, which declare Key.
+	if tests["example.com/m"] == "" || tests["example.com/m"] == plain["example.com/m"] {
+		t.Fatalf("external tests import %q, want the test variant of %q", tests["example.com/m"], plain["example.com/m"])
+	}
+	imp := newImporter(token.NewFileSet(), tests)
+	pkg, err := imp.Import("example.com/m")
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if pkg.Scope().Lookup("Key") == nil {
+		t.Error("test variant does not declare Key")
+	}
+}
+
+func TestInspectDirParseError(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "good.go"), `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`)
+	writeFile(t, filepath.Join(tmp, "broken.go"), `package p
+
+func g() {
+	if {
+}
+`)
+
+	count, err := inspectDir(tmp, options{})
+	if err == nil || !strings.Contains(err.Error(), "broken.go") {
+		t.Fatalf("expected error reporting broken.go, got %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 issue from the remaining files, got %d", count)
+	}
+}
+
+func TestMissingImport(t *testing.T) {
+	t.Parallel()
+
+	path, ok := missingImport(types.Error{Msg: "could not import example.com/dep (no required module provides package)"})
+	if !ok || path != "example.com/dep" {
+		t.Fatalf("missingImport returned %q, %v", path, ok)
+	}
+	if _, ok := missingImport(types.Error{Msg: "undefined: x"}); ok {
+		t.Fatalf("unexpected missing import for unrelated error")
+	}
+}
+
+func TestInspectDirGOOS(t *testing.T) {
+	tmp := t.TempDir()
+	src := `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`
+	writeFile(t, filepath.Join(tmp, "p_linux.go"), src)
+	writeFile(t, filepath.Join(tmp, "p_windows.go"), src)
+	writeFile(t, filepath.Join(tmp, "shared.go"), `package p
+
+func g() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`)
+
+	count, err := inspectDir(tmp, options{goos: stringList{"linux"}})
+	if err != nil {
+		t.Fatalf("inspectDir returned error: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected 2 issues for linux, got %d", count)
+	}
+
+	count, err = inspectDir(tmp, options{goos: stringList{"linux", "windows"}})
+	if err != nil {
+		t.Fatalf("inspectDir returned error: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 merged issues for linux and windows, got %d", count)
+	}
+}
+
+func TestInspectDirVendor(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not available")
+	}
+	tmp := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmp, "vendor", "example.com", "dep"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.24\n\nrequire example.com/dep v1.0.0\n")
+	writeFile(t, filepath.Join(tmp, "vendor", "modules.txt"), "# example.com/dep v1.0.0\n## explicit; go 1.24\nexample.com/dep\n")
+	writeFile(t, filepath.Join(tmp, "vendor", "example.com", "dep", "dep.go"), `package dep
+
+type Set map[string]bool
+
+func New() Set { return Set{} }
+`)
+	writeFile(t, filepath.Join(tmp, "app.go"), `package app
+
+import "example.com/dep"
+
+func f() {
+	set := dep.New()
+	set["a"] = true
+}
+`)
+
+	t.Setenv("GOFLAGS", "-mod=mod")
+	count, err := inspectDir(tmp, options{mod: "vendor"})
+	if err != nil {
+		t.Fatalf("inspectDir returned error: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 issue with -mod=vendor, got %d", count)
+	}
+
+	t.Setenv("GOFLAGS", "-mod=vendor")
+	count, err = inspectDir(tmp, options{})
+	if err != nil {
+		t.Fatalf("inspectDir returned error: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 issue with GOFLAGS=-mod=vendor, got %d", count)
+	}
+}
+
+func TestInspectDirCache(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "p.go")
+	writeFile(t, src, `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`)
+	opts := options{cache: &resultCache{dir: t.TempDir()}}
+
+	count, err := inspectDir(tmp, opts)
+	if err != nil {
+		t.Fatalf("inspectDir returned error: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 issue, got %d", count)
+	}
+
+	entries, err := filepath.Glob(filepath.Join(opts.cache.dir, "*", "*"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one cache entry, got %v (%v)", entries, err)
+	}
+	// A doctored entry proves the second run is served from the cache.
+	writeFile(t, entries[0], "[]")
+	count, err = inspectDir(tmp, opts)
+	if err != nil {
+		t.Fatalf("inspectDir returned error: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("expected cached result with 0 issues, got %d", count)
+	}
+
+	writeFile(t, src, `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+	set["b"] = true
+}
+`)
+	count, err = inspectDir(tmp, opts)
+	if err != nil {
+		t.Fatalf("inspectDir returned error: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected edited source to invalidate the cache, got %d issue(s)", count)
+	}
+	// Cached findings keep the details the JSON format prints.
+	found, err := collectDir(tmp, opts)
+	if err != nil || len(found) != 1 || found[0].keyType != "string" || found[0].writes != 2 {
+		t.Fatalf("cached findings = %+v, %v; want one with string keys and 2 writes", found, err)
+	}
+}
+
+func TestInspectDirCacheDependencies(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/m\n\ngo 1.22\n")
+	for _, pkg := range []string{"a", "b", "c"} {
+		if err := os.Mkdir(filepath.Join(tmp, pkg), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(tmp, "a", "a.go"), `package a
+
+import "example.com/m/b"
+
+func f() {
+	set := map[string]bool{}
+	set[b.Key] = true
+}
+`)
+	writeFile(t, filepath.Join(tmp, "b", "b.go"), "package b\n\nimport \"example.com/m/c\"\n\nconst Key = c.Key\n")
+	writeFile(t, filepath.Join(tmp, "c", "c.go"), "package c\n\nconst Key = \"a\"\n")
+	opts := options{cache: &resultCache{dir: t.TempDir()}}
+	dir := filepath.Join(tmp, "a")
+
+	if count, err := inspectDir(dir, opts); err != nil || count != 1 {
+		t.Fatalf("inspectDir = %d, %v; want 1 issue", count, err)
+	}
+	entries, err := filepath.Glob(filepath.Join(opts.cache.dir, "*", "*"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one cache entry, got %v (%v)", entries, err)
+	}
+	writeFile(t, entries[0], "[]")
+	if count, err := inspectDir(dir, opts); err != nil || count != 0 {
+		t.Fatalf("inspectDir = %d, %v; want the doctored cache entry", count, err)
+	}
+
+	// An edit to an indirect dependency in the module misses the cache.
+	writeFile(t, filepath.Join(tmp, "c", "c.go"), "package c\n\nconst Key = \"b\"\n")
+	if count, err := inspectDir(dir, opts); err != nil || count != 1 {
+		t.Fatalf("inspectDir = %d, %v; want the dependency edit to invalidate the cache", count, err)
+	}
+}
+
+func TestWriteDepsVendor(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/m\n\ngo 1.22\n\nrequire example.com/dep v1.0.0\n")
+	for _, dir := range []string{"dep", "indirect"} {
+		if err := os.MkdirAll(filepath.Join(tmp, "vendor", "example.com", dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(tmp, "vendor", "modules.txt"), "# example.com/dep v1.0.0\n## explicit; go 1.22\nexample.com/dep\nexample.com/indirect\n")
+	writeFile(t, filepath.Join(tmp, "vendor", "example.com", "dep", "dep.go"), "package dep\n\nimport \"example.com/indirect\"\n\nconst Key = indirect.Key\n")
+	indirect := filepath.Join(tmp, "vendor", "example.com", "indirect", "indirect.go")
+	writeFile(t, indirect, "package indirect\n\nconst Key = \"a\"\n")
+
+	sum := func() string {
+		t.Helper()
+		h := sha256.New()
+		if err := writeDeps(h, tmp, []string{"example.com/dep"}, options{}); err != nil {
+			t.Fatalf("writeDeps: %v", err)
+		}
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	before := sum()
+	// Vendored sources edited in place change the key like local ones.
+	writeFile(t, indirect, "package indirect\n\nconst Key = \"b\"\n")
+	if sum() == before {
+		t.Error("editing a vendored dependency left the key unchanged")
+	}
+}
+
+func TestWatchDirs(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmp, "pkg"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, filepath.Join(tmp, "pkg", "a.go"), "package pkg\n")
+
+	got := watchDirs([]string{tmp, filepath.Join(tmp, "pkg", "a.go"), filepath.Join(tmp, "pkg")})
+	want := []string{tmp, filepath.Join(tmp, "pkg")}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected watch dirs %v, want %v", got, want)
+	}
+}
+
+func TestDependents(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/m\n\ngo 1.22\n")
+	for _, pkg := range []string{"a", "b", "c", "d"} {
+		if err := os.Mkdir(filepath.Join(tmp, pkg), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile(t, filepath.Join(tmp, "a", "a.go"), "package a\n\nimport _ \"example.com/m/b\"\n")
+	writeFile(t, filepath.Join(tmp, "b", "b.go"), "package b\n\nimport _ \"example.com/m/c\"\n")
+	writeFile(t, filepath.Join(tmp, "c", "c.go"), "package c\n")
+	writeFile(t, filepath.Join(tmp, "d", "d.go"), "package d\n")
+	writeFile(t, filepath.Join(tmp, "d", "d_test.go"), "package d\n\nimport _ \"example.com/m/c\"\n")
+	dir := func(pkg string) string { return filepath.Join(tmp, pkg) }
+	targets := []string{dir("a"), dir("b"), dir("c"), dir("d")}
+
+	// An edit to c re-analyses the packages importing it, directly or not.
+	if got, want := dependents(targets, []string{dir("c")}, false), []string{dir("a"), dir("b"), dir("c")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dependents = %q, want %q", got, want)
+	}
+	if got, want := dependents(targets, []string{dir("c")}, true), []string{dir("a"), dir("b"), dir("c"), dir("d")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dependents with tests = %q, want %q", got, want)
+	}
+	if got, want := dependents(targets, []string{dir("a")}, true), []string{dir("a")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dependents of a = %q, want %q", got, want)
+	}
+}
+
+func TestServe(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmp, "pkg"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, filepath.Join(tmp, "pkg", "p.go"), `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`)
+
+	ln, err := net.Listen("unix", filepath.Join(tmp, "boolsetlint.sock"))
+	if err != nil {
+		t.Skipf("unix sockets unsupported: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	opts := options{cache: &resultCache{memory: make(map[string][]finding)}}
+	go (&server{opts: opts}).serve(ln)
+
+	conn, err := net.Dial("unix", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+
+	// The second request is answered from the warm in-memory cache.
+	for i := 0; i < 2; i++ {
+		if err := enc.Encode(serveRequest{Dir: tmp, Patterns: []string{"./..."}}); err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		var resp serveResponse
+		if err := dec.Decode(&resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if len(resp.Errors) != 0 {
+			t.Fatalf("unexpected errors %v", resp.Errors)
+		}
+		if len(resp.Findings) != 1 || resp.Findings[0].Line != 4 || resp.Findings[0].Rule != boolset.RuleMapBoolSet || filepath.Base(resp.Findings[0].File) != "p.go" {
+			t.Fatalf("unexpected findings %+v", resp.Findings)
+		}
+	}
+	if len(opts.cache.memory) != 1 {
+		t.Fatalf("expected one warm cache entry, got %d", len(opts.cache.memory))
+	}
+}
+
+func TestTypeCache(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/m\n\ngo 1.22\n")
+	src := filepath.Join(tmp, "p.go")
+	writeFile(t, src, "package p\n\nimport \"strings\"\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[strings.ToLower(\"A\")] = true\n}\n")
+	opts := withMemoryCache(options{out: io.Discard})
+
+	warm := func() *warmPackage {
+		t.Helper()
+		if count, err := inspectDir(tmp, opts); err != nil || count != 1 {
+			t.Fatalf("inspectDir = %d, %v; want 1 issue", count, err)
+		}
+		if len(opts.types.pkgs) != 1 {
+			t.Fatalf("type cache holds %d packages, want 1", len(opts.types.pkgs))
+		}
+		for _, w := range opts.types.pkgs {
+			return w
+		}
+		return nil
+	}
+	first := warm()
+	checked := first.checked
+
+	// Results for unchanged files come from the result cache.
+	if w := warm(); w.checked != checked {
+		t.Error("unchanged package was type-checked again")
+	}
+
+	// An edit re-checks the package, in a FileSet of its own, with the
+	// export data already listed.
+	writeFile(t, src, "package p\n\nimport \"strings\"\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[strings.ToUpper(\"a\")] = true\n}\n")
+	w := warm()
+	if w.checked == checked {
+		t.Error("edited package was not type-checked again")
+	}
+	if w != first {
+		t.Error("edited package did not reuse the warm dependencies")
+	}
+	if w.checked.fset == checked.fset {
+		t.Error("edited package was parsed into the previous FileSet")
+	}
+
+	// Concurrent requests for a new version share the entry.
+	writeFile(t, src, "package p\n\nimport \"strings\"\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[strings.TrimSpace(\"a\")] = true\n}\n")
+	opts.cache.memory = make(map[string][]finding)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if count, err := inspectDir(tmp, opts); err != nil || count != 1 {
+				t.Errorf("inspectDir = %d, %v; want 1 issue", count, err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if got, want := defaultSocket(), "/run/user/1000/boolsetlint.sock"; got != filepath.FromSlash(want) {
+		t.Errorf("defaultSocket() = %q, want %q", got, want)
+	}
+
+	tmp := t.TempDir()
+	file := filepath.Join(tmp, "file")
+	writeFile(t, file, "")
+	if err := removeStaleSocket(file); err == nil {
+		t.Error("removeStaleSocket removed a regular file")
+	}
+	if err := removeStaleSocket(filepath.Join(tmp, "missing")); err != nil {
+		t.Errorf("removeStaleSocket(missing) = %v", err)
+	}
+
+	socket := filepath.Join(tmp, "s.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unsupported: %v", err)
+	}
+	if err := removeStaleSocket(socket); err == nil {
+		t.Error("removeStaleSocket removed the socket of a running daemon")
+	}
+	// Closing a unix listener unlinks the socket; leave the file behind as
+	// a crashed daemon would.
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	if err := removeStaleSocket(socket); err != nil {
+		t.Fatalf("removeStaleSocket(stale) = %v", err)
+	}
+	if _, err := os.Lstat(socket); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("stale socket left behind: %v", err)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "p.go"), "package p\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[\"a\"] = true\n}\n")
+
+	s := &server{opts: options{cache: &resultCache{memory: make(map[string][]finding)}}, last: serveResponse{Findings: []serveFinding{}}}
+	ts := httptest.NewServer(s.httpHandler())
+	defer ts.Close()
+
+	get := func(path string) (int, serveResponse) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var body serveResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return resp.StatusCode, body
+	}
+
+	if code, body := get("/findings"); code != http.StatusOK || body.Findings == nil || len(body.Findings) != 0 {
+		t.Fatalf("GET /findings before any request = %d %+v, want 200 with no findings", code, body)
+	}
+	if code, _ := get("/analyze"); code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET /analyze = %d, want 405", code)
+	}
+	resp, err := http.Post(ts.URL+"/analyze", "application/json", strings.NewReader("{"))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("POST /analyze with invalid JSON = %d, want 400", resp.StatusCode)
+	}
+
+	req, _ := json.Marshal(serveRequest{Dir: tmp})
+	resp, err = http.Post(ts.URL+"/analyze", "application/json", bytes.NewReader(req))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	var analyzed serveResponse
+	err = json.NewDecoder(resp.Body).Decode(&analyzed)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(analyzed.Findings) != 1 || analyzed.Findings[0].Line != 4 || len(analyzed.Errors) != 0 {
+		t.Fatalf("unexpected response %+v", analyzed)
+	}
+	if _, body := get("/findings"); !reflect.DeepEqual(body, analyzed) {
+		t.Fatalf("GET /findings = %+v, want the last response %+v", body, analyzed)
+	}
+
+	// Requests a web page can make the browser send are refused.
+	for _, tc := range []struct {
+		name        string
+		contentType string
+		host        string
+		origin      string
+		want        int
+	}{
+		{"simple POST", "text/plain", "", "", http.StatusUnsupportedMediaType},
+		{"rebound host", "application/json", "evil.example:80", "", http.StatusForbidden},
+		{"foreign origin", "application/json", "", "https://evil.example", http.StatusForbidden},
+		{"loopback origin", "application/json", "localhost", "http://localhost:3000", http.StatusOK},
+	} {
+		r, _ := http.NewRequest(http.MethodPost, ts.URL+"/analyze", bytes.NewReader(req))
+		r.Header.Set("Content-Type", tc.contentType)
+		if tc.host != "" {
+			r.Host = tc.host
+		}
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		resp, err := http.DefaultClient.Do(r)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Errorf("%s: POST /analyze = %d, want %d", tc.name, resp.StatusCode, tc.want)
+		}
+	}
+}
+
+func TestLoopbackAddr(t *testing.T) {
+	for addr, want := range map[string]string{
+		":7878":          "127.0.0.1:7878",
+		"localhost:7878": "localhost:7878",
+		"[::1]:7878":     "[::1]:7878",
+		"0.0.0.0:7878":   "",
+		"example.com:80": "",
+	} {
+		got, err := loopbackAddr(addr)
+		if got != want || (err != nil) != (want == "") {
+			t.Errorf("loopbackAddr(%q) = %q, %v; want %q", addr, got, err, want)
+		}
+	}
+}
+
+func TestLSP(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "p.go")
+	writeFile(t, src, `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`)
+	uri := pathToURI(src)
+
+	var in bytes.Buffer
+	for _, msg := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"` + uri + `"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/codeAction","params":{"textDocument":{"uri":"` + uri + `"},"range":{"start":{"line":3,"character":0},"end":{"line":3,"character":0}}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	} {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+
+	var out bytes.Buffer
+	if code := newLSPServer(&in, &out, options{}).run(); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	client := newLSPServer(&out, io.Discard, options{})
+	var messages []lspRequest
+	var results []json.RawMessage
+	for {
+		body, err := readLSPBody(client)
+		if err != nil {
+			break
+		}
+		var msg struct {
+			lspRequest
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		messages = append(messages, msg.lspRequest)
+		results = append(results, msg.Result)
+	}
+	if len(messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(messages))
+	}
+
+	var published struct {
+		URI         string          `json:"uri"`
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	}
+	if messages[1].Method != "textDocument/publishDiagnostics" {
+		t.Fatalf("expected diagnostics notification, got %q", messages[1].Method)
+	}
+	if err := json.Unmarshal(messages[1].Params, &published); err != nil {
+		t.Fatalf("unmarshal diagnostics: %v", err)
+	}
+	if published.URI != uri || len(published.Diagnostics) != 1 || published.Diagnostics[0].Range.Start.Line != 3 || published.Diagnostics[0].Range.End.Character <= published.Diagnostics[0].Range.Start.Character {
+		t.Fatalf("unexpected diagnostics %+v", published)
+	}
+
+	var actions []lspCodeAction
+	if err := json.Unmarshal(results[2], &actions); err != nil {
+		t.Fatalf("unmarshal code actions: %v", err)
+	}
+	if len(actions) != 1 || len(actions[0].Edit.Changes[uri]) != 2 {
+		t.Fatalf("unexpected code actions %+v", actions)
+	}
+}
+
+func TestLSPPositionEncoding(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "p.go")
+	writeFile(t, src, "package p\n\nfunc f() {\n\t/* é😀 */ set := map[string]bool{}\n\tset[\"a\"] = true\n}\n")
+	uri := pathToURI(src)
+
+	for _, tc := range []struct {
+		params   string
+		encoding string
+		column   int
+	}{
+		{`{}`, "utf-16", 11},
+		{`{"capabilities":{"general":{"positionEncodings":["utf-16"]}}}`, "utf-16", 11},
+		{`{"capabilities":{"general":{"positionEncodings":["utf-32","utf-8"]}}}`, "utf-8", 14},
+	} {
+		var in bytes.Buffer
+		for _, msg := range []string{
+			`{"jsonrpc":"2.0","id":1,"method":"initialize","params":` + tc.params + `}`,
+			`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"` + uri + `"}}}`,
+			`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+			`{"jsonrpc":"2.0","method":"exit"}`,
+		} {
+			fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+		}
+		var out bytes.Buffer
+		if code := newLSPServer(&in, &out, options{}).run(); code != 0 {
+			t.Fatalf("%s: exit code %d", tc.params, code)
+		}
+
+		client := newLSPServer(&out, io.Discard, options{})
+		var initialized struct {
+			Result struct {
+				Capabilities struct {
+					PositionEncoding string `json:"positionEncoding"`
+				} `json:"capabilities"`
+			} `json:"result"`
+		}
+		var published struct {
+			Params struct {
+				Diagnostics []lspDiagnostic `json:"diagnostics"`
+			} `json:"params"`
+		}
+		for _, v := range []any{&initialized, &published} {
+			body, err := readLSPBody(client)
+			if err != nil {
+				t.Fatalf("%s: read: %v", tc.params, err)
+			}
+			if err := json.Unmarshal(body, v); err != nil {
+				t.Fatalf("%s: unmarshal: %v", tc.params, err)
+			}
+		}
+		if got := initialized.Result.Capabilities.PositionEncoding; got != tc.encoding {
+			t.Errorf("%s: positionEncoding = %q, want %q", tc.params, got, tc.encoding)
+		}
+		if diags := published.Params.Diagnostics; len(diags) != 1 || diags[0].Range.Start != (lspPosition{Line: 3, Character: tc.column}) {
+			t.Errorf("%s: diagnostics = %+v, want one at 3:%d", tc.params, diags, tc.column)
+		}
+	}
+}
+
+func TestLSPUnsavedChanges(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "p.go")
+	writeFile(t, src, `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`)
+	uri := pathToURI(src)
+	doc := `"textDocument":{"uri":"` + uri + `"}`
+	codeAction := `"method":"textDocument/codeAction","params":{` + doc + `,"range":{"start":{"line":3,"character":0},"end":{"line":3,"character":0}}}}`
+
+	var in bytes.Buffer
+	for _, msg := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{` + doc + `}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didChange","params":{` + doc + `,"contentChanges":[{"text":"package p\n"}]}}`,
+		`{"jsonrpc":"2.0","id":2,` + codeAction,
+		`{"jsonrpc":"2.0","method":"textDocument/didSave","params":{` + doc + `}}`,
+		`{"jsonrpc":"2.0","id":3,` + codeAction,
+		`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	} {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(msg), msg)
+	}
+	var out bytes.Buffer
+	if code := newLSPServer(&in, &out, options{}).run(); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	client := newLSPServer(&out, io.Discard, options{})
+	var got []string
+	for {
+		body, err := readLSPBody(client)
+		if err != nil {
+			break
+		}
+		var msg struct {
+			Method string `json:"method"`
+			Params struct {
+				Diagnostics []lspDiagnostic `json:"diagnostics"`
+			} `json:"params"`
+			Result json.RawMessage `json:"result"`
+		}
+		if err := json.Unmarshal(body, &msg); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		switch {
+		case msg.Method != "":
+			got = append(got, fmt.Sprintf("diagnostics %d", len(msg.Params.Diagnostics)))
+		case bytes.HasPrefix(msg.Result, []byte("[")):
+			var actions []lspCodeAction
+			if err := json.Unmarshal(msg.Result, &actions); err != nil {
+				t.Fatalf("unmarshal code actions: %v", err)
+			}
+			got = append(got, fmt.Sprintf("actions %d", len(actions)))
+		}
+	}
+	// The edited buffer gets neither diagnostics nor fixes until saved.
+	want := []string{"diagnostics 1", "diagnostics 0", "actions 0", "diagnostics 1", "actions 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestLSPContentLength(t *testing.T) {
+	for _, length := range []string{"-1", strconv.Itoa(lspMaxMessage + 1)} {
+		in := strings.NewReader("Content-Length: " + length + "\r\n\r\n{}")
+		if _, err := newLSPServer(in, io.Discard, options{}).read(); err == nil {
+			t.Errorf("Content-Length %s: expected an error", length)
+		}
+	}
+}
+
+func readLSPBody(s *lspServer) ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, err
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(s.in, body)
+	return body, err
+}
+
+func TestList(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmp, "empty"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, filepath.Join(tmp, "p.go"), "package p\n")
+	writeFile(t, filepath.Join(tmp, "p_windows.go"), "package p\n")
+	writeFile(t, filepath.Join(tmp, "p_test.go"), "package p\n")
+
+	targets := []string{tmp, filepath.Join(tmp, "empty")}
+	var out bytes.Buffer
+	if err := list(&out, targets, options{goos: stringList{"linux"}}, true); err != nil {
+		t.Fatalf("list returned error: %v", err)
+	}
+	if want := filepath.Join(tmp, "p.go") + "\n"; out.String() != want {
+		t.Fatalf("unexpected file list %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := list(&out, targets, options{tests: true, goos: stringList{"linux", "windows"}}, true); err != nil {
+		t.Fatalf("list returned error: %v", err)
+	}
+	want := filepath.Join(tmp, "p.go") + "\n" + filepath.Join(tmp, "p_test.go") + "\n" + filepath.Join(tmp, "p_windows.go") + "\n"
+	if out.String() != want {
+		t.Fatalf("unexpected file list %q, want %q", out.String(), want)
+	}
+
+	out.Reset()
+	if err := list(&out, targets, options{}, false); err != nil {
+		t.Fatalf("list returned error: %v", err)
+	}
+	if want := tmp + "\n"; out.String() != want {
+		t.Fatalf("unexpected package list %q, want %q", out.String(), want)
+	}
+}
+
+func TestRunDeterministicOrder(t *testing.T) {
+	tmp := t.TempDir()
+	src := `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`
+	var targets []string
+	for _, name := range []string{"c", "a", "d", "b"} {
+		dir := filepath.Join(tmp, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		writeFile(t, filepath.Join(dir, "z.go"), src)
+		writeFile(t, filepath.Join(dir, "y.go"), src)
+		targets = append(targets, dir)
+	}
+
+	var want string
+	for i := 0; i < 5; i++ {
+		var out bytes.Buffer
+		count, hadError := run(context.Background(), targets, options{parallel: 4, out: &out})
+		if hadError || count != 8 {
+			t.Fatalf("run returned %d issues, error %v", count, hadError)
+		}
+		if i == 0 {
+			want = out.String()
+			continue
+		}
+		if out.String() != want {
+			t.Fatalf("output differs between runs:\n%s\nvs\n%s", out.String(), want)
+		}
+	}
+
+	lines := strings.Split(strings.TrimSpace(want), "\n")
+	var got []string
+	for _, line := range lines[:len(lines)-1] {
+		file, _, _ := strings.Cut(line, ":")
+		rel, err := filepath.Rel(tmp, file)
+		if err != nil {
+			t.Fatalf("rel: %v", err)
+		}
+		got = append(got, filepath.ToSlash(rel))
+	}
+	wantFiles := []string{"a/y.go", "a/z.go", "b/y.go", "b/z.go", "c/y.go", "c/z.go", "d/y.go", "d/z.go"}
+	if !reflect.DeepEqual(got, wantFiles) {
+		t.Fatalf("unexpected output order %v, want %v", got, wantFiles)
+	}
+}
+
+// eventReporter records the calls boolsetlint makes to a reporter.
+type eventReporter struct {
+	events *[]string
+}
+
+func (r eventReporter) Begin() error { *r.events = append(*r.events, "begin"); return nil }
+
+func (r eventReporter) Report(f boolset.PositionedFinding) error {
+	*r.events = append(*r.events, fmt.Sprintf("%s:%d %s %s", filepath.Base(f.Position.Filename), f.Position.Line, f.Rule, f.Name))
+	return nil
+}
+
+func (r eventReporter) End() error { *r.events = append(*r.events, "end"); return nil }
+
+func TestRunFormat(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/p\n\ngo 1.22\n")
+	writeFile(t, filepath.Join(tmp, "p.go"), "package p\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[\"a\"] = true\n}\n")
+
+	var out bytes.Buffer
+	if count, hadError := run(context.Background(), []string{tmp}, options{out: &out, format: "json"}); count != 1 || hadError {
+		t.Fatalf("run = %d, %v; want 1 issue", count, hadError)
+	}
+	line, summary, _ := strings.Cut(out.String(), "\n")
+	var got map[string]any
+	if err := json.Unmarshal([]byte(line), &got); err != nil {
+		t.Fatalf("first line %q is not JSON: %v", line, err)
+	}
+	if got["rule"] != boolset.RuleMapBoolSet || got["name"] != "set" || got["severity"] != "warning" || got["line"] != 4.0 {
+		t.Errorf("JSON finding = %v", got)
+	}
+	if got["package"] != "example.com/p" || got["keyType"] != "string" || got["elemType"] != "bool" || got["writes"] != 1.0 || got["reads"] != 0.0 {
+		t.Errorf("JSON finding details = %v", got)
+	}
+	if summary != "boolsetlint found 1 issue(s)\n" {
+		t.Errorf("after the finding: %q", summary)
+	}
+
+	var events []string
+	boolset.RegisterReporter("events-test", func(io.Writer) boolset.Reporter { return eventReporter{&events} })
+	var format formatFlag
+	if err := format.Set("events-test"); err != nil {
+		t.Fatal(err)
+	}
+	run(context.Background(), []string{tmp}, options{out: io.Discard, format: string(format)})
+	if want := []string{"begin", "p.go:4 BS0001 set", "end"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("reporter calls = %q, want %q", events, want)
+	}
+	if err := format.Set("xml"); err == nil {
+		t.Error("-format accepted an unknown reporter")
+	}
+}
+
+func TestRunCancelled(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "p.go"), "package p\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[\"a\"] = true\n}\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var out bytes.Buffer
+	count, hadError := run(ctx, []string{tmp}, options{parallel: 1, out: &out})
+	if count != 0 || !hadError {
+		t.Fatalf("run = %d, %v; want no issues and a failed run", count, hadError)
+	}
+	if !strings.Contains(out.String(), "interrupted") {
+		t.Fatalf("output %q does not report the interruption", out.String())
+	}
+}
+
+func TestFixCommand(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "p.go"), `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+	other := map[int]bool{}
+	other[1] = true
+	_ = other[1]
+}
+`)
+	withWorkingDir(t, tmp)
+
+	if code := runCommand([]string{"fix", "-no-cache", "."}); code != exitFindings {
+		t.Fatalf("fix exited with %d, want 1 for the unfixable map", code)
+	}
+	got, err := os.ReadFile(filepath.Join(tmp, "p.go"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := `package p
+
+func f() {
+	set := map[string]struct{}{}
+	set["a"] = struct{}{}
+	other := map[int]bool{}
+	other[1] = true
+	_ = other[1]
+}
+`
+	if string(got) != want {
+		t.Fatalf("unexpected fixed source:\n%s", got)
+	}
+}
+
+func TestBaselineCommand(t *testing.T) {
+	tmp := t.TempDir()
+	src := `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`
+	writeFile(t, filepath.Join(tmp, "p.go"), src)
+	withWorkingDir(t, tmp)
+
+	if code := runCommand([]string{"baseline", "-no-cache", "."}); code != exitOK {
+		t.Fatalf("baseline exited with %d", code)
+	}
+	if code := runCommand([]string{"lint", "-no-cache", "-baseline", defaultBaselineFile, "."}); code != exitOK {
+		t.Fatalf("lint with baseline exited with %d, want 0", code)
+	}
+
+	writeFile(t, filepath.Join(tmp, "q.go"), strings.Replace(src, "func f()", "func g()", 1))
+	if code := runCommand([]string{"lint", "-no-cache", "-baseline", defaultBaselineFile, "."}); code != exitFindings {
+		t.Fatalf("lint with baseline exited with %d, want 1 for the new finding", code)
+	}
+}
+
+func TestSARIFFingerprints(t *testing.T) {
+	tmp := t.TempDir()
+	src := `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+
+func g() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`
+	writeFile(t, filepath.Join(tmp, "p.go"), src)
+	withWorkingDir(t, tmp)
+
+	lint := func() (lines []int, fingerprints []string) {
+		t.Helper()
+		if code := runCommand([]string{"lint", "-no-cache", "-sarif", "out.sarif", "."}); code != exitFindings {
+			t.Fatalf("lint exited with %d, want 1", code)
+		}
+		data, err := os.ReadFile("out.sarif")
+		if err != nil {
+			t.Fatalf("read SARIF: %v", err)
+		}
+		var report sarifReport
+		if err := json.Unmarshal(data, &report); err != nil {
+			t.Fatalf("unmarshal SARIF: %v", err)
+		}
+		if len(report.Runs) != 1 || len(report.Runs[0].Results) != 2 {
+			t.Fatalf("unexpected SARIF log %s", data)
+		}
+		for _, r := range report.Runs[0].Results {
+			if r.RuleID != boolset.RuleMapBoolSet || r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "p.go" {
+				t.Fatalf("unexpected result %+v", r)
+			}
+			lines = append(lines, r.Locations[0].PhysicalLocation.Region.StartLine)
+			fingerprints = append(fingerprints, r.PartialFingerprints[sarifFingerprint])
+		}
+		return lines, fingerprints
+	}
+
+	lines, before := lint()
+	if before[0] == "" || before[0] == before[1] {
+		t.Fatalf("fingerprints %q should be set and distinct", before)
+	}
+	writeFile(t, filepath.Join(tmp, "p.go"), strings.Replace(src, "package p\n", "package p\n\nconst unrelated = 1\n", 1))
+	shifted, after := lint()
+	if shifted[0] == lines[0] {
+		t.Fatalf("lines %v did not shift", shifted)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Fatalf("fingerprints changed from %q to %q when lines shifted", before, after)
+	}
+}
+
+func TestSeverityLevels(t *testing.T) {
+	var findings []finding
+	for line, sev := range []boolset.Severity{boolset.SeverityInfo, boolset.SeverityWarning, boolset.SeverityError} {
+		findings = append(findings, finding{
+			pos:      token.Position{Filename: "p.go", Line: line + 1, Column: 1},
+			rule:     boolset.RuleMapBoolSet,
+			severity: sev,
+			message:  "m",
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "out.sarif")
+	if err := writeSARIF(path, findings); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report sarifReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	var levels []string
+	for _, r := range report.Runs[0].Results {
+		levels = append(levels, r.Level)
+	}
+	if want := []string{"note", "warning", "error"}; !reflect.DeepEqual(levels, want) {
+		t.Errorf("SARIF levels = %q, want %q", levels, want)
+	}
+
+	var severities []int
+	for _, f := range findings {
+		severities = append(severities, toLSPDiagnostic(f, toLSPPosition).Severity)
+	}
+	if want := []int{3, 2, 1}; !reflect.DeepEqual(severities, want) {
+		t.Errorf("LSP severities = %v, want %v", severities, want)
+	}
+}
+
+func TestMetricsOut(t *testing.T) {
+	tmp := t.TempDir()
+	src := "package p\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[\"a\"] = true\n}\n"
+	for _, dir := range []string{"a", "b", "clean"} {
+		if err := os.MkdirAll(filepath.Join(tmp, dir), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+	writeFile(t, filepath.Join(tmp, "a", "p.go"), src)
+	writeFile(t, filepath.Join(tmp, "a", "q.go"), strings.Replace(src, "func f()", "func g()", 1))
+	writeFile(t, filepath.Join(tmp, "b", "p.go"), src)
+	writeFile(t, filepath.Join(tmp, "clean", "p.go"), "package p\n")
+	withWorkingDir(t, tmp)
+
+	if code := runCommand([]string{"lint", "-no-cache", "-metrics-out", "boolset.prom", "./..."}); code != exitFindings {
+		t.Fatalf("lint exited with %d, want 1", code)
+	}
+	data, err := os.ReadFile("boolset.prom")
+	if err != nil {
+		t.Fatalf("read metrics: %v", err)
+	}
+	for _, want := range []string{
+		"# TYPE boolsetlint_findings gauge\n",
+		"boolsetlint_findings{rule=\"BS0001\"} 3\n",
+		"boolsetlint_package_findings{package=\"a\"} 2\n",
+		"boolsetlint_package_findings{package=\"b\"} 1\n",
+		"boolsetlint_duration_seconds ",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("metrics missing %q:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "clean") {
+		t.Errorf("metrics list a package without findings:\n%s", data)
+	}
+}
+
+func TestGitHubPRReview(t *testing.T) {
+	tmp := t.TempDir()
+	if err := os.Mkdir(filepath.Join(tmp, ".git"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(tmp, "pkg"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeFile(t, filepath.Join(tmp, "pkg", "p.go"), `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+
+func g() {
+	old := map[string]bool{}
+	old["a"] = true
+}
+`)
+	withWorkingDir(t, tmp)
+
+	var reviews []githubReview
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/o/r/pulls/7/files", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		// Only f is part of the pull request.
+		json.NewEncoder(w).Encode([]githubFile{{Filename: "pkg/p.go", Patch: "@@ -1,2 +1,7 @@\n package p\n \n+func f() {\n+\tset := map[string]bool{}\n+\tset[\"a\"] = true\n+}\n+"}})
+	})
+	mux.HandleFunc("GET /repos/o/r/pulls/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		var posted []githubComment
+		for _, review := range reviews {
+			posted = append(posted, review.Comments...)
+		}
+		json.NewEncoder(w).Encode(posted)
+	})
+	mux.HandleFunc("POST /repos/o/r/pulls/7/reviews", func(w http.ResponseWriter, r *http.Request) {
+		var review githubReview
+		if err := json.NewDecoder(r.Body).Decode(&review); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reviews = append(reviews, review)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	t.Setenv("GITHUB_API_URL", ts.URL)
+	t.Setenv("GITHUB_TOKEN", "secret")
+
+	// The second run finds its comment already posted.
+	for i := 0; i < 2; i++ {
+		if code := runCommand([]string{"lint", "-no-cache", "-github-pr", "o/r#7", "./..."}); code != exitFindings {
+			t.Fatalf("lint exited with %d, want 1", code)
+		}
+	}
+	if len(reviews) != 1 || len(reviews[0].Comments) != 1 {
+		t.Fatalf("reviews = %+v, want one review with one comment", reviews)
+	}
+	want := githubComment{
+		Path:      "pkg/p.go",
+		StartLine: 4,
+		StartSide: "RIGHT",
+		Line:      5,
+		Side:      "RIGHT",
+		Body:      "map[string]bool only stores \"true\" values; consider map[string]struct{} (BS0001)\n\n```suggestion\n\tset := map[string]struct{}{}\n\tset[\"a\"] = struct{}{}\n```",
+	}
+	if !reflect.DeepEqual(reviews[0].Comments[0], want) {
+		t.Fatalf("comment = %+v, want %+v", reviews[0].Comments[0], want)
+	}
+
+	t.Setenv("GITHUB_TOKEN", "")
+	if code := runCommand([]string{"lint", "-no-cache", "-github-pr", "o/r#7", "./..."}); code != exitUsage {
+		t.Fatalf("lint without a token exited with %d, want 2", code)
+	}
+	if code := runCommand([]string{"lint", "-github-pr", "o/r", "./..."}); code != exitUsage {
+		t.Fatalf("lint with a malformed pull request exited with %d, want 2", code)
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	tmp := t.TempDir()
+	for name, src := range map[string]string{
+		"clean/p.go":    "package p\n",
+		"findings/p.go": "package p\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[\"a\"] = true\n}\n",
+		"broken/p.go":   "package p\n\nfunc f( {\n",
+	} {
+		path := filepath.Join(tmp, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		writeFile(t, path, src)
+	}
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{[]string{"-no-cache", filepath.Join(tmp, "clean")}, exitOK},
+		{[]string{"-no-cache", filepath.Join(tmp, "findings")}, exitFindings},
+		{[]string{"-no-such-flag"}, exitUsage},
+		{[]string{"-no-cache", filepath.Join(tmp, "broken"), filepath.Join(tmp, "findings")}, exitFailure},
+	}
+	for _, tt := range tests {
+		if code := runCommand(tt.args); code != tt.want {
+			t.Errorf("boolsetlint %v exited with %d, want %d", tt.args, code, tt.want)
+		}
+	}
+}
+
+func TestAnalysisFlags(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "p.go"), `package p
+
+type S struct {
+	Tagged map[string]bool `+"`json:\"tagged\"`"+`
+}
+
+func f(s *S) {
+	s.Tagged["a"] = true
+}
+
+func g(keys []string) {
+	v := true
+	seen := map[string]bool{}
+	for _, k := range keys {
+		seen[k] = v
+	}
+}
+`)
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{nil, 1},
+		{[]string{"-include-serialized"}, 2},
+		{[]string{"-ssa", "-include-serialized"}, 2},
+	} {
+		var f sharedFlags
+		fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+		f.register(fs)
+		if err := fs.Parse(append(tc.args, "-no-cache", tmp)); err != nil {
+			t.Fatalf("%q: %v", tc.args, err)
+		}
+		findings, err := collectDir(tmp, f.opts)
+		if err != nil || len(findings) != tc.want {
+			t.Errorf("%q: collectDir = %d finding(s), %v; want %d", tc.args, len(findings), err, tc.want)
+		}
+	}
+}
+
+func TestExplainCommand(t *testing.T) {
+	if _, ok := findRule("bs0001"); !ok {
+		t.Fatalf("rule lookup should ignore case")
+	}
+	if code := runCommand([]string{"explain", "BS0001"}); code != exitOK {
+		t.Fatalf("explain exited with %d", code)
+	}
+	if code := runCommand([]string{"explain", "BS9999"}); code != exitUsage {
+		t.Fatalf("explain of an unknown rule exited with %d, want 2", code)
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	info := &debug.BuildInfo{
+		GoVersion: "go1.24.0",
+		Main:      debug.Module{Version: "v1.2.3"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "0123abc"},
+			{Key: "vcs.time", Value: "2025-01-02T03:04:05Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	want := "boolsetlint v1.2.3\nrevision: 0123abc (2025-01-02T03:04:05Z, modified)\ngo: go1.24.0\n"
+	if got := versionString(info); got != want {
+		t.Fatalf("unexpected version %q, want %q", got, want)
+	}
+
+	info = &debug.BuildInfo{GoVersion: "go1.24.0"}
+	if got, want := versionString(info), "boolsetlint (devel)\ngo: go1.24.0\n"; got != want {
+		t.Fatalf("unexpected version %q, want %q", got, want)
+	}
+}
+
+func TestPathModeDisplay(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/mod\n\ngo 1.24\n")
+	if err := os.MkdirAll(filepath.Join(tmp, "pkg"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	file := filepath.Join(tmp, "pkg", "p.go")
+	writeFile(t, file, "package pkg\n")
+	withWorkingDir(t, tmp)
+
+	tests := []struct {
+		mode pathMode
+		want string
+	}{
+		{"", file},
+		{pathAbs, file},
+		{pathRel, filepath.Join("pkg", "p.go")},
+		{pathModule, "example.com/mod/pkg/p.go"},
+	}
+	for _, tt := range tests {
+		if got := tt.mode.display(file); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+
+	var mode pathMode
+	if err := mode.Set("relative"); err == nil {
+		t.Fatalf("expected an error for an unknown path mode")
+	}
+}
+
+func TestDoctor(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/mod\n\ngo 1.24\n")
+	writeFile(t, filepath.Join(tmp, "p_test.go"), "package p\n")
+	withWorkingDir(t, tmp)
+
+	var out bytes.Buffer
+	d := &doctor{w: &out, opts: options{cache: &resultCache{dir: filepath.Join(tmp, "cache")}}}
+	d.run(nil)
+	if d.failed {
+		t.Fatalf("doctor reported a failure:\n%s", out.String())
+	}
+	for _, want := range []string{
+		"ok    module: example.com/mod",
+		"ok    cache: " + filepath.Join(tmp, "cache"),
+		"only test files; pass -tests",
+		"nothing to analyse",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("doctor output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRuleSelection(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "p.go"), `package p
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
+`)
+	tests := []struct {
+		enable, disable string
+		want            int
+	}{
+		{"", "", 1},
+		{"bs0001", "", 1},
+		{"all", "BS0001", 0},
+		{"", "all", 0},
+	}
+	for _, tt := range tests {
+		var enable, disable ruleList
+		if tt.enable != "" {
+			if err := enable.Set(tt.enable); err != nil {
+				t.Fatalf("enable %q: %v", tt.enable, err)
+			}
+		}
+		if tt.disable != "" {
+			if err := disable.Set(tt.disable); err != nil {
+				t.Fatalf("disable %q: %v", tt.disable, err)
+			}
+		}
+		findings, err := collectDir(tmp, options{disabled: disabledRules(enable, disable)})
+		if err != nil {
+			t.Fatalf("collectDir returned error: %v", err)
+		}
+		if len(findings) != tt.want {
+			t.Errorf("-enable=%q -disable=%q: got %d findings, want %d", tt.enable, tt.disable, len(findings), tt.want)
+		}
+	}
+
+	var list ruleList
+	if err := list.Set("BS9999"); err == nil {
+		t.Fatalf("expected an error for an unknown rule")
+	}
+}
+
+func TestMessageLocale(t *testing.T) {
+	defer func(locale string) { messageLocale = locale }(messageLocale)
+	messageLocale = "de_DE.UTF-8"
+
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "p.go"), "package p\n\nfunc f() {\n\tset := map[string]bool{}\n\tset[\"a\"] = true\n}\n")
+	findings, err := collectDir(tmp, options{})
+	if err != nil || len(findings) != 1 {
+		t.Fatalf("collectDir = %v, %v; want one finding", findings, err)
+	}
+	f := findings[0]
+	if want := "map[string]bool speichert nur „true“; besser map[string]struct{}"; f.display() != want {
+		t.Errorf("display() = %q, want %q", f.display(), want)
+	}
+	// Baselines and fingerprints keep matching across locales.
+	if want := `map[string]bool only stores "true" values; consider map[string]struct{}`; f.message != want {
+		t.Errorf("message = %q, want %q", f.message, want)
+	}
+}
+
+func TestStartProfiles(t *testing.T) {
+	tmp := t.TempDir()
+	cpu := filepath.Join(tmp, "cpu.out")
+	mem := filepath.Join(tmp, "mem.out")
+	trace := filepath.Join(tmp, "trace.out")
+	if err := startProfiles(cpu, mem, trace); err != nil {
+		t.Fatalf("startProfiles returned error: %v", err)
+	}
+	stopProfiles()
+
+	for _, path := range []string{cpu, mem, trace} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		if info.Size() == 0 {
+			t.Fatalf("profile %s is empty", path)
+		}
+	}
+}
+
+func TestCommandStopsProfiles(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "p.go"), "package p\n")
+	withWorkingDir(t, tmp)
+
+	// Profiles are flushed when the command returns, without exiting.
+	if code := runCommand([]string{"lint", "-no-cache", "-cpuprofile", "cpu.out", "-memprofile", "mem.out", "."}); code != exitOK {
+		t.Fatalf("lint exited with %d", code)
+	}
+	for _, path := range []string{"cpu.out", "mem.out"} {
+		if info, err := os.Stat(filepath.Join(tmp, path)); err != nil || info.Size() == 0 {
+			t.Fatalf("profile %s not written: %v", path, err)
+		}
+	}
+}
+
+func TestServeStopsOnCancel(t *testing.T) {
+	tmp := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() { errc <- serve(ctx, filepath.Join(tmp, "boolsetlint.sock"), "", options{}) }()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatalf("serve returned error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve did not return after cancellation")
+	}
+}
+
+func TestTimingLogSummary(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	log := &timingLog{w: &out}
+	log.record(&pkgTiming{dir: "fast", load: time.Millisecond})
+	log.record(&pkgTiming{dir: "slow", check: 3 * time.Millisecond})
+	log.record(&pkgTiming{dir: "medium", analyze: 2 * time.Millisecond})
+	out.Reset()
+
+	if err := log.summary(2); err != nil {
+		t.Fatalf("summary returned error: %v", err)
+	}
+	want := "boolsetlint: slowest 2 package(s):\n" +
+		"  slow: load 0s, type-check 3ms, analyze 0s, total 3ms\n" +
+		"  medium: load 0s, type-check 0s, analyze 2ms, total 2ms\n"
+	if out.String() != want {
+		t.Fatalf("unexpected summary %q, want %q", out.String(), want)
+	}
+}
+
+type stubImporter struct {
+	pkg *types.Package
+	err error
+}
+
+func (s stubImporter) Import(path string) (*types.Package, error) {
+	return s.ImportFrom(path, "", 0)
+}
+
+func (s stubImporter) ImportFrom(string, string, types.ImportMode) (*types.Package, error) {
+	return s.pkg, s.err
+}
+
+func TestFallbackImporter(t *testing.T) {
+	t.Parallel()
+
+	want := types.NewPackage("example.com/dep", "dep")
+	imp := fallbackImporter{
+		stubImporter{err: errors.New("no export data")},
+		stubImporter{pkg: want},
+	}
+	got, err := imp.Import("example.com/dep")
+	if err != nil || got != want {
+		t.Fatalf("Import returned %v, %v; want %v", got, err, want)
+	}
+
+	imp = fallbackImporter{
+		stubImporter{err: errors.New("first")},
+		stubImporter{err: errors.New("second")},
+	}
+	if _, err := imp.Import("example.com/dep"); err == nil || !strings.Contains(err.Error(), "first") || !strings.Contains(err.Error(), "second") {
+		t.Fatalf("expected both errors, got %v", err)
+	}
+}
+
+func TestInspectDirSourceImporter(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "p.go"), `package p
+
+import "strings"
+
+func f() {
+	set := map[string]bool{}
+	set[strings.ToLower("A")] = true
+}
+`)
+
+	// Outside a module the go command cannot list export data.
+	count, err := inspectDir(tmp, options{})
+	if err != nil {
+		t.Fatalf("inspectDir returned error: %v", err)
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 issue, got %d", count)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
 func withWorkingDir(t *testing.T, dir string) {
 	t.Helper()
 	cwd, err := os.Getwd()
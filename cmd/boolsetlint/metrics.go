@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsLog counts the reported findings of a run for -metrics-out. It is
+// safe for concurrent use by packages analysed in parallel.
+type metricsLog struct {
+	mu       sync.Mutex
+	rules    map[string]int
+	packages map[string]int
+	duration time.Duration
+}
+
+func newMetricsLog() *metricsLog {
+	m := &metricsLog{rules: make(map[string]int), packages: make(map[string]int)}
+	// Every rule is written, so a rule's series drops to zero instead of
+	// disappearing once its last finding is fixed.
+	for _, r := range rules {
+		m.rules[r.id] = 0
+	}
+	return m
+}
+
+// add counts the findings reported for the package in dir. It does nothing
+// on a nil log.
+func (m *metricsLog) add(dir string, findings []finding) {
+	if m == nil || len(findings) == 0 {
+		return
+	}
+	pkg := filepath.ToSlash(pathRel.display(dir))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, f := range findings {
+		m.rules[f.rule]++
+		m.packages[pkg]++
+	}
+}
+
+// write stores the metrics at path in the Prometheus text exposition format,
+// for node_exporter's textfile collector. The file is replaced atomically so
+// the collector never reads a partial file.
+func (m *metricsLog) write(path string) error {
+	var buf bytes.Buffer
+	buf.WriteString("# HELP boolsetlint_findings Findings reported by the last run, by rule.\n")
+	buf.WriteString("# TYPE boolsetlint_findings gauge\n")
+	for _, rule := range sortedKeys(m.rules) {
+		fmt.Fprintf(&buf, "boolsetlint_findings{rule=%s} %d\n", quoteLabel(rule), m.rules[rule])
+	}
+	buf.WriteString("# HELP boolsetlint_package_findings Findings reported by the last run, by package directory.\n")
+	buf.WriteString("# TYPE boolsetlint_package_findings gauge\n")
+	for _, pkg := range sortedKeys(m.packages) {
+		fmt.Fprintf(&buf, "boolsetlint_package_findings{package=%s} %d\n", quoteLabel(pkg), m.packages[pkg])
+	}
+	buf.WriteString("# HELP boolsetlint_duration_seconds Wall time of the last run.\n")
+	buf.WriteString("# TYPE boolsetlint_duration_seconds gauge\n")
+	fmt.Fprintf(&buf, "boolsetlint_duration_seconds %g\n", m.duration.Seconds())
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	// CreateTemp restricts the file to its owner; the collector may run as
+	// another user.
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := buf.WriteTo(tmp); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// quoteLabel quotes a label value, escaping only what the exposition format
+// requires.
+func quoteLabel(value string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(value) + `"`
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"golang.org/x/mod/modfile"
+)
+
+// pathMode selects how file names are displayed in findings.
+type pathMode string
+
+const (
+	// pathAbs prints absolute paths.
+	pathAbs pathMode = "abs"
+	// pathRel prints paths relative to the working directory.
+	pathRel pathMode = "rel"
+	// pathModule prints the module path followed by the path within the
+	// module, e.g. example.com/mod/pkg/file.go.
+	pathModule pathMode = "module"
+)
+
+func (m *pathMode) String() string {
+	return string(*m)
+}
+
+func (m *pathMode) Set(value string) error {
+	switch mode := pathMode(value); mode {
+	case pathAbs, pathRel, pathModule:
+		*m = mode
+		return nil
+	}
+	return fmt.Errorf("must be abs, rel or module")
+}
+
+// display returns filename in the form selected by m. The zero mode, and any
+// mode that cannot be applied to filename, leaves it as reported.
+func (m pathMode) display(filename string) string {
+	if m == "" {
+		return filename
+	}
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return filename
+	}
+	switch m {
+	case pathRel:
+		wd, err := os.Getwd()
+		if err != nil {
+			return abs
+		}
+		if rel, err := filepath.Rel(wd, abs); err == nil {
+			return rel
+		}
+	case pathModule:
+		if p := modulePath(filepath.Dir(abs), abs); p != "" {
+			return p
+		}
+	}
+	return abs
+}
+
+// importPath returns the import path of the package in dir, or "" when dir
+// is not inside a module.
+func importPath(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	return modulePath(abs, abs)
+}
+
+// modulePath returns the module path of the module containing dir followed
+// by the path of abs within it, or "" when either cannot be determined.
+func modulePath(dir, abs string) string {
+	root := moduleRoot(dir)
+	if root == "" {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return ""
+	}
+	modPath := modfile.ModulePath(data)
+	rel, err := filepath.Rel(root, abs)
+	if modPath == "" || err != nil {
+		return ""
+	}
+	return path.Join(modPath, filepath.ToSlash(rel))
+}
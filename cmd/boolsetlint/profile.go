@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// stopProfiles flushes any profiles started by startProfiles. It runs when a
+// command returns and before every exit, so profiles are complete even when
+// findings fail the run.
+var stopProfiles = func() {}
+
+// exit stops profiling and terminates the process with code.
+func exit(code int) {
+	stopProfiles()
+	os.Exit(code)
+}
+
+// startProfiles starts the CPU profile and execution trace and arranges for
+// the heap profile to be written when profiling stops. Empty paths disable
+// the corresponding profile.
+func startProfiles(cpuProfile, memProfile, traceFile string) error {
+	var stops []func()
+	stopAll := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if cpuProfile != "" {
+		f, err := os.Create(cpuProfile)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			closeProfile(f)
+		})
+	}
+	if traceFile != "" {
+		f, err := os.Create(traceFile)
+		if err != nil {
+			stopAll()
+			return err
+		}
+		if err := trace.Start(f); err != nil {
+			f.Close()
+			stopAll()
+			return err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			closeProfile(f)
+		})
+	}
+	if memProfile != "" {
+		stops = append(stops, func() {
+			f, err := os.Create(memProfile)
+			if err != nil {
+				reportProfileError(err)
+				return
+			}
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(f); err != nil {
+				reportProfileError(err)
+			}
+			closeProfile(f)
+		})
+	}
+
+	if len(stops) == 0 {
+		return nil
+	}
+	stopProfiles = func() {
+		stopProfiles = func() {}
+		stopAll()
+	}
+	return nil
+}
+
+func closeProfile(f *os.File) {
+	if err := f.Close(); err != nil {
+		reportProfileError(err)
+	}
+}
+
+func reportProfileError(err error) {
+	if _, err := fmt.Fprintln(os.Stderr, "boolsetlint: profile:", err); err != nil {
+		os.Exit(exitFailure)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arturmelanchyk/boolset/boolset"
+)
+
+// rule documents a check for the explain command.
+type rule struct {
+	id    string
+	title string
+	doc   string
+}
+
+var rules = []rule{
+	{
+		id:    boolset.RuleMapBoolSet,
+		title: "map[T]bool used as a set",
+		doc: `A map with bool values is reported when every value ever stored in it is the
+constant true. Such a map is a set: false is never stored, so the value carries
+no information beyond the presence of the key.
+
+Rationale
+
+A bool value occupies one byte in every map slot, and the slot is padded to
+the alignment of the key, so map[int64]bool spends eight bytes per entry on a
+value that is always true. struct{} has size zero: map[T]struct{} stores the
+keys alone. The set type also states the intent directly and rules out the
+ambiguity between a missing key and a key mapped to false.
+
+Examples
+
+Reported:
+
+	seen := map[string]bool{}
+	for _, name := range names {
+		seen[name] = true
+	}
+	if seen["x"] { ... }
+
+Preferred:
+
+	seen := map[string]struct{}{}
+	for _, name := range names {
+		seen[name] = struct{}{}
+	}
+	if _, ok := seen["x"]; ok { ... }
+
+Not reported, because false is stored or the value is not known to be true:
+
+	enabled := map[string]bool{"a": true}
+	enabled["b"] = isEnabled("b")
+
+Not reported either are maps passed to functions of other packages or stored
+in interface values, whose users may depend on the element type, and, unless
+-include-serialized is set, maps encoded with encoding/json and similar
+packages, whose encoded form the conversion changes.
+
+"boolsetlint fix" rewrites a map automatically when the rewrite is provably
+safe: the map is only initialised, written with constant true values and
+deleted from or cleared, and its type is not part of an exported API.
+
+Suppressing
+
+  - Record existing findings with "boolsetlint baseline" and run
+    "boolsetlint lint -baseline boolset-baseline.json" to report only new ones.
+  - Under golangci-lint, add a "//nolint:boolset" comment to the line.
+  - A map that deliberately needs bool values will stop being reported once
+    the code stores false in it.
+`,
+	},
+}
+
+func findRule(id string) (rule, bool) {
+	for _, r := range rules {
+		if strings.EqualFold(r.id, id) {
+			return r, true
+		}
+	}
+	return rule{}, false
+}
+
+// ruleList is a repeatable flag of rule IDs, or "all", that also accepts
+// comma-separated values. Unknown IDs are rejected while parsing flags.
+type ruleList []string
+
+func (l *ruleList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *ruleList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if strings.EqualFold(v, "all") {
+			*l = append(*l, "all")
+			continue
+		}
+		r, ok := findRule(v)
+		if !ok {
+			return fmt.Errorf("unknown rule %q; run \"boolsetlint explain\" to list rules", v)
+		}
+		*l = append(*l, r.id)
+	}
+	return nil
+}
+
+// expand returns the rule IDs l selects.
+func (l ruleList) expand() map[string]struct{} {
+	ids := make(map[string]struct{})
+	for _, id := range l {
+		if id == "all" {
+			for _, r := range rules {
+				ids[r.id] = struct{}{}
+			}
+			continue
+		}
+		ids[id] = struct{}{}
+	}
+	return ids
+}
+
+// disabledRules returns the rules turned off by the -enable and -disable
+// flags: everything outside enable when it is given, plus everything in
+// disable.
+func disabledRules(enable, disable ruleList) map[string]struct{} {
+	disabled := disable.expand()
+	if len(enable) > 0 {
+		enabled := enable.expand()
+		for _, r := range rules {
+			if _, ok := enabled[r.id]; !ok {
+				disabled[r.id] = struct{}{}
+			}
+		}
+	}
+	if len(disabled) == 0 {
+		return nil
+	}
+	return disabled
+}
+
+// filterRules drops the findings of disabled rules.
+func filterRules(findings []finding, disabled map[string]struct{}) []finding {
+	if len(disabled) == 0 {
+		return findings
+	}
+	kept := findings[:0:0]
+	for _, f := range findings {
+		if _, ok := disabled[f.rule]; !ok {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
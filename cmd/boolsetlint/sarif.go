@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+
+	"github.com/arturmelanchyk/boolset/boolset"
+)
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// sarifFingerprint names the partial fingerprint boolsetlint computes. Bump
+// the version if its inputs change, so code scanning treats the new values
+// as a different scheme instead of closing and reopening every alert.
+const sarifFingerprint = "boolsetObject/v1"
+
+type sarifReport struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version,omitempty"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+	HelpURI          string       `json:"helpUri"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn"`
+			EndLine     int `json:"endLine,omitempty"`
+			EndColumn   int `json:"endColumn,omitempty"`
+		} `json:"region"`
+	} `json:"physicalLocation"`
+}
+
+// writeSARIF stores findings at path as a SARIF 2.1.0 log.
+func writeSARIF(path string, findings []finding) error {
+
+	driver := sarifDriver{
+		Name:           "boolsetlint",
+		InformationURI: "https://github.com/arturmelanchyk/boolset",
+		Rules:          []sarifRule{},
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		driver.Version = info.Main.Version
+	}
+	for _, r := range rules {
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:               r.id,
+			ShortDescription: sarifMessage{Text: r.title},
+			FullDescription:  sarifMessage{Text: strings.TrimSpace(r.doc)},
+			HelpURI:          boolset.RuleURL(r.id),
+		})
+	}
+
+	run := sarifRun{Tool: sarifTool{Driver: driver}, Results: []sarifResult{}}
+	seen := make(map[string]int)
+	for _, f := range findings {
+		result := sarifResult{
+			RuleID:    f.rule,
+			Level:     sarifLevel(f.severity),
+			Message:   sarifMessage{Text: f.display()},
+			Locations: make([]sarifLocation, 1),
+		}
+		loc := &result.Locations[0].PhysicalLocation
+		loc.ArtifactLocation.URI = filepath.ToSlash(pathRel.display(f.pos.Filename))
+		loc.Region.StartLine = f.pos.Line
+		loc.Region.StartColumn = f.pos.Column
+		if f.end.IsValid() {
+			loc.Region.EndLine = f.end.Line
+			loc.Region.EndColumn = f.end.Column
+		}
+
+		identity := strings.Join([]string{f.rule, pathModule.display(f.pos.Filename), f.object, f.message}, "\x00")
+		seen[identity]++
+		result.PartialFingerprints = map[string]string{sarifFingerprint: fingerprint(identity, seen[identity])}
+		run.Results = append(run.Results, result)
+	}
+
+	data, err := json.MarshalIndent(sarifReport{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// sarifLevel returns the SARIF result level of findings of severity sev.
+func sarifLevel(sev boolset.Severity) string {
+	switch sev {
+	case boolset.SeverityInfo:
+		return "note"
+	case boolset.SeverityError:
+		return "error"
+	}
+	return "warning"
+}
+
+// fingerprint hashes a finding's identity and its occurrence among findings
+// sharing that identity. Line numbers are left out so that edits elsewhere in
+// the file do not turn known alerts into new ones.
+func fingerprint(identity string, occurrence int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", identity, occurrence)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// objectName returns obj's name qualified by the top-level declaration that
+// encloses it in files: "f.seen" for a local of f, "(*S).init.seen" for a
+// local of a method, "S.seen" for a field of S, and "seen" for a package-level
+// variable.
+func objectName(files []*ast.File, obj types.Object) string {
+	if obj == nil {
+		return ""
+	}
+	for _, file := range files {
+		if obj.Pos() < file.Pos() || obj.Pos() >= file.End() {
+			continue
+		}
+		for _, decl := range file.Decls {
+			if obj.Pos() < decl.Pos() || obj.Pos() >= decl.End() {
+				continue
+			}
+			switch decl := decl.(type) {
+			case *ast.FuncDecl:
+				name := decl.Name.Name
+				if decl.Recv != nil && len(decl.Recv.List) > 0 {
+					name = "(" + types.ExprString(decl.Recv.List[0].Type) + ")." + name
+				}
+				return name + "." + obj.Name()
+			case *ast.GenDecl:
+				for _, spec := range decl.Specs {
+					if ts, ok := spec.(*ast.TypeSpec); ok && obj.Pos() >= ts.Pos() && obj.Pos() < ts.End() {
+						return ts.Name.Name + "." + obj.Name()
+					}
+				}
+			}
+		}
+	}
+	return obj.Name()
+}
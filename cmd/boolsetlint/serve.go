@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// serveRequest asks the daemon to analyse patterns relative to Dir.
+type serveRequest struct {
+	Dir      string   `json:"dir"`
+	Patterns []string `json:"patterns"`
+}
+
+type serveFinding struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+type serveResponse struct {
+	Findings []serveFinding `json:"findings"`
+	Errors   []string       `json:"errors,omitempty"`
+}
+
+// server answers analysis requests, reusing results across requests through
+// the in-memory layer of opts.cache and type information through opts.types.
+type server struct {
+	opts options
+	mu   sync.Mutex
+	// last is the response to the most recent request, served by
+	// GET /findings.
+	last serveResponse
+}
+
+// defaultSocket returns the socket the daemon listens on by default, in a
+// directory only the user can access: $XDG_RUNTIME_DIR when set, the user
+// cache directory otherwise.
+func defaultSocket() string {
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = defaultCacheDir()
+	}
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), fmt.Sprintf("boolsetlint-%d", os.Getuid()))
+	}
+	return filepath.Join(dir, "boolsetlint.sock")
+}
+
+// serve listens on the unix socket and answers newline-delimited JSON
+// requests until ctx is done or the listener fails. When httpAddr is set, the
+// same requests are also answered over HTTP.
+func serve(ctx context.Context, socket, httpAddr string, opts options) error {
+	if err := os.MkdirAll(filepath.Dir(socket), 0700); err != nil {
+		return err
+	}
+	if err := removeStaleSocket(socket); err != nil {
+		return err
+	}
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+	// Closing the listener makes s.serve return.
+	defer context.AfterFunc(ctx, func() { ln.Close() })()
+	s := &server{opts: opts, last: serveResponse{Findings: []serveFinding{}}}
+	if httpAddr == "" {
+		return s.serve(ln)
+	}
+
+	httpAddr, err = loopbackAddr(httpAddr)
+	if err != nil {
+		return err
+	}
+	httpLn, err := net.Listen("tcp", httpAddr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: s.httpHandler()}
+	defer srv.Close()
+	errc := make(chan error, 2)
+	go func() { errc <- srv.Serve(httpLn) }()
+	go func() { errc <- s.serve(ln) }()
+	return <-errc
+}
+
+// removeStaleSocket removes the socket a previous daemon left behind, which
+// would make Listen fail. It refuses to remove anything but a socket, or a
+// socket another daemon still answers on.
+func removeStaleSocket(socket string) error {
+	info, err := os.Lstat(socket)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", socket)
+	}
+	if conn, err := net.Dial("unix", socket); err == nil {
+		conn.Close()
+		return fmt.Errorf("another daemon is listening on %s", socket)
+	}
+	return os.Remove(socket)
+}
+
+// loopbackAddr returns addr, listening on the loopback interface when it
+// names no host. The HTTP API has no authentication, so other hosts are
+// refused.
+func loopbackAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+	if !isLoopback(host) {
+		return "", fmt.Errorf("-http %s: the HTTP API is unauthenticated and only listens on loopback addresses", addr)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// isLoopback reports whether host names the local machine.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// localOnly rejects the requests a web page can make the browser send to
+// the daemon: requests for a non-loopback Host, as DNS rebinding produces,
+// and requests from non-loopback origins.
+func localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !isLoopback(host) {
+			http.Error(w, "host not allowed", http.StatusForbidden)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || !isLoopback(u.Hostname()) {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// httpHandler exposes the daemon to tools that speak HTTP rather than the
+// socket protocol: POST /analyze takes a serveRequest body and returns the
+// serveResponse, and GET /findings returns the response to the most recent
+// request from either transport. Only local clients are answered, and
+// /analyze requires a JSON content type, which browsers cannot send across
+// origins without a preflight the daemon does not answer.
+func (s *server) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/analyze", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+			http.Error(w, "use Content-Type: application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		var req serveRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, s.analyze(req))
+	})
+	mux.HandleFunc("/findings", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "use GET", http.StatusMethodNotAllowed)
+			return
+		}
+		// Waits for a request in progress, so the result is never stale
+		// relative to a POST that already returned.
+		s.mu.Lock()
+		resp := s.last
+		s.mu.Unlock()
+		writeJSON(w, resp)
+	})
+	return localOnly(mux)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	// The client went away if this fails; there is no one to report to.
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (s *server) serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req serveRequest
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		if err := enc.Encode(s.analyze(req)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *server) analyze(req serveRequest) serveResponse {
+	// Requests are answered one at a time; the go command and the cache do
+	// the heavy lifting and gain little from overlapping runs.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	patterns := req.Patterns
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	if req.Dir != "" {
+		resolved := make([]string, len(patterns))
+		for i, pattern := range patterns {
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(req.Dir, pattern)
+			}
+			resolved[i] = pattern
+		}
+		patterns = resolved
+	}
+
+	resp := serveResponse{Findings: []serveFinding{}}
+	targets, err := expandTargets(patterns, s.opts)
+	if err != nil {
+		resp.Errors = append(resp.Errors, err.Error())
+		s.last = resp
+		return resp
+	}
+	for _, target := range targets {
+		findings, err := collectPath(target, s.opts)
+		if err != nil {
+			resp.Errors = append(resp.Errors, err.Error())
+		}
+		for _, f := range findings {
+			resp.Findings = append(resp.Findings, serveFinding{
+				File:    f.pos.Filename,
+				Line:    f.pos.Line,
+				Column:  f.pos.Column,
+				Rule:    f.rule,
+				Message: f.display(),
+			})
+		}
+	}
+	s.last = resp
+	return resp
+}
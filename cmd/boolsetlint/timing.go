@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// slowestPackages is the number of packages listed in the -v summary.
+const slowestPackages = 10
+
+// pkgTiming records where the time analysing one package directory went.
+// Loading covers build constraint evaluation, export data lookup and parsing.
+type pkgTiming struct {
+	dir     string
+	load    time.Duration
+	check   time.Duration
+	analyze time.Duration
+}
+
+func (t *pkgTiming) total() time.Duration {
+	return t.load + t.check + t.analyze
+}
+
+func (t *pkgTiming) String() string {
+	return fmt.Sprintf("%s: load %v, type-check %v, analyze %v, total %v",
+		t.dir, round(t.load), round(t.check), round(t.analyze), round(t.total()))
+}
+
+// timingLog prints each package's timing as it completes and keeps them for
+// the end-of-run summary. A nil log records nothing.
+type timingLog struct {
+	w io.Writer
+
+	mu   sync.Mutex
+	pkgs []*pkgTiming
+}
+
+func (l *timingLog) record(t *pkgTiming) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pkgs = append(l.pkgs, t)
+	if _, err := fmt.Fprintf(l.w, "boolsetlint: %v\n", t); err != nil {
+		exit(exitFailure)
+	}
+}
+
+// summary prints the n slowest packages recorded so far.
+func (l *timingLog) summary(n int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pkgs := append([]*pkgTiming(nil), l.pkgs...)
+	sort.SliceStable(pkgs, func(i, j int) bool {
+		return pkgs[i].total() > pkgs[j].total()
+	})
+	if len(pkgs) > n {
+		pkgs = pkgs[:n]
+	}
+	if len(pkgs) == 0 {
+		return nil
+	}
+	if _, err := fmt.Fprintf(l.w, "boolsetlint: slowest %d package(s):\n", len(pkgs)); err != nil {
+		return err
+	}
+	for _, t := range pkgs {
+		if _, err := fmt.Fprintf(l.w, "  %v\n", t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strings"
+)
+
+func versionCommand(*flag.FlagSet) func(options, []string) int {
+	return func(options, []string) int {
+		return printVersion()
+	}
+}
+
+func printVersion() int {
+	info, _ := debug.ReadBuildInfo()
+	if _, err := fmt.Fprint(os.Stdout, versionString(info)); err != nil {
+		exit(exitFailure)
+	}
+	return exitOK
+}
+
+// versionString describes the build in info: the module version, the VCS
+// revision it was built from and the Go toolchain, one per line. info is nil
+// when the binary carries no build information.
+func versionString(info *debug.BuildInfo) string {
+	if info == nil {
+		return "boolsetlint (unknown)\n"
+	}
+	version := info.Main.Version
+	if version == "" {
+		version = "(devel)"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "boolsetlint %s\n", version)
+
+	var revision, revisionTime string
+	modified := false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.time":
+			revisionTime = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision != "" {
+		var details []string
+		if revisionTime != "" {
+			details = append(details, revisionTime)
+		}
+		if modified {
+			details = append(details, "modified")
+		}
+		fmt.Fprintf(&sb, "revision: %s", revision)
+		if len(details) > 0 {
+			fmt.Fprintf(&sb, " (%s)", strings.Join(details, ", "))
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "go: %s\n", info.GoVersion)
+	return sb.String()
+}
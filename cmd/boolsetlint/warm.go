@@ -0,0 +1,83 @@
+package main
+
+import (
+	"go/build"
+	"go/token"
+	"strings"
+	"sync"
+
+	"github.com/arturmelanchyk/boolset/boolset"
+)
+
+// typeCache keeps type information warm in long-running commands. For each
+// group of files it holds the export data of the dependencies for as long as
+// they are unchanged, and the type-checked package for as long as the files
+// are unchanged too, so an edit re-parses and re-checks only the edited
+// package.
+type typeCache struct {
+	mu   sync.Mutex
+	pkgs map[typeKey]*warmPackage
+}
+
+// typeKey identifies a group of files of a package directory in a build
+// context.
+type typeKey struct {
+	dir    string
+	target string
+	names  string
+}
+
+// warmPackage is the type information kept for a group of files. deps and
+// files are the packageKeys it was loaded for; mu guards files and checked
+// from the check to the store, so concurrent requests check each version
+// once.
+type warmPackage struct {
+	deps    string
+	exports map[string]string
+
+	mu      sync.Mutex
+	files   string
+	checked *checkedPackage
+}
+
+func newTypeCache() *typeCache {
+	return &typeCache{pkgs: make(map[typeKey]*warmPackage)}
+}
+
+// analyze is analyzeFiles reusing the type information loaded by previous
+// calls with the same keys.
+func (c *typeCache) analyze(l *boolset.Linter, ctx *build.Context, dir string, names []string, keys packageKeys, exports func() map[string]string, stats *pkgStats) ([]finding, error) {
+	key := typeKey{
+		dir:    dir,
+		target: ctx.GOOS + "/" + ctx.GOARCH + " " + strings.Join(ctx.BuildTags, ","),
+		names:  strings.Join(names, " "),
+	}
+	c.mu.Lock()
+	w := c.pkgs[key]
+	if w == nil || w.deps != keys.deps {
+		w = &warmPackage{deps: keys.deps}
+		c.pkgs[key] = w
+	}
+	c.mu.Unlock()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.checked != nil && w.files == keys.files {
+		for _, path := range w.checked.missing {
+			stats.missing[path] = struct{}{}
+		}
+		return analyzeChecked(l, w.checked, stats)
+	}
+	if w.exports == nil {
+		w.exports = exports()
+	}
+	// Each version of the files gets a FileSet of its own, which would
+	// otherwise grow with every edit.
+	fset := token.NewFileSet()
+	checked, err := checkFiles(fset, newImporter(fset, w.exports), dir, names, stats)
+	if checked == nil {
+		return nil, err
+	}
+	w.files, w.checked = keys.files, checked
+	return analyzeChecked(l, checked, stats)
+}
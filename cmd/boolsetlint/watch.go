@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce groups bursts of file events (editor saves, git checkouts)
+// into a single re-analysis.
+const watchDebounce = 200 * time.Millisecond
+
+// watch re-analyses the packages whose Go files change, and the packages
+// among targets importing them, until interrupted or the watcher fails.
+// Directories created below the patterns expanded into targets are watched
+// as they appear.
+func watch(patterns, targets []string, opts options) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	watched := make(map[string]struct{})
+	for _, dir := range watchDirs(targets) {
+		if err := w.Add(dir); err != nil {
+			return err
+		}
+		watched[dir] = struct{}{}
+	}
+	if _, err := fmt.Fprintln(os.Stderr, "boolsetlint: watching for changes"); err != nil {
+		exit(exitFailure)
+	}
+
+	pending := make(map[string]struct{})
+	created := false
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if event.Op.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					created = true
+					timer.Reset(watchDebounce)
+					continue
+				}
+			}
+			if filepath.Ext(event.Name) != ".go" || event.Op == fsnotify.Chmod {
+				continue
+			}
+			pending[filepath.Dir(event.Name)] = struct{}{}
+			timer.Reset(watchDebounce)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-timer.C:
+			if created {
+				// New directories matching the patterns may already hold
+				// files, written before they were watched.
+				created = false
+				if expanded, err := expandTargets(patterns, opts); err == nil {
+					targets = expanded
+				}
+				for _, dir := range watchDirs(targets) {
+					if _, ok := watched[dir]; ok {
+						continue
+					}
+					if err := w.Add(dir); err != nil {
+						return err
+					}
+					watched[dir] = struct{}{}
+					pending[dir] = struct{}{}
+				}
+			}
+			if len(pending) == 0 {
+				continue
+			}
+			changed := make([]string, 0, len(pending))
+			for dir := range pending {
+				changed = append(changed, dir)
+			}
+			clear(pending)
+			dirs := dependents(targets, changed, opts.tests)
+			if _, err := fmt.Fprintf(os.Stderr, "boolsetlint: re-analysing %d package(s)\n", len(dirs)); err != nil {
+				exit(exitFailure)
+			}
+			// Each cycle reports on its own.
+			if opts.reported != nil {
+				opts.reported = &findingLog{}
+			}
+			if opts.metrics != nil {
+				opts.metrics = newMetricsLog()
+			}
+			if count, _ := run(ctx, dirs, opts); count == 0 {
+				if _, err := fmt.Fprintln(os.Stderr, "boolsetlint found no issues"); err != nil {
+					exit(exitFailure)
+				}
+			}
+		}
+	}
+}
+
+// dependents returns, sorted, the changed package directories and the
+// directories among targets whose packages import them, directly or not, as
+// their results may change with them. Test files count with tests set.
+func dependents(targets, changed []string, tests bool) []string {
+	importers := make(map[string][]string)
+	for _, dir := range targets {
+		for _, path := range dirImports(dir, tests) {
+			importers[path] = append(importers[path], dir)
+		}
+	}
+	seen := make(map[string]struct{})
+	var dirs []string
+	queue := append([]string(nil), changed...)
+	for len(queue) > 0 {
+		dir := queue[0]
+		queue = queue[1:]
+		if _, ok := seen[dir]; ok {
+			continue
+		}
+		seen[dir] = struct{}{}
+		dirs = append(dirs, dir)
+		if path := importPath(dir); path != "" {
+			queue = append(queue, importers[path]...)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// dirImports returns the import paths of the Go files in dir.
+func dirImports(dir string, tests bool) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var imports []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || (!tests && strings.HasSuffix(name, "_test.go")) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		imports = append(imports, fileImports(name, data)...)
+	}
+	return imports
+}
+
+// watchDirs returns the directories to watch for the given targets; file
+// targets are watched through their parent directory.
+func watchDirs(targets []string) []string {
+	seen := make(map[string]struct{})
+	var dirs []string
+	for _, target := range targets {
+		dir := target
+		if info, err := os.Stat(target); err == nil && !info.IsDir() {
+			dir = filepath.Dir(target)
+		}
+		if _, ok := seen[dir]; ok {
+			continue
+		}
+		seen[dir] = struct{}{}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
@@ -0,0 +1,16 @@
+// Command boolsetvet runs the boolset analyzer as a go vet tool:
+//
+//	go vet -vettool=$(which boolsetvet) ./...
+//
+// The go command loads packages, resolves modules and caches results, so
+// analysis matches the project's own builds exactly.
+package main
+
+import (
+	"github.com/arturmelanchyk/boolset/boolset"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(boolset.Analyzers()...)
+}
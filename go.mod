@@ -2,4 +2,14 @@ module github.com/arturmelanchyk/boolset
 
 go 1.24.0
 
-require golang.org/x/tools v0.37.0
+require (
+	github.com/fsnotify/fsnotify v1.10.1
+	github.com/golangci/plugin-module-register v0.1.2
+	golang.org/x/mod v0.28.0
+	golang.org/x/tools v0.37.0
+)
+
+require (
+	golang.org/x/sync v0.17.0 // indirect
+	golang.org/x/sys v0.36.0 // indirect
+)
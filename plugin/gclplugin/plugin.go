@@ -0,0 +1,136 @@
+// Package gclplugin registers boolset with golangci-lint's module plugin
+// system (golangci-lint v1.57+). Reference it from .custom-gcl.yml and enable
+// the "boolset" linter; settings are decoded into Settings.
+package gclplugin
+
+import (
+	"fmt"
+	"go/token"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/arturmelanchyk/boolset/boolset"
+	"github.com/golangci/plugin-module-register/register"
+	"golang.org/x/tools/go/analysis"
+)
+
+func init() {
+	register.Plugin("boolset", New)
+}
+
+// Settings is the linters-settings.custom.boolset.settings section of the
+// golangci-lint configuration.
+type Settings struct {
+	// Disable lists rule IDs, or "all", whose findings are dropped.
+	Disable []string `json:"disable"`
+	// Exclude lists regular expressions matched against file paths;
+	// findings in matching files are dropped.
+	Exclude []string `json:"exclude"`
+
+	// The remaining settings map onto the analyzer flags of the same name.
+	MinWrites         *int   `json:"min-writes"`
+	IncludeFields     *bool  `json:"include-fields"`
+	IncludeGlobals    *bool  `json:"include-globals"`
+	ExcludeKeyTypes   string `json:"exclude-key-types"`
+	SSA               *bool  `json:"ssa"`
+	IncludeSerialized *bool  `json:"include-serialized"`
+}
+
+type plugin struct {
+	disabled map[string]struct{}
+	exclude  []*regexp.Regexp
+	// flags are applied to every analyzer's Flags.
+	flags map[string]string
+}
+
+// New decodes settings and returns the boolset linter plugin.
+func New(settings any) (register.LinterPlugin, error) {
+	s, err := register.DecodeSettings[Settings](settings)
+	if err != nil {
+		return nil, err
+	}
+	p := &plugin{disabled: make(map[string]struct{})}
+	for _, id := range s.Disable {
+		switch {
+		case strings.EqualFold(id, "all"):
+			p.disabled[boolset.RuleMapBoolSet] = struct{}{}
+		case strings.EqualFold(id, boolset.RuleMapBoolSet):
+			p.disabled[boolset.RuleMapBoolSet] = struct{}{}
+		default:
+			return nil, fmt.Errorf("boolset: unknown rule %q in disable", id)
+		}
+	}
+	for _, pattern := range s.Exclude {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("boolset: exclude: %w", err)
+		}
+		p.exclude = append(p.exclude, re)
+	}
+
+	p.flags = make(map[string]string)
+	if s.MinWrites != nil {
+		p.flags["min-writes"] = strconv.Itoa(*s.MinWrites)
+	}
+	if s.IncludeFields != nil {
+		p.flags["include-fields"] = strconv.FormatBool(*s.IncludeFields)
+	}
+	if s.IncludeGlobals != nil {
+		p.flags["include-globals"] = strconv.FormatBool(*s.IncludeGlobals)
+	}
+	if s.ExcludeKeyTypes != "" {
+		p.flags["exclude-key-types"] = s.ExcludeKeyTypes
+	}
+	if s.SSA != nil {
+		p.flags["ssa"] = strconv.FormatBool(*s.SSA)
+	}
+	if s.IncludeSerialized != nil {
+		p.flags["include-serialized"] = strconv.FormatBool(*s.IncludeSerialized)
+	}
+	// Reject invalid values when golangci-lint starts rather than per package.
+	if _, err := p.BuildAnalyzers(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *plugin) BuildAnalyzers() ([]*analysis.Analyzer, error) {
+	analyzers := boolset.Analyzers()
+	for _, a := range analyzers {
+		for name, value := range p.flags {
+			if err := a.Flags.Set(name, value); err != nil {
+				return nil, fmt.Errorf("boolset: %s: %w", name, err)
+			}
+		}
+		run := a.Run
+		a.Run = func(pass *analysis.Pass) (any, error) {
+			report := pass.Report
+			pass.Report = func(d analysis.Diagnostic) {
+				if p.keep(pass.Fset, d) {
+					report(d)
+				}
+			}
+			defer func() { pass.Report = report }()
+			return run(pass)
+		}
+	}
+	return analyzers, nil
+}
+
+func (p *plugin) GetLoadMode() string {
+	return register.LoadModeTypesInfo
+}
+
+func (p *plugin) keep(fset *token.FileSet, d analysis.Diagnostic) bool {
+	if _, ok := p.disabled[d.Category]; ok {
+		return false
+	}
+	filename := fset.Position(d.Pos).Filename
+	for _, re := range p.exclude {
+		if re.MatchString(filename) {
+			return false
+		}
+	}
+	return true
+}
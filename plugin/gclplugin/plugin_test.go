@@ -0,0 +1,60 @@
+package gclplugin
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestPlugin(t *testing.T) {
+	p, err := New(map[string]any{"exclude": []string{"/excluded/"}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	analyzers, err := p.BuildAnalyzers()
+	if err != nil {
+		t.Fatalf("BuildAnalyzers returned error: %v", err)
+	}
+	analysistest.Run(t, analysistest.TestData(), analyzers[0], "p", "excluded")
+}
+
+func TestPluginDisable(t *testing.T) {
+	p, err := New(map[string]any{"disable": []string{"all"}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	analyzers, err := p.BuildAnalyzers()
+	if err != nil {
+		t.Fatalf("BuildAnalyzers returned error: %v", err)
+	}
+	analysistest.Run(t, analysistest.TestData(), analyzers[0], "excluded")
+}
+
+func TestPluginAnalyzerSettings(t *testing.T) {
+	p, err := New(map[string]any{"min-writes": 2})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	analyzers, err := p.BuildAnalyzers()
+	if err != nil {
+		t.Fatalf("BuildAnalyzers returned error: %v", err)
+	}
+	if got := analyzers[0].Flags.Lookup("min-writes").Value.String(); got != "2" {
+		t.Fatalf("min-writes = %s, want 2", got)
+	}
+	// The excluded package writes true once, below the threshold.
+	analysistest.Run(t, analysistest.TestData(), analyzers[0], "excluded")
+}
+
+func TestPluginSettingsErrors(t *testing.T) {
+	for _, settings := range []map[string]any{
+		{"disable": []string{"BS9999"}},
+		{"exclude": []string{"("}},
+		{"exclude-key-types": "("},
+		{"unknown": true},
+	} {
+		if _, err := New(settings); err == nil {
+			t.Errorf("expected an error for settings %v", settings)
+		}
+	}
+}
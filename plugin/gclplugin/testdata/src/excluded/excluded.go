@@ -0,0 +1,6 @@
+package excluded
+
+func f() {
+	set := map[string]bool{}
+	set["a"] = true
+}
@@ -0,0 +1,6 @@
+package p
+
+func f() {
+	set := map[string]bool{} // want `map\[string\]bool only stores "true" values`
+	set["a"] = true
+}
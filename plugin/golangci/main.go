@@ -8,9 +8,7 @@ import (
 type analyzerPlugin struct{}
 
 func (*analyzerPlugin) GetAnalyzers() []*analysis.Analyzer {
-	return []*analysis.Analyzer{
-		boolset.NewAnalyzer(),
-	}
+	return boolset.Analyzers()
 }
 
 var AnalyzerPlugin analyzerPlugin